@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/plan42-ai/sdk-go/p42"
+)
+
+type AuthProviderOptions struct {
+	Create      CreateAuthProviderOptions      `cmd:"" help:"Create an authentication provider for a tenant."`
+	List        ListAuthProvidersOptions       `cmd:"" help:"List the authentication providers of a tenant."`
+	Get         GetAuthProviderOptions         `cmd:"" help:"Get an authentication provider by ID."`
+	Update      UpdateAuthProviderOptions      `cmd:"" help:"Update an authentication provider."`
+	Delete      DeleteAuthProviderOptions      `cmd:"" help:"Soft delete an authentication provider."`
+	VerifyToken VerifyAuthProviderTokenOptions `cmd:"" help:"Verify a JWT against an authentication provider, using the provider's JWKS."`
+}
+
+type CreateAuthProviderOptions struct {
+	TenantID   string `help:"The tenant ID to create the provider under." name:"tenant-id" short:"i" required:""`
+	ProviderID string `help:"Optional. The ID of the provider to create. A random ID is used if not set." name:"provider-id" short:"p" optional:""`
+	JSON       string `help:"The JSON file containing the provider definition. Use '-' to read from stdin." short:"j" default:"-"`
+}
+
+func (o *CreateAuthProviderOptions) Run(ctx context.Context, s *SharedOptions) error {
+	err := validateJSONFeatureFlags(o.JSON, s.FeatureFlags)
+	if err != nil {
+		return err
+	}
+	var req p42.CreateAuthProviderRequest
+	err = readJsonFile(o.JSON, &req)
+	if err != nil {
+		return err
+	}
+	err = loadFeatureFlags(s, &req.FeatureFlags)
+	if err != nil {
+		return err
+	}
+	req.TenantID = o.TenantID
+	req.ProviderID = o.ProviderID
+	if req.ProviderID == "" {
+		req.ProviderID = uuid.NewString()
+	}
+	processDelegatedAuth(s, &req.DelegatedAuthInfo)
+
+	provider, err := s.Client.CreateAuthProvider(ctx, &req)
+	if err != nil {
+		return err
+	}
+	return printJSON(provider)
+}
+
+type ListAuthProvidersOptions struct {
+	TenantID       string `help:"The tenant ID to list providers for." name:"tenant-id" short:"i" required:""`
+	IncludeDeleted bool   `help:"When set, includes deleted providers in the results." short:"d" optional:""`
+}
+
+func (o *ListAuthProvidersOptions) Run(ctx context.Context, s *SharedOptions) error {
+	req := &p42.ListAuthProvidersRequest{
+		TenantID:       o.TenantID,
+		IncludeDeleted: pointer(o.IncludeDeleted),
+	}
+
+	if err := loadFeatureFlags(s, &req.FeatureFlags); err != nil {
+		return err
+	}
+	processDelegatedAuth(s, &req.DelegatedAuthInfo)
+
+	for {
+		resp, err := s.Client.ListAuthProviders(ctx, req)
+		if err != nil {
+			return err
+		}
+
+		for _, provider := range resp.Items {
+			if err := printJSON(provider); err != nil {
+				return err
+			}
+		}
+
+		if resp.NextToken == nil {
+			break
+		}
+
+		req.Token = resp.NextToken
+	}
+
+	return nil
+}
+
+type GetAuthProviderOptions struct {
+	TenantID       string `help:"The tenant ID that owns the provider." name:"tenant-id" short:"i" required:""`
+	ProviderID     string `help:"The provider ID to fetch." name:"provider-id" short:"p" required:""`
+	IncludeDeleted bool   `help:"Set to return a deleted provider." name:"include-deleted" short:"d" optional:""`
+}
+
+func (o *GetAuthProviderOptions) Run(ctx context.Context, s *SharedOptions) error {
+	req := &p42.GetAuthProviderRequest{
+		TenantID:       o.TenantID,
+		ProviderID:     o.ProviderID,
+		IncludeDeleted: pointer(o.IncludeDeleted),
+	}
+
+	err := loadFeatureFlags(s, &req.FeatureFlags)
+	if err != nil {
+		return err
+	}
+
+	processDelegatedAuth(s, &req.DelegatedAuthInfo)
+
+	provider, err := s.Client.GetAuthProvider(ctx, req)
+	if err != nil {
+		return err
+	}
+	return printJSON(provider)
+}
+
+type UpdateAuthProviderOptions struct {
+	TenantID   string `help:"The tenant ID that owns the provider." name:"tenant-id" short:"i" required:""`
+	ProviderID string `help:"The provider ID to update." name:"provider-id" short:"p" required:""`
+	JSON       string `help:"The json file containing the provider update. Use '-' to read from stdin." short:"j" default:"-"`
+}
+
+func (o *UpdateAuthProviderOptions) Run(ctx context.Context, s *SharedOptions) error {
+	err := validateJSONFeatureFlags(o.JSON, s.FeatureFlags)
+	if err != nil {
+		return err
+	}
+	var req p42.UpdateAuthProviderRequest
+	err = readJsonFile(o.JSON, &req)
+	if err != nil {
+		return err
+	}
+	err = loadFeatureFlags(s, &req.FeatureFlags)
+	if err != nil {
+		return err
+	}
+	req.TenantID = o.TenantID
+	req.ProviderID = o.ProviderID
+
+	getReq := &p42.GetAuthProviderRequest{
+		TenantID:       o.TenantID,
+		ProviderID:     o.ProviderID,
+		IncludeDeleted: pointer(true),
+	}
+	getReq.FeatureFlags = req.FeatureFlags
+	processDelegatedAuth(s, &getReq.DelegatedAuthInfo)
+	provider, err := s.Client.GetAuthProvider(ctx, getReq)
+	if err != nil {
+		return err
+	}
+	req.Version = provider.Version
+	processDelegatedAuth(s, &req.DelegatedAuthInfo)
+
+	updated, err := s.Client.UpdateAuthProvider(ctx, &req)
+	if err != nil {
+		return err
+	}
+	return printJSON(updated)
+}
+
+type DeleteAuthProviderOptions struct {
+	TenantID   string `help:"The tenant ID that owns the provider." name:"tenant-id" short:"i" required:""`
+	ProviderID string `help:"The provider ID to delete." name:"provider-id" short:"p" required:""`
+}
+
+func (o *DeleteAuthProviderOptions) Run(ctx context.Context, s *SharedOptions) error {
+	getReq := &p42.GetAuthProviderRequest{
+		TenantID:   o.TenantID,
+		ProviderID: o.ProviderID,
+	}
+
+	err := loadFeatureFlags(s, &getReq.FeatureFlags)
+	if err != nil {
+		return err
+	}
+
+	processDelegatedAuth(s, &getReq.DelegatedAuthInfo)
+
+	provider, err := s.Client.GetAuthProvider(ctx, getReq)
+	if err != nil {
+		return err
+	}
+
+	delReq := &p42.DeleteAuthProviderRequest{
+		TenantID:   o.TenantID,
+		ProviderID: o.ProviderID,
+		Version:    provider.Version,
+	}
+	delReq.FeatureFlags = getReq.FeatureFlags
+	processDelegatedAuth(s, &delReq.DelegatedAuthInfo)
+
+	return s.Client.DeleteAuthProvider(ctx, delReq)
+}
+
+type VerifyAuthProviderTokenOptions struct {
+	TenantID   string `help:"The tenant ID that owns the provider." name:"tenant-id" short:"i" required:""`
+	ProviderID string `help:"The provider ID to verify the token against." name:"provider-id" short:"p" required:""`
+	JWKSURL    string `help:"The URL of the provider's JSON Web Key Set." name:"jwks-url" short:"k" required:""`
+	TokenFile  string `help:"The file containing the JWT to verify. Use '-' to read from stdin." name:"token-file" short:"t" default:"-"`
+}
+
+func (o *VerifyAuthProviderTokenOptions) Run(ctx context.Context, s *SharedOptions) error {
+	token, err := o.readToken()
+	if err != nil {
+		return err
+	}
+
+	req := &p42.GetAuthProviderRequest{
+		TenantID:   o.TenantID,
+		ProviderID: o.ProviderID,
+	}
+	err = loadFeatureFlags(s, &req.FeatureFlags)
+	if err != nil {
+		return err
+	}
+	processDelegatedAuth(s, &req.DelegatedAuthInfo)
+
+	provider, err := s.Client.GetAuthProvider(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	verifier, err := p42.NewJWTVerifier(&p42.JWTVerifierConfig{Provider: provider, JWKSURL: o.JWKSURL})
+	if err != nil {
+		return err
+	}
+	claims, err := verifier.Verify(ctx, token)
+	if err != nil {
+		return err
+	}
+	return printJSON(claims.Raw)
+}
+
+func (o *VerifyAuthProviderTokenOptions) readToken() (string, error) {
+	var data []byte
+	var err error
+	if o.TokenFile == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(o.TokenFile)
+	}
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("token is empty")
+	}
+	return token, nil
+}
@@ -1,11 +1,15 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"fmt"
+	"os"
 	"strings"
 
 	"github.com/google/uuid"
 	"github.com/plan42-ai/sdk-go/p42"
+	"golang.org/x/term"
 )
 
 type EnvironmentOptions struct {
@@ -14,11 +18,15 @@ type EnvironmentOptions struct {
 	Update UpdateEnvironmentOptions `cmd:"" help:"Update an existing environment."`
 	Delete DeleteEnvironmentOptions `cmd:"" help:"Soft delete an environment."`
 	List   ListEnvironmentsOptions  `cmd:"" help:"List environments for a tenant."`
+	Apply  ApplyEnvironmentOptions  `cmd:"" help:"Create or update an environment to match a YAML spec."`
+	Clone  CloneEnvironmentOptions  `cmd:"" help:"Copy an environment to a new environment ID, possibly in another tenant."`
 }
 
 type CreateEnvironmentOptions struct {
-	TenantID string `help:"The tenant ID to create the environment for" short:"i" required:""`
-	JSON     string `help:"The JSON file to load the environment definition from" short:"j" default:"-"`
+	TenantID       string `help:"The tenant ID to create the environment for" short:"i" required:""`
+	JSON           string `help:"The JSON file to load the environment definition from" short:"j" default:"-"`
+	ResolveSecrets bool   `help:"Resolve secret references (env://, file://) locally, and store the secrets instead of the references." name:"resolve-secrets"`
+	SkipValidation bool   `help:"Send the environment to the service without validating it first." name:"skip-validation"`
 }
 
 func (o *CreateEnvironmentOptions) Run(ctx context.Context, s *SharedOptions) error {
@@ -35,7 +43,16 @@ func (o *CreateEnvironmentOptions) Run(ctx context.Context, s *SharedOptions) er
 	}
 	req.TenantID = o.TenantID
 	req.EnvironmentID = uuid.NewString()
+	if o.ResolveSecrets {
+		req.EnvVars, err = p42.ResolveEnvVars(ctx, req.EnvVars)
+		if err != nil {
+			return err
+		}
+	}
 	processDelegatedAuth(s, &req.DelegatedAuthInfo)
+	if err := validateEnvironment(ctx, s, req.Environment(), req.FeatureFlags, o.SkipValidation); err != nil {
+		return err
+	}
 
 	env, err := s.Client.CreateEnvironment(ctx, &req)
 	if err != nil {
@@ -73,9 +90,11 @@ func (o *GetEnvironmentOptions) Run(ctx context.Context, s *SharedOptions) error
 }
 
 type UpdateEnvironmentOptions struct {
-	TenantID      string `help:"The tennant ID that owns the environment being fetched." short:"i" required:""`
-	EnvironmentID string `help:"The ID of the environment to update" name:"environment-id" short:"e" required:""`
-	JSON          string `help:"The json file containing the environment updates" short:"j" default:"-" required:""`
+	TenantID       string `help:"The tennant ID that owns the environment being fetched." short:"i" required:""`
+	EnvironmentID  string `help:"The ID of the environment to update" name:"environment-id" short:"e" required:""`
+	JSON           string `help:"The json file containing the environment updates" short:"j" default:"-" required:""`
+	ResolveSecrets bool   `help:"Resolve secret references (env://, file://) locally, and store the secrets instead of the references." name:"resolve-secrets"`
+	SkipValidation bool   `help:"Send the environment to the service without validating it first." name:"skip-validation"`
 }
 
 // nolint: dupl
@@ -93,6 +112,13 @@ func (o *UpdateEnvironmentOptions) Run(ctx context.Context, s *SharedOptions) er
 	}
 	req.TenantID = o.TenantID
 	req.EnvironmentID = o.EnvironmentID
+	if o.ResolveSecrets && req.EnvVars != nil {
+		envVars, err := p42.ResolveEnvVars(ctx, *req.EnvVars)
+		if err != nil {
+			return err
+		}
+		req.EnvVars = &envVars
+	}
 
 	getReq := &p42.GetEnvironmentRequest{
 		TenantID:       o.TenantID,
@@ -107,11 +133,15 @@ func (o *UpdateEnvironmentOptions) Run(ctx context.Context, s *SharedOptions) er
 	}
 	req.Version = env.Version
 	processDelegatedAuth(s, &req.DelegatedAuthInfo)
+	if err := validateEnvironment(ctx, s, req.ApplyTo(env), req.FeatureFlags, o.SkipValidation); err != nil {
+		return err
+	}
 
 	updated, err := s.Client.UpdateEnvironment(ctx, &req)
 	if err != nil {
 		return err
 	}
+	maskSecrets(updated, s)
 	return printJSON(updated)
 }
 
@@ -175,13 +205,142 @@ func (o *ListEnvironmentsOptions) Run(ctx context.Context, s *SharedOptions) err
 	return nil
 }
 
-func maskSecrets(e *p42.Environment, s *SharedOptions) {
-	if s.ShowSecrets {
-		return
+type ApplyEnvironmentOptions struct {
+	TenantID       string `help:"The tenant ID that owns the environment." short:"i" required:""`
+	File           string `help:"The YAML file containing the environment spec." short:"f" default:"-"`
+	DryRun         bool   `help:"Print the plan without applying it." name:"dry-run"`
+	ResolveSecrets bool   `help:"Resolve secret references (env://, file://) locally, and store the secrets instead of the references." name:"resolve-secrets"`
+	SkipValidation bool   `help:"Send the environment to the service without validating it first." name:"skip-validation"`
+}
+
+func (o *ApplyEnvironmentOptions) Run(ctx context.Context, s *SharedOptions) error {
+	in := os.Stdin
+	if o.File != "-" {
+		f, err := os.Open(o.File)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	spec, err := p42.ParseEnvironmentSpec(in)
+	if err != nil {
+		return err
 	}
-	for i := range e.EnvVars {
-		if e.EnvVars[i].IsSecret {
-			e.EnvVars[i].Value = strings.Repeat("*", len(e.EnvVars[i].Value))
+
+	req := &p42.PlanEnvironmentRequest{TenantID: o.TenantID, Spec: spec, ResolveSecrets: o.ResolveSecrets}
+	if err := loadFeatureFlags(s, &req.FeatureFlags); err != nil {
+		return err
+	}
+	processDelegatedAuth(s, &req.DelegatedAuthInfo)
+	err = validateEnvironment(ctx, s, spec.Environment(o.TenantID), req.FeatureFlags, o.SkipValidation)
+	if err != nil {
+		return err
+	}
+	plan, err := p42.PlanEnvironment(ctx, s.Client, req)
+	if err != nil {
+		return err
+	}
+	fmt.Print(plan)
+	if o.DryRun || plan.Action == p42.EnvironmentPlanNone {
+		return nil
+	}
+
+	env, err := p42.ApplyEnvironmentPlan(ctx, s.Client, plan)
+	if err != nil {
+		return err
+	}
+	maskSecrets(env, s)
+	return printJSON(env)
+}
+
+type CloneEnvironmentOptions struct {
+	TenantID           string  `help:"The tenant ID that owns the environment being cloned." short:"i" required:""`
+	EnvironmentID      string  `help:"The ID of the environment to clone." name:"environment-id" short:"e" required:""`
+	ToTenantID         string  `help:"The tenant ID to create the clone in. Defaults to the source tenant." name:"to-tenant-id"`
+	ToEnvironmentID    string  `help:"The ID of the clone. Defaults to a new ID." name:"to-environment-id"`
+	Name               *string `help:"The name of the clone. Defaults to the source environment's name." name:"name"`
+	RunnerID           *string `help:"The runner ID for the clone. Defaults to the source's runner, or to the default runner of another tenant." name:"runner-id"`
+	GithubConnectionID *string `help:"The GitHub connection ID for the clone. Defaults to the source's connection, or to the default connection of another tenant." name:"github-connection-id"`
+	Secrets            string  `help:"What to do with secret env vars: copy them, strip them, or prompt for new values." enum:"copy,strip,prompt" default:"copy"`
+	SkipValidation     bool    `help:"Send the environment to the service without validating it first." name:"skip-validation"`
+}
+
+func (o *CloneEnvironmentOptions) Run(ctx context.Context, s *SharedOptions) error {
+	req := &p42.CloneEnvironmentRequest{
+		SourceTenantID:           o.TenantID,
+		SourceEnvironmentID:      o.EnvironmentID,
+		DestinationTenantID:      o.ToTenantID,
+		DestinationEnvironmentID: o.ToEnvironmentID,
+		Name:                     o.Name,
+		RunnerID:                 o.RunnerID,
+		GithubConnectionID:       o.GithubConnectionID,
+		Secrets:                  p42.CloneSecretsMode(o.Secrets),
+	}
+	if req.DestinationEnvironmentID == "" {
+		req.DestinationEnvironmentID = uuid.NewString()
+	}
+	if err := loadFeatureFlags(s, &req.FeatureFlags); err != nil {
+		return err
+	}
+	processDelegatedAuth(s, &req.DelegatedAuthInfo)
+	if req.Secrets == p42.CloneSecretsPrompt {
+		stdin := bufio.NewReader(os.Stdin)
+		req.PromptSecret = func(_ context.Context, envVar p42.EnvVar) (string, error) {
+			fmt.Fprintf(os.Stderr, "Value for secret %s (empty removes it): ", envVar.Name)
+			value, err := readSecret(stdin)
+			if err != nil {
+				return "", fmt.Errorf("unable to read value for secret %s: %w", envVar.Name, err)
+			}
+			return value, nil
 		}
 	}
+	if !o.SkipValidation {
+		req.Resolver = p42.NewEnvironmentResolver(s.Client, req.FeatureFlags, req.DelegatedAuthInfo)
+	}
+
+	env, err := p42.CloneEnvironment(ctx, s.Client, req)
+	if err != nil {
+		return err
+	}
+	maskSecrets(env, s)
+	return printJSON(env)
+}
+
+// readSecret reads a secret value from stdin. If stdin is a terminal, the value isn't echoed as it is typed;
+// otherwise a line is read from stdin.
+func readSecret(stdin *bufio.Reader) (string, error) {
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) { // #nosec G115: File descriptors fit in an int.
+		value, err := term.ReadPassword(fd)
+		// The newline typed by the user isn't echoed either.
+		fmt.Fprintln(os.Stderr)
+		return string(value), err
+	}
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// validateEnvironment validates an environment before it is sent to the service, unless skip is set.
+func validateEnvironment(
+	ctx context.Context,
+	s *SharedOptions,
+	env *p42.Environment,
+	flags p42.FeatureFlags,
+	skip bool,
+) error {
+	if skip {
+		return nil
+	}
+	var auth p42.DelegatedAuthInfo
+	processDelegatedAuth(s, &auth)
+	return env.Validate(ctx, p42.NewEnvironmentResolver(s.Client, flags, auth))
+}
+
+func maskSecrets(e *p42.Environment, s *SharedOptions) {
+	if !s.ShowSecrets {
+		e.MaskSecrets()
+	}
 }
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"math"
 
 	"github.com/google/uuid"
 	"github.com/plan42-ai/sdk-go/internal/util"
@@ -23,6 +24,7 @@ type GithubOptions struct {
 	GetTenantCreds    GetTenantGithubCredsOptions    `cmd:"" help:"Fetch GitHub credentials for a tenant."`
 	UpdateTenantCreds UpdateTenantGithubCredsOptions `cmd:"" help:"Update GitHub credentials for a tenant."`
 	FindUsers         FindGithubUsersOptions         `cmd:"" help:"Find tenants given their github login or user id."`
+	SearchRepos       SearchGithubReposOptions       `cmd:"" help:"Search the repositories of a GitHub org available through a GitHub connection."`
 }
 
 // FindGithubUsersOptions provides options for the `github find-users` command.
@@ -172,6 +174,54 @@ func (o *ListGithubConnectionsOptions) Run(ctx context.Context, s *SharedOptions
 	return nil
 }
 
+type SearchGithubReposOptions struct {
+	TenantID     string `help:"The id of the tenant that owns the connection." name:"tenant-id" short:"i" required:""`
+	ConnectionID string `help:"The ID of the GitHub connection to search through." name:"connection-id" short:"c" required:""`
+	OrgName      string `help:"The name of the GitHub org to search in." name:"org-name" short:"o" required:""`
+	Search       string `help:"The search string to filter repositories by." name:"search" short:"q" optional:""`
+	MaxElements  *int   `help:"Maximum number of repositories to retrieve." short:"m" optional:""`
+}
+
+func (o *SearchGithubReposOptions) Run(ctx context.Context, s *SharedOptions) error {
+	req := &p42.SearchReposRequest{
+		TenantID:     o.TenantID,
+		ConnectionID: o.ConnectionID,
+		OrgName:      o.OrgName,
+		Search:       o.Search,
+	}
+
+	err := loadFeatureFlags(s, &req.FeatureFlags)
+	if err != nil {
+		return err
+	}
+
+	processDelegatedAuth(s, &req.DelegatedAuthInfo)
+
+	remaining := math.MaxInt
+	if o.MaxElements != nil {
+		remaining = *o.MaxElements
+		if remaining <= 0 {
+			return nil
+		}
+		req.MaxResults = pointer(min(remaining, 499))
+	}
+
+	for repo, err := range s.Client.SearchReposAll(ctx, req) {
+		if err != nil {
+			return err
+		}
+		err = printJSON(repo)
+		if err != nil {
+			return err
+		}
+		remaining--
+		if remaining == 0 {
+			break
+		}
+	}
+	return nil
+}
+
 type UpdateGithubConnectionOptions struct {
 	TenantID     string `help:"The id of the tenant that owns the connection." name:"tenant-id" short:"i" required:""`
 	ConnectionID string `help:"The ID of the connection to update." name:"connection-id" short:"c" required:""`
@@ -21,6 +21,7 @@ var helpMap = map[string]string{
     "GithubUserID" : *int,
     "OAuthToken": "*string",
     "RefreshToken": "*string",
+    "TokenExpiry": "*string",
     "State"  : "*string",
     "StateExpiry" : "*string"
 }
@@ -90,6 +91,27 @@ var helpMap = map[string]string{
     "RunnerID" : "*string",
     "GithubConnectionID" : "*string"
 }
+`,
+	"environment apply": `
+--- Input YAML Spec ---
+
+id: string                   # the environment id, created if it doesn't exist
+name: string
+description: string
+context: string
+repos: [org/repo, ...]
+setupScript: string
+dockerImage: string
+allowedHosts: [string, ...]
+envVars:
+  - name: string
+    value: string
+    secret: bool             # the value of a secret can be a reference: env://NAME, file:///path
+runnerId: string             # omit for the default runner
+githubConnectionId: string   # omit for the default connection
+
+Omitted fields are cleared. The plan never shows the values of secret env vars, only secret references. Secret
+references are stored and resolved by the runner, unless --resolve-secrets is set.
 `,
 	"tenant update": `
 --- Input JSON Schema ---
@@ -208,6 +230,17 @@ var helpMap = map[string]string{
         }
     ]
 }
+`,
+	"logs import": `
+--- Input JSONL Schema (one object per line) ---
+
+{
+    "TaskId": "string",
+    "TurnIndex": int,
+    "Index": int,
+    "Timestamp": "string",
+    "Message": "string"
+}
 `,
 	"feature-flag add": `
 
@@ -270,5 +303,25 @@ var helpMap = map[string]string{
     "ProxiesGithub":*bool,
     "Deleted": "*bool"    
 }
+`,
+	"auth-provider create": `
+
+--- Input JSON Schema ---
+
+{
+    "Name": "string",
+    "Issuer": "string",
+    "Audience": "string"
+}
+`,
+	"auth-provider update": `
+--- Input JSON Schema ---
+
+{
+    "Name": "*string",
+    "Issuer": "*string",
+    "Audience": "*string",
+    "Deleted": "*bool"
+}
 `,
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"time"
@@ -14,27 +15,37 @@ import (
 type LogsOptions struct {
 	Stream StreamLogsOptions `cmd:"" help:"Stream logs for a given turn."`
 	Upload UploadLogsOptions `cmd:"" help:"Upload a batch of logs for a given turn."`
+	Export ExportLogsOptions `cmd:"" help:"Export the logs of a turn, task or workstream to an archive."`
+	Import ImportLogsOptions `cmd:"" help:"Import the logs in an archive into a turn."`
 }
 
 type StreamLogsOptions struct {
 	TenantID       string `help:"The id of the tenant that owns the task / turn to stream logs for." name:"tenant-id" short:"i" required:""`
 	TaskID         string `help:"The id of the task to stream logs for." name:"task-id" short:"t" required:""`
-	TurnIndex      int    `help:"The turn to stream logs for." name:"turn-index" short:"n" required:""`
+	TurnIndex      *int   `help:"The turn to stream logs for. With --follow-task, the turn to start from." name:"turn-index" short:"n" optional:""`
 	IncludeDeleted bool   `help:"Include logs for turns on deleted tasks" short:"d"`
+	FollowTask     bool   `help:"Follow the task across turns, until the task is completed." name:"follow-task" short:"f"`
 }
 
-func (o *StreamLogsOptions) Run(_ context.Context, s *SharedOptions) error {
-	// TODO: Modify this so that NewLogStream uses the passed in context.
+func (o *StreamLogsOptions) Run(ctx context.Context, s *SharedOptions) error {
 	var flags p42.FeatureFlags
 	err := loadFeatureFlags(s, &flags)
 	if err != nil {
 		return err
 	}
-	ls := p42.NewLogStream(
+	if o.FollowTask {
+		return o.followTask(ctx, s, flags)
+	}
+	if o.TurnIndex == nil {
+		return fmt.Errorf("--turn-index is required unless --follow-task is set")
+	}
+
+	ls := p42.NewLogStreamContext(
+		ctx,
 		s.Client,
 		o.TenantID,
 		o.TaskID,
-		o.TurnIndex,
+		*o.TurnIndex,
 		1000,
 		p42.WithIncludeDeleted(o.IncludeDeleted),
 		p42.WithFeatureFlags(flags.FeatureFlags),
@@ -49,7 +60,37 @@ func (o *StreamLogsOptions) Run(_ context.Context, s *SharedOptions) error {
 		}
 	}
 
-	return ls.ShutdownTimeout(2 * time.Second)
+	if err := ls.ShutdownTimeout(2 * time.Second); err != nil {
+		return err
+	}
+	return ls.Err()
+}
+
+func (o *StreamLogsOptions) followTask(ctx context.Context, s *SharedOptions, flags p42.FeatureFlags) error {
+	cfg := &p42.TaskLogFollowerConfig{
+		Client:         s.Client,
+		TenantID:       o.TenantID,
+		TaskID:         o.TaskID,
+		IncludeDeleted: o.IncludeDeleted,
+		FeatureFlags:   flags.FeatureFlags,
+		StartTurnIndex: o.TurnIndex,
+		Buffer:         1000,
+	}
+	f := p42.NewTaskLogFollower(ctx, cfg)
+	defer f.Close()
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	for log := range f.Logs() {
+		if err := enc.Encode(log); err != nil {
+			return err
+		}
+	}
+
+	if err := f.ShutdownTimeout(2 * time.Second); err != nil {
+		return err
+	}
+	return f.Err()
 }
 
 type UploadLogsOptions struct {
@@ -57,6 +98,7 @@ type UploadLogsOptions struct {
 	TaskID    string `help:"The id of the task to upload logs for." name:"task-id" short:"t" required:""`
 	TurnIndex int    `help:"The turn to upload logs for." name:"turn-index" short:"n" required:""`
 	JSON      string `help:"The file containing the logs to upload." short:"j" default:"-"`
+	Text      bool   `help:"Treat the file as plain text, uploading each line as a log, instead of as JSON log entries."`
 }
 
 func (o *UploadLogsOptions) Run(ctx context.Context, s *SharedOptions) error {
@@ -111,18 +153,134 @@ func (o *UploadLogsOptions) Run(ctx context.Context, s *SharedOptions) error {
 		},
 	)
 
-	dec := json.NewDecoder(reader)
-	for {
-		var entry p42.TurnLog
-		if err := dec.Decode(&entry); err != nil {
-			if errors.Is(err, io.EOF) {
-				break
-			}
+	if o.Text {
+		w := p42.NewTurnLogWriter(&p42.TurnLogWriterConfig{Logs: logsCh, Done: lu.Done()})
+		_, err = io.Copy(w, reader)
+		err = errors.Join(err, w.Close())
+		if err != nil {
 			_ = lu.Close()
-			return err
+			return uploadLogsError(lu, err)
+		}
+	} else {
+		dec := json.NewDecoder(reader)
+		for {
+			var entry p42.TurnLog
+			if err := dec.Decode(&entry); err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				_ = lu.Close()
+				return err
+			}
+			select {
+			case logsCh <- entry:
+			case <-lu.Done():
+				_ = lu.Close()
+				return uploadLogsError(lu, p42.ErrTurnLogsDone)
+			}
 		}
-		logsCh <- entry
 	}
 	close(logsCh)
-	return lu.ShutdownTimeout(10 * time.Second)
+	if err := lu.ShutdownTimeout(10 * time.Second); err != nil {
+		_ = lu.Close()
+		return err
+	}
+	return lu.Err()
+}
+
+// uploadLogsError prefers the error that stopped the uploader over err, since it explains why logs could not be sent.
+func uploadLogsError(lu *p42.LogUploader, err error) error {
+	if luErr := lu.Err(); luErr != nil && errors.Is(err, p42.ErrTurnLogsDone) {
+		return luErr
+	}
+	return err
+}
+
+type ExportLogsOptions struct {
+	TenantID       string  `help:"The id of the tenant that owns the logs to export." name:"tenant-id" short:"i" required:""`
+	WorkstreamID   *string `help:"Export the logs of every task in this workstream." name:"workstream-id" short:"w" optional:""`
+	TaskID         *string `help:"Export the logs of every turn of this task." name:"task-id" short:"t" optional:""`
+	TurnIndex      *int    `help:"Export only the logs of this turn. Requires --task-id." name:"turn-index" short:"n" optional:""`
+	IncludeDeleted bool    `help:"Include deleted tasks and turns." short:"d"`
+	Format         string  `help:"The archive format: jsonl or text." enum:"jsonl,text" default:"jsonl"`
+	Gzip           bool    `help:"Compress the archive with gzip." short:"z"`
+	Output         string  `help:"The file to write the archive to." short:"o" default:"-"`
+}
+
+func (o *ExportLogsOptions) Run(ctx context.Context, s *SharedOptions) error {
+	req := &p42.ExportLogsRequest{
+		TenantID:       o.TenantID,
+		WorkstreamID:   o.WorkstreamID,
+		TaskID:         o.TaskID,
+		TurnIndex:      o.TurnIndex,
+		IncludeDeleted: pointer(o.IncludeDeleted),
+	}
+	err := loadFeatureFlags(s, &req.FeatureFlags)
+	if err != nil {
+		return err
+	}
+	processDelegatedAuth(s, &req.DelegatedAuthInfo)
+
+	var out *os.File
+	if o.Output == "-" {
+		out = os.Stdout
+	} else {
+		out, err = os.Create(o.Output)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+
+	w, err := p42.NewLogArchiveWriter(out, p42.LogArchiveFormat(o.Format), o.Gzip)
+	if err != nil {
+		return err
+	}
+	err = p42.ExportLogs(ctx, s.Client, req, w)
+	return errors.Join(err, w.Close())
+}
+
+type ImportLogsOptions struct {
+	TenantID        string  `help:"The id of the tenant that owns the turn to import logs into." name:"tenant-id" short:"i" required:""`
+	TaskID          string  `help:"The id of the task to import logs into." name:"task-id" short:"t" required:""`
+	TurnIndex       int     `help:"The turn to import logs into." name:"turn-index" short:"n" required:""`
+	SourceTaskID    *string `help:"The task in the archive to import logs from, if it contains more than one." name:"source-task-id" optional:""`
+	SourceTurnIndex *int    `help:"The turn in the archive to import logs from, if it contains more than one." name:"source-turn-index" optional:""`
+	Input           string  `help:"The JSONL archive to import, which may be gzipped." default:"-"`
+}
+
+func (o *ImportLogsOptions) Run(ctx context.Context, s *SharedOptions) error {
+	if err := validateJSONFeatureFlags(o.Input, s.FeatureFlags); err != nil {
+		return err
+	}
+	req := &p42.ImportLogsRequest{
+		TenantID:        o.TenantID,
+		TaskID:          o.TaskID,
+		TurnIndex:       o.TurnIndex,
+		SourceTaskID:    o.SourceTaskID,
+		SourceTurnIndex: o.SourceTurnIndex,
+	}
+	err := loadFeatureFlags(s, &req.FeatureFlags)
+	if err != nil {
+		return err
+	}
+	processDelegatedAuth(s, &req.DelegatedAuthInfo)
+
+	var in *os.File
+	if o.Input == "-" {
+		in = os.Stdin
+	} else {
+		in, err = os.Open(o.Input)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+	}
+
+	r, err := p42.NewLogArchiveReader(in)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return p42.ImportLogs(ctx, s.Client, req, r)
 }
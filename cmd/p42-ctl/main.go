@@ -97,6 +97,14 @@ func readJsonFile[T any](fileName string, ptr *T) error {
 	return json.NewDecoder(file).Decode(ptr)
 }
 
+func writeJsonFile(fileName string, value any) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, data, 0o600)
+}
+
 func ensureNoFeatureFlags(s *SharedOptions, cmd string) error {
 	if s.FeatureFlags != nil {
 		return fmt.Errorf(featureFlagsNotSupported, cmd)
@@ -106,18 +114,19 @@ func ensureNoFeatureFlags(s *SharedOptions, cmd string) error {
 
 type Options struct {
 	SharedOptions
-	Tenant      TenantOptions      `cmd:""`
-	Policies    PolicyOptions      `cmd:""`
-	Github      GithubOptions      `cmd:"" help:"commands related to github"`
-	UIToken     UITokenOptions     `cmd:""`
-	Environment EnvironmentOptions `cmd:""`
-	Task        TaskOptions        `cmd:""`
-	Turn        TurnOptions        `cmd:""`
-	Logs        LogsOptions        `cmd:""`
-	FeatureFlag FeatureFlagOptions `cmd:""`
-	Workstream  WorkstreamOptions  `cmd:""`
-	Runner      RunnerOptions      `cmd:""`
-	Ctx         context.Context    `kong:"-"`
+	Tenant       TenantOptions       `cmd:""`
+	Policies     PolicyOptions       `cmd:""`
+	Github       GithubOptions       `cmd:"" help:"commands related to github"`
+	UIToken      UITokenOptions      `cmd:""`
+	Environment  EnvironmentOptions  `cmd:""`
+	Task         TaskOptions         `cmd:""`
+	Turn         TurnOptions         `cmd:""`
+	Logs         LogsOptions         `cmd:""`
+	FeatureFlag  FeatureFlagOptions  `cmd:""`
+	Workstream   WorkstreamOptions   `cmd:""`
+	Runner       RunnerOptions       `cmd:""`
+	AuthProvider AuthProviderOptions `cmd:"" help:"commands related to authentication providers (proposed API, not yet supported by the service)"`
+	Ctx          context.Context     `kong:"-"`
 }
 
 func main() {
@@ -189,6 +198,8 @@ func dispatchCommand(kongctx *kong.Context, options *Options) error {
 		return options.Github.UpdateOrg.Run(options.Ctx, &options.SharedOptions)
 	case "github delete-org":
 		return options.Github.DeleteOrg.Run(options.Ctx, &options.SharedOptions)
+	case "github search-repos":
+		return options.Github.SearchRepos.Run(options.Ctx, &options.SharedOptions)
 	case "github find-users":
 		return options.Github.FindUsers.Run(options.Ctx, &options.SharedOptions)
 	case "github get-tenant-creds":
@@ -205,6 +216,10 @@ func dispatchCommand(kongctx *kong.Context, options *Options) error {
 		return options.Environment.Delete.Run(options.Ctx, &options.SharedOptions)
 	case "environment list":
 		return options.Environment.List.Run(options.Ctx, &options.SharedOptions)
+	case "environment apply":
+		return options.Environment.Apply.Run(options.Ctx, &options.SharedOptions)
+	case "environment clone":
+		return options.Environment.Clone.Run(options.Ctx, &options.SharedOptions)
 	case "task create":
 		return options.Task.Create.Run(options.Ctx, &options.SharedOptions)
 	case "task update":
@@ -217,6 +232,12 @@ func dispatchCommand(kongctx *kong.Context, options *Options) error {
 		return options.Task.Search.Run(options.Ctx, &options.SharedOptions)
 	case "task get":
 		return options.Task.Get.Run(options.Ctx, &options.SharedOptions)
+	case "task bulk":
+		return options.Task.Bulk.Run(options.Ctx, &options.SharedOptions)
+	case "task watch":
+		return options.Task.Watch.Run(options.Ctx, &options.SharedOptions)
+	case "task sync-prs":
+		return options.Task.SyncPRs.Run(options.Ctx, &options.SharedOptions)
 	case "task get-github-creds":
 		return options.Task.GetGithubCreds.Run(options.Ctx, &options.SharedOptions)
 	case "turn create":
@@ -233,6 +254,10 @@ func dispatchCommand(kongctx *kong.Context, options *Options) error {
 		return options.Logs.Stream.Run(options.Ctx, &options.SharedOptions)
 	case "logs upload":
 		return options.Logs.Upload.Run(options.Ctx, &options.SharedOptions)
+	case "logs export":
+		return options.Logs.Export.Run(options.Ctx, &options.SharedOptions)
+	case "logs import":
+		return options.Logs.Import.Run(options.Ctx, &options.SharedOptions)
 	case "feature-flag add":
 		return options.FeatureFlag.Add.Run(options.Ctx, &options.SharedOptions)
 	case "feature-flag list":
@@ -271,6 +296,18 @@ func dispatchCommand(kongctx *kong.Context, options *Options) error {
 		return options.Workstream.DeleteShortName.Run(options.Ctx, &options.SharedOptions)
 	case "workstream move-short-name":
 		return options.Workstream.MoveShortName.Run(options.Ctx, &options.SharedOptions)
+	case "auth-provider create":
+		return options.AuthProvider.Create.Run(options.Ctx, &options.SharedOptions)
+	case "auth-provider list":
+		return options.AuthProvider.List.Run(options.Ctx, &options.SharedOptions)
+	case "auth-provider get":
+		return options.AuthProvider.Get.Run(options.Ctx, &options.SharedOptions)
+	case "auth-provider update":
+		return options.AuthProvider.Update.Run(options.Ctx, &options.SharedOptions)
+	case "auth-provider delete":
+		return options.AuthProvider.Delete.Run(options.Ctx, &options.SharedOptions)
+	case "auth-provider verify-token":
+		return options.AuthProvider.VerifyToken.Run(options.Ctx, &options.SharedOptions)
 	case "runner create":
 		return options.Runner.Create.Run(options.Ctx, &options.SharedOptions)
 	case "runner list":
@@ -2,8 +2,14 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/plan42-ai/sdk-go/p42"
@@ -18,6 +24,9 @@ type TaskOptions struct {
 	Get            GetTaskOptions            `cmd:"" help:"Get a task by ID."`
 	GetGithubCreds GetTaskGithubCredsOptions `cmd:"" help:"Get GitHub credentials for a task."`
 	Move           MoveTaskOptions           `cmd:"" help:"Move a task from one workstream to another."`
+	Watch          WatchTasksOptions         `cmd:"" help:"Watch the tasks of a tenant or workstream and print an event for each change."`
+	Bulk           BulkTaskOptions           `cmd:"" help:"Move, re-assign, delete or change the model of many tasks at once."`
+	SyncPRs        SyncTaskPRsOptions        `cmd:"" name:"sync-prs" help:"Update the pull request status of tasks awaiting code review from GitHub."`
 }
 
 // MoveTaskOptions contains the flags for the `task move` command.
@@ -163,9 +172,13 @@ type UpdateTaskOptions struct {
 	TaskID       string `help:"The ID of the task to update." name:"task-id" short:"t" required:""`
 	JSON         string `help:"The json file containing the updates." short:"j" default:"-"`
 	WorkstreamID string `help:"The id of the workstream containing the task to update." name:"workstream-id" short:"w" optional:""`
+	Validate     bool   `help:"Check that the state change is a legal task transition before updating. Requires --workstream-id." name:"validate-transition"`
 }
 
 func (o *UpdateTaskOptions) Run(ctx context.Context, s *SharedOptions) error {
+	if o.Validate && o.WorkstreamID == "" {
+		return fmt.Errorf("--validate-transition requires --workstream-id")
+	}
 	if o.WorkstreamID != "" {
 		return o.runWorkstream(ctx, s)
 	}
@@ -240,6 +253,9 @@ func (o *UpdateTaskOptions) runWorkstream(ctx context.Context, s *SharedOptions)
 		return err
 	}
 	req.Version = task.Version
+	if o.Validate {
+		req.CurrentState = &task.State
+	}
 	req.FeatureFlags = getReq.FeatureFlags
 	processDelegatedAuth(s, &req.DelegatedAuthInfo)
 
@@ -309,9 +325,13 @@ type ListTasksOptions struct {
 	IncludeDeleted bool   `help:"When set, includes deleted tasks in the results." short:"d" optional:""`
 	WorkstreamID   string `help:"Optional. When set, list tasks in the specified workstream." name:"workstream-id" short:"w" optional:""`
 	MaxElements    *int   `help:"Maximum number of elements to retrieve" short:"m" optional:""`
+	Filter         string `help:"Optional. Only list tasks matching a filter, e.g. 'state=Pending|Executing,model=O3,createdAt>2025-01-01'. Conditions are comma separated; supported fields are state, model, assignedToAI, assignedToTenantId, workstreamId, lastTurnStatus, createdAt, updatedAt, prStatus, prRepo, taskId, pullRequestId and includeDeleted." short:"f" optional:""`
 }
 
 func (o *ListTasksOptions) Run(ctx context.Context, s *SharedOptions) error {
+	if o.Filter != "" {
+		return o.runQuery(ctx, s)
+	}
 	if o.WorkstreamID != "" {
 		return o.runWorkstreamTasks(ctx, s)
 	}
@@ -362,6 +382,52 @@ func (o *ListTasksOptions) run(ctx context.Context, s *SharedOptions) error {
 	return nil
 }
 
+func (o *ListTasksOptions) runQuery(ctx context.Context, s *SharedOptions) error {
+	query, err := p42.ParseTaskFilter(o.Filter)
+	if err != nil {
+		return err
+	}
+	if o.WorkstreamID != "" {
+		query.WithWorkstreamID(o.WorkstreamID)
+	}
+	if o.IncludeDeleted {
+		query.WithIncludeDeleted(true)
+	}
+
+	req := &p42.QueryTasksRequest{
+		TenantID: o.TenantID,
+		Query:    query,
+		PageSize: 10,
+	}
+	processDelegatedAuth(s, &req.DelegatedAuthInfo)
+	err = loadFeatureFlags(s, &req.FeatureFlags)
+	if err != nil {
+		return err
+	}
+
+	remaining := math.MaxInt
+	if o.MaxElements != nil {
+		remaining = *o.MaxElements
+	}
+	if remaining <= 0 {
+		return nil
+	}
+	for task, err := range p42.QueryTasks(ctx, s.Client, req) {
+		if err != nil {
+			return err
+		}
+		err = printJSON(task)
+		if err != nil {
+			return err
+		}
+		remaining--
+		if remaining == 0 {
+			return nil
+		}
+	}
+	return nil
+}
+
 func (o *ListTasksOptions) runWorkstreamTasks(ctx context.Context, s *SharedOptions) error {
 	req := &p42.ListWorkstreamTasksRequest{
 		TenantID:       o.TenantID,
@@ -409,9 +475,14 @@ func (o *ListTasksOptions) runWorkstreamTasks(ctx context.Context, s *SharedOpti
 }
 
 type SearchTasksOptions struct {
-	PullRequestID int64  `help:"The GitHub pull request ID to search for." name:"pull-request-id" short:"p" optional:""`
-	TaskID        string `help:"The task ID to search for." name:"task-id" short:"t" optional:""`
-	JSON          string `help:"Optional path to the JSON request body. Use '-' to read from stdin." short:"j" optional:""`
+	PullRequestID     int64  `help:"The GitHub pull request ID to search for." name:"pull-request-id" short:"p" optional:""`
+	TaskID            string `help:"The task ID to search for." name:"task-id" short:"t" optional:""`
+	PullRequestURL    string `help:"The GitHub pull request URL to search for, e.g. https://github.com/owner/repo/pull/123. Its ID is looked up on GitHub." name:"pr-url" short:"u" optional:""`
+	Repository        string `help:"The repository of the pull request to search for, in owner/name form. Requires --pr-number." name:"repo" short:"r" optional:""`
+	PullRequestNumber int    `help:"The number of the pull request to search for in --repo. Its ID is looked up on GitHub." name:"pr-number" short:"n" optional:""`
+	GithubTokenFile   string `help:"The file containing the GitHub token to look up pull requests with. Not needed for public repositories." name:"github-token-file" short:"g" optional:""`
+	GithubURL         string `help:"The GitHub API URL." name:"github-url" default:"https://api.github.com"`
+	JSON              string `help:"Optional path to the JSON request body. Use '-' to read from stdin." short:"j" optional:""`
 }
 
 func (o *SearchTasksOptions) Run(ctx context.Context, s *SharedOptions) error {
@@ -424,8 +495,26 @@ func (o *SearchTasksOptions) Run(ctx context.Context, s *SharedOptions) error {
 		req.TaskID = pointer(o.TaskID)
 	}
 
+	criteria, err := o.criteria()
+	if err != nil {
+		return err
+	}
+	if !criteria.IsEmpty() {
+		// The service can only search by pull request ID, so the pull request is looked up on GitHub first. The
+		// criteria still filter the results, so that the matching repos are reported.
+		if req.PullRequestID != nil {
+			return fmt.Errorf("pull-request-id can't be combined with pr-url, repo or pr-number")
+		}
+		id, err := o.resolvePullRequestID(ctx, criteria)
+		if err != nil {
+			return err
+		}
+		req.PullRequestID = pointer(id)
+		req.Criteria = criteria
+	}
+
 	if req.PullRequestID == nil && req.TaskID == nil {
-		return fmt.Errorf("either pull-request-id or task-id must be provided")
+		return fmt.Errorf("one of pull-request-id, task-id, pr-url, or repo and pr-number must be provided")
 	}
 
 	if o.JSON != "" {
@@ -444,13 +533,49 @@ func (o *SearchTasksOptions) Run(ctx context.Context, s *SharedOptions) error {
 	}
 	processDelegatedAuth(s, &req.DelegatedAuthInfo)
 
-	resp, err := s.Client.SearchTasks(ctx, &req)
+	resp, err := s.Client.SearchTasksWithMatches(ctx, &req)
 	if err != nil {
 		return err
 	}
 	return printJSON(resp)
 }
 
+// criteria returns the pull request given by --pr-url, or --repo and --pr-number.
+func (o *SearchTasksOptions) criteria() (*p42.SearchTasksCriteria, error) {
+	criteria := &p42.SearchTasksCriteria{}
+	if o.PullRequestURL != "" {
+		fromURL, err := p42.ParsePullRequestURL(o.PullRequestURL)
+		if err != nil {
+			return nil, err
+		}
+		criteria = fromURL
+	}
+	if o.Repository != "" {
+		criteria.Repository = pointer(o.Repository)
+	}
+	if o.PullRequestNumber != 0 {
+		criteria.PullRequestNumber = pointer(o.PullRequestNumber)
+	}
+	return criteria, nil
+}
+
+// resolvePullRequestID looks up the ID of the pull request on GitHub.
+func (o *SearchTasksOptions) resolvePullRequestID(ctx context.Context, criteria *p42.SearchTasksCriteria) (int64, error) {
+	cfg := &p42.GithubPRStatusFetcherConfig{BaseURL: o.GithubURL}
+	if o.GithubTokenFile != "" {
+		token, err := readGithubToken(o.GithubTokenFile)
+		if err != nil {
+			return 0, err
+		}
+		cfg.Tokens = staticGithubToken(token)
+	}
+	fetcher, err := p42.NewGithubPRStatusFetcher(cfg)
+	if err != nil {
+		return 0, err
+	}
+	return criteria.ResolvePullRequestID(ctx, fetcher)
+}
+
 type GetTaskOptions struct {
 	TenantID       string `help:"The ID of the tenant to list tasks for" short:"i" required:""`
 	TaskID         string `help:"The ID of the task to get" short:"t" required:""`
@@ -530,3 +655,214 @@ func (o *GetTaskGithubCredsOptions) Run(ctx context.Context, s *SharedOptions) e
 	}
 	return printJSON(creds)
 }
+
+type WatchTasksOptions struct {
+	TenantID       string        `help:"The ID of the tenant to watch tasks for." short:"i" required:""`
+	WorkstreamID   *string       `help:"Optional. When set, watch only the tasks in the specified workstream." name:"workstream-id" short:"w" optional:""`
+	IncludeDeleted bool          `help:"When set, deleted tasks are listed, and deletions are reported as they happen." short:"d" optional:""`
+	Interval       time.Duration `help:"How often to list tasks." default:"10s"`
+	Cursor         string        `help:"A file to resume the watch from, and to save the watch's progress to on exit." optional:""`
+	Initial        bool          `help:"Report every existing task as created, unless resuming from --cursor."`
+}
+
+func (o *WatchTasksOptions) Run(ctx context.Context, s *SharedOptions) error {
+	var flags p42.FeatureFlags
+	if err := loadFeatureFlags(s, &flags); err != nil {
+		return err
+	}
+	cfg := &p42.TaskWatcherConfig{
+		Client:         s.Client,
+		TenantID:       o.TenantID,
+		WorkstreamID:   o.WorkstreamID,
+		IncludeDeleted: o.IncludeDeleted,
+		FeatureFlags:   flags.FeatureFlags,
+		Interval:       o.Interval,
+		EmitInitial:    o.Initial,
+		Buffer:         100,
+	}
+	processDelegatedAuth(s, &cfg.DelegatedAuth)
+
+	if o.Cursor != "" {
+		var cursor p42.TaskWatchCursor
+		err := readJsonFile(o.Cursor, &cursor)
+		switch {
+		case err == nil:
+			cfg.Cursor = &cursor
+		case !errors.Is(err, os.ErrNotExist):
+			return err
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+	w := p42.NewTaskWatcher(ctx, cfg)
+	defer w.Close()
+
+	for event := range w.Events() {
+		if err := printJSON(event); err != nil {
+			return err
+		}
+	}
+
+	if o.Cursor != "" {
+		if err := writeJsonFile(o.Cursor, w.Cursor()); err != nil {
+			return err
+		}
+	}
+	if err := w.Err(); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return nil
+}
+
+type BulkTaskOptions struct {
+	TenantID       string   `help:"The ID of the tenant that owns the tasks." short:"i" required:""`
+	TaskIDs        []string `help:"The IDs of the tasks to operate on. If not set, tasks are listed." name:"task-id" short:"t" optional:""`
+	WorkstreamID   *string  `help:"List the tasks of this workstream, instead of every task of the tenant." name:"workstream-id" short:"w" optional:""`
+	State          []string `help:"Only operate on tasks in these states." optional:""`
+	IncludeDeleted bool     `help:"Include deleted tasks when listing tasks." short:"d" optional:""`
+
+	Operation    string  `help:"The operation to apply to each task." enum:"move,assign,delete,set-model" short:"o" required:""`
+	ToWorkstream string  `help:"The workstream to move tasks to, for the move operation." name:"to-workstream-id" optional:""`
+	AssignTo     *string `help:"The tenant to assign tasks to, for the assign operation." name:"assign-to-tenant-id" optional:""`
+	AssignToAI   *bool   `help:"Whether tasks are assigned to AI, for the assign operation." name:"assign-to-ai" optional:""`
+	Model        string  `help:"The model to set, for the set-model operation." optional:""`
+
+	Concurrency int  `help:"The number of tasks to update at once." default:"4"`
+	DryRun      bool `help:"Report what would be done to each task without changing anything." name:"dry-run"`
+}
+
+func (o *BulkTaskOptions) Run(ctx context.Context, s *SharedOptions) error {
+	req := &p42.BulkTaskRequest{
+		TenantID: o.TenantID,
+		Selector: p42.BulkTaskSelector{
+			TaskIDs:        o.TaskIDs,
+			WorkstreamID:   o.WorkstreamID,
+			IncludeDeleted: o.IncludeDeleted,
+		},
+		Concurrency: o.Concurrency,
+		DryRun:      o.DryRun,
+	}
+	if len(o.State) > 0 {
+		req.Selector.Filter = func(task *p42.Task) bool {
+			return slices.Contains(o.State, string(task.State))
+		}
+	}
+
+	switch o.Operation {
+	case "move":
+		req.Operation = p42.BulkTaskOperation{Type: p42.BulkOperationMove, DestinationWorkstreamID: o.ToWorkstream}
+	case "assign":
+		req.Operation = p42.BulkTaskOperation{
+			Type:               p42.BulkOperationAssign,
+			AssignedToTenantID: o.AssignTo,
+			AssignedToAI:       o.AssignToAI,
+		}
+	case "delete":
+		req.Operation = p42.BulkTaskOperation{Type: p42.BulkOperationDelete}
+	case "set-model":
+		req.Operation = p42.BulkTaskOperation{Type: p42.BulkOperationSetModel, Model: p42.ModelType(o.Model)}
+	}
+
+	if err := loadFeatureFlags(s, &req.FeatureFlags); err != nil {
+		return err
+	}
+	processDelegatedAuth(s, &req.DelegatedAuthInfo)
+
+	report, err := p42.BulkUpdateTasks(ctx, s.Client, req)
+	if err != nil {
+		return err
+	}
+	if err := printJSON(report); err != nil {
+		return err
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("%d of %d tasks failed", report.Failed, len(report.Results))
+	}
+	return nil
+}
+
+type SyncTaskPRsOptions struct {
+	TenantID        string        `help:"The ID of the tenant that owns the tasks." short:"i" required:""`
+	WorkstreamID    *string       `help:"Only sync the tasks of this workstream." name:"workstream-id" short:"w" optional:""`
+	GithubTokenFile string        `help:"The file containing the GitHub token to query pull requests with." name:"github-token-file" short:"g" required:""`
+	GithubURL       string        `help:"The GitHub API URL." name:"github-url" default:"https://api.github.com"`
+	CompleteOnMerge bool          `help:"Move workstream tasks to Completed once all of their pull requests are merged." name:"complete-on-merge"`
+	Loop            bool          `help:"Keep syncing every --interval until interrupted, instead of syncing once."`
+	Interval        time.Duration `help:"How often to sync when --loop is set." default:"1m"`
+}
+
+func (o *SyncTaskPRsOptions) Run(ctx context.Context, s *SharedOptions) error {
+	token, err := readGithubToken(o.GithubTokenFile)
+	if err != nil {
+		return err
+	}
+	fetcher, err := p42.NewGithubPRStatusFetcher(
+		&p42.GithubPRStatusFetcherConfig{Tokens: staticGithubToken(token), BaseURL: o.GithubURL},
+	)
+	if err != nil {
+		return err
+	}
+
+	cfg := &p42.PRSyncConfig{
+		Client:          s.Client,
+		TenantID:        o.TenantID,
+		WorkstreamID:    o.WorkstreamID,
+		Fetcher:         fetcher,
+		CompleteOnMerge: o.CompleteOnMerge,
+		Interval:        o.Interval,
+	}
+	if err := loadFeatureFlags(s, &cfg.FeatureFlags); err != nil {
+		return err
+	}
+	processDelegatedAuth(s, &cfg.DelegatedAuth)
+	syncer, err := p42.NewPRSyncer(cfg)
+	if err != nil {
+		return err
+	}
+
+	if o.Loop {
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+		defer stop()
+		err := syncer.Run(
+			ctx, func(report *p42.PRSyncReport) {
+				_ = printJSON(report)
+			},
+		)
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		return err
+	}
+
+	report, err := syncer.SyncOnce(ctx)
+	if err != nil {
+		return err
+	}
+	if err := printJSON(report); err != nil {
+		return err
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("%d of %d tasks failed", report.Failed, len(report.Results))
+	}
+	return nil
+}
+
+// readGithubToken reads a GitHub token from a file.
+func readGithubToken(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("github token is empty")
+	}
+	return token, nil
+}
+
+type staticGithubToken string
+
+func (t staticGithubToken) Token(context.Context) (string, error) {
+	return string(t), nil
+}
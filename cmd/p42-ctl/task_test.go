@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/google/uuid"
@@ -105,6 +106,74 @@ func TestSearchTasksOptionsRunWithBody(t *testing.T) {
 	require.NoError(t, opts.Run(context.Background(), &shared))
 }
 
+func TestSearchTasksOptionsRunWithPullRequestURL(t *testing.T) {
+	t.Parallel()
+	github := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, "Bearer gh-token", r.Header.Get("Authorization"))
+				if r.URL.Path != "/repos/org/repo/pulls/7" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				_, _ = w.Write([]byte(`{"id": 42, "state": "open", "head": {"sha": "abc"}}`))
+			},
+		),
+	)
+	defer github.Close()
+	srv := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, "/v1/tasks/search", r.URL.Path)
+				require.Equal(t, "42", r.URL.Query().Get("pullRequestId"))
+
+				var body map[string]any
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+				require.Empty(t, body)
+
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"Tasks": [], "NextToken": null}`))
+			},
+		),
+	)
+	defer srv.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("gh-token\n"), 0o600))
+	shared := SharedOptions{Client: p42.NewClient(srv.URL)}
+
+	// The pull request's ID is looked up on GitHub, by URL or by repo and number.
+	opts := SearchTasksOptions{
+		PullRequestURL:  "https://github.com/org/repo/pull/7",
+		GithubTokenFile: tokenFile,
+		GithubURL:       github.URL,
+	}
+	require.NoError(t, opts.Run(context.Background(), &shared))
+	opts = SearchTasksOptions{Repository: "org/repo", PullRequestNumber: 7, GithubTokenFile: tokenFile, GithubURL: github.URL}
+	require.NoError(t, opts.Run(context.Background(), &shared))
+
+	tests := []struct {
+		opts SearchTasksOptions
+		msg  string
+	}{
+		{
+			SearchTasksOptions{Repository: "org/repo", GithubURL: github.URL},
+			"repository and pull request number are required to look up a pull request",
+		},
+		{
+			SearchTasksOptions{PullRequestID: 42, Repository: "org/repo", PullRequestNumber: 7},
+			"pull-request-id can't be combined with pr-url, repo or pr-number",
+		},
+		{
+			SearchTasksOptions{Repository: "org/repo", PullRequestNumber: 8, GithubTokenFile: tokenFile, GithubURL: github.URL},
+			"unable to look up pull request org/repo#8: github request failed: status 404: ",
+		},
+	}
+	for _, tc := range tests {
+		require.EqualError(t, tc.opts.Run(context.Background(), &shared), tc.msg)
+	}
+}
+
 func TestSearchTasksOptionsRunWithoutCriteria(t *testing.T) {
 	t.Parallel()
 
@@ -144,3 +213,25 @@ func TestGetTaskGithubCredsOptionsRunRequiresShowSecrets(t *testing.T) {
 
 	require.EqualError(t, opts.Run(context.Background(), &shared), "you must specify `-s` when calling get-github-creds")
 }
+
+func TestListTasksOptionsRunWithFilter(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, http.MethodGet, r.Method)
+				require.Equal(t, "/v1/tenants/tenant/workstreams/ws/tasks", r.URL.Path)
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"Items": [{"TaskId": "a", "State": "Pending"}], "NextToken": null}`))
+			},
+		),
+	)
+	defer srv.Close()
+
+	opts := ListTasksOptions{TenantID: "tenant", WorkstreamID: "ws", Filter: "state=Pending"}
+	shared := SharedOptions{Client: p42.NewClient(srv.URL)}
+	require.NoError(t, opts.Run(context.Background(), &shared))
+
+	opts.Filter = "color=red"
+	require.EqualError(t, opts.Run(context.Background(), &shared), `invalid filter condition "color=red": unknown field color`)
+}
@@ -107,6 +107,7 @@ type UpdateTurnOptions struct {
 	TaskID    string `help:"The ID of the task that contains the turn" short:"t" required:""`
 	TurnIndex int    `help:"The index of the turn to update" name:"turn-index" short:"n" required:""`
 	JSON      string `help:"The json file containing the updates to make" short:"j" default:"-"`
+	Validate  bool   `help:"Check that the status change is a legal turn transition before updating." name:"validate-transition"`
 }
 
 func (o *UpdateTurnOptions) Run(ctx context.Context, s *SharedOptions) error {
@@ -139,6 +140,9 @@ func (o *UpdateTurnOptions) Run(ctx context.Context, s *SharedOptions) error {
 		return err
 	}
 	req.Version = turn.Version
+	if o.Validate {
+		req.CurrentStatus = &turn.Status
+	}
 	req.FeatureFlags = getReq.FeatureFlags
 	processDelegatedAuth(s, &req.DelegatedAuthInfo)
 
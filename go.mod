@@ -14,6 +14,8 @@ require (
 	github.com/plan42-ai/concurrency v1.0.3
 	github.com/plan42-ai/ecies v1.0.3
 	github.com/plan42-ai/sigv4util v1.0.3
+	golang.org/x/term v0.38.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -32,5 +34,5 @@ require (
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/scottwis/persistent v1.0.8 // indirect
 	golang.org/x/exp v0.0.0-20251219203646-944ab1f22d93 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	golang.org/x/sys v0.39.0 // indirect
 )
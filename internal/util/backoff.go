@@ -41,14 +41,22 @@ func (b *Backoff) setupTimer(d time.Duration) *time.Timer {
 	return b.timer
 }
 
+// Jitter returns a random duration less than or equal to the current backoff. It is the duration Wait would sleep,
+// for callers that need to schedule the wait themselves.
+func (b *Backoff) Jitter() time.Duration {
+	if b.current == 0 {
+		return 0
+	}
+	return rand.N[time.Duration](b.current)
+}
+
 // Wait sleeps for a random duration less than or equal to the current backoff.
 // If the backoff is zero, Wait returns immediately.
 func (b *Backoff) Wait(ctx context.Context) error {
 	if b.current == 0 {
 		return nil
 	}
-	d := rand.N[time.Duration](b.current)
-	t := b.setupTimer(d)
+	t := b.setupTimer(b.Jitter())
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
@@ -85,3 +85,18 @@ func TestBackoffWaitAtLeast(t *testing.T) {
 		t.Fatalf("wait exceeded expected maximum")
 	}
 }
+
+func TestBackoffJitter(t *testing.T) {
+	b := util.NewBackoff(5*time.Millisecond, 20*time.Millisecond)
+	if d := b.Jitter(); d != 0 {
+		t.Fatalf("expected zero jitter with zero backoff, got %v", d)
+	}
+
+	b.Backoff()
+	b.Backoff() // 10ms
+	for range 100 {
+		if d := b.Jitter(); d < 0 || d > 10*time.Millisecond {
+			t.Fatalf("jitter %v outside [0, 10ms]", d)
+		}
+	}
+}
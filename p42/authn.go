@@ -1,12 +1,22 @@
 package p42
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
 	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// AuthenticationProvider is an external identity provider whose tokens a tenant accepts as Auth Provider tokens.
+//
+// The auth provider APIs are proposed (see API.md sections 97 to 101). The service does not implement them yet, so
+// the Client methods that call them fail until it does, and the contract may change.
 type AuthenticationProvider struct {
 	TenantID   string
 	ProviderID uuid.UUID
@@ -15,5 +25,519 @@ type AuthenticationProvider struct {
 	Audience   string
 	CreatedAt  time.Time
 	UpdatedAt  time.Time
+	Deleted    bool
 	Version    int
 }
+
+// authenticationProviderJSON is the wire format of AuthenticationProvider, with Issuer as a string.
+type authenticationProviderJSON struct {
+	TenantID   string    `json:"TenantId"`
+	ProviderID uuid.UUID `json:"ProviderId"`
+	Name       string    `json:"Name"`
+	Issuer     string    `json:"Issuer"`
+	Audience   string    `json:"Audience"`
+	CreatedAt  time.Time `json:"CreatedAt"`
+	UpdatedAt  time.Time `json:"UpdatedAt"`
+	Deleted    bool      `json:"Deleted"`
+	Version    int       `json:"Version"`
+}
+
+// ObjectType returns the object type for ConflictError handling.
+func (AuthenticationProvider) ObjectType() ObjectType { return ObjectTypeAuthenticationProvider }
+
+func (p AuthenticationProvider) MarshalJSON() ([]byte, error) {
+	tmp := authenticationProviderJSON{
+		TenantID:   p.TenantID,
+		ProviderID: p.ProviderID,
+		Name:       p.Name,
+		Audience:   p.Audience,
+		CreatedAt:  p.CreatedAt,
+		UpdatedAt:  p.UpdatedAt,
+		Deleted:    p.Deleted,
+		Version:    p.Version,
+	}
+	if p.Issuer != nil {
+		tmp.Issuer = p.Issuer.String()
+	}
+	return json.Marshal(tmp)
+}
+
+func (p *AuthenticationProvider) UnmarshalJSON(b []byte) error {
+	var tmp authenticationProviderJSON
+	if err := json.Unmarshal(b, &tmp); err != nil {
+		return err
+	}
+	var issuer *url.URL
+	if tmp.Issuer != "" {
+		var err error
+		issuer, err = url.Parse(tmp.Issuer)
+		if err != nil {
+			return fmt.Errorf("invalid issuer: %w", err)
+		}
+	}
+	*p = AuthenticationProvider{
+		TenantID:   tmp.TenantID,
+		ProviderID: tmp.ProviderID,
+		Name:       tmp.Name,
+		Issuer:     issuer,
+		Audience:   tmp.Audience,
+		CreatedAt:  tmp.CreatedAt,
+		UpdatedAt:  tmp.UpdatedAt,
+		Deleted:    tmp.Deleted,
+		Version:    tmp.Version,
+	}
+	return nil
+}
+
+func validateIssuer(issuer string) error {
+	u, err := url.Parse(issuer)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("issuer must be an https url")
+	}
+	return nil
+}
+
+// CreateAuthProviderRequest is the request payload for CreateAuthProvider.
+type CreateAuthProviderRequest struct {
+	FeatureFlags
+	DelegatedAuthInfo
+
+	TenantID   string `json:"-"`
+	ProviderID string `json:"-"`
+
+	Name     string `json:"Name"`
+	Issuer   string `json:"Issuer"`
+	Audience string `json:"Audience"`
+}
+
+// GetField retrieves the value of a field by name.
+// nolint: goconst
+func (r *CreateAuthProviderRequest) GetField(name string) (any, bool) {
+	switch name {
+	case "TenantID":
+		return r.TenantID, true
+	case "ProviderID":
+		return r.ProviderID, true
+	case "Name":
+		return r.Name, true
+	case "Issuer":
+		return r.Issuer, true
+	case "Audience":
+		return r.Audience, true
+	default:
+		return nil, false
+	}
+}
+
+// CreateAuthProvider creates an authentication provider for a tenant.
+// This is a proposed API; see AuthenticationProvider.
+// nolint: dupl
+func (c *Client) CreateAuthProvider(
+	ctx context.Context,
+	req *CreateAuthProviderRequest,
+) (*AuthenticationProvider, error) {
+	if req == nil {
+		return nil, fmt.Errorf("req is nil")
+	}
+	if req.TenantID == "" {
+		return nil, fmt.Errorf("tenant id is required")
+	}
+	if _, err := uuid.Parse(req.ProviderID); err != nil {
+		return nil, fmt.Errorf("provider id must be a valid uuid")
+	}
+	if err := validateIssuer(req.Issuer); err != nil {
+		return nil, err
+	}
+	if req.Audience == "" {
+		return nil, fmt.Errorf("audience is required")
+	}
+
+	bodyBytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	u := c.BaseURL.JoinPath(
+		"v1",
+		"tenants",
+		url.PathEscape(req.TenantID),
+		"auth-providers",
+		url.PathEscape(req.ProviderID),
+	)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	httpReq.Header.Set("Content-Type", "application/json")
+	processFeatureFlags(httpReq, req.FeatureFlags)
+
+	if err := c.authenticate(req.DelegatedAuthInfo, httpReq); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient().Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return nil, decodeError(resp)
+	}
+
+	var provider AuthenticationProvider
+	if err := json.NewDecoder(resp.Body).Decode(&provider); err != nil {
+		return nil, err
+	}
+	return &provider, nil
+}
+
+// GetAuthProviderRequest is the request payload for GetAuthProvider.
+type GetAuthProviderRequest struct {
+	FeatureFlags
+	DelegatedAuthInfo
+
+	TenantID       string `json:"-"`
+	ProviderID     string `json:"-"`
+	IncludeDeleted *bool  `json:"-"`
+}
+
+// GetField retrieves the value of a field by name.
+// nolint: goconst
+func (r *GetAuthProviderRequest) GetField(name string) (any, bool) {
+	switch name {
+	case "TenantID":
+		return r.TenantID, true
+	case "ProviderID":
+		return r.ProviderID, true
+	case "IncludeDeleted":
+		return EvalNullable(r.IncludeDeleted)
+	default:
+		return nil, false
+	}
+}
+
+// GetAuthProvider retrieves an authentication provider by ID.
+// This is a proposed API; see AuthenticationProvider.
+// nolint: dupl
+func (c *Client) GetAuthProvider(
+	ctx context.Context,
+	req *GetAuthProviderRequest,
+) (*AuthenticationProvider, error) {
+	if req == nil {
+		return nil, fmt.Errorf("req is nil")
+	}
+	if req.TenantID == "" {
+		return nil, fmt.Errorf("tenant id is required")
+	}
+	if req.ProviderID == "" {
+		return nil, fmt.Errorf("provider id is required")
+	}
+
+	u := c.BaseURL.JoinPath(
+		"v1",
+		"tenants",
+		url.PathEscape(req.TenantID),
+		"auth-providers",
+		url.PathEscape(req.ProviderID),
+	)
+	q := u.Query()
+	if req.IncludeDeleted != nil {
+		q.Set("includeDeleted", strconv.FormatBool(*req.IncludeDeleted))
+	}
+	u.RawQuery = q.Encode()
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	processFeatureFlags(httpReq, req.FeatureFlags)
+
+	if err := c.authenticate(req.DelegatedAuthInfo, httpReq); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient().Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeError(resp)
+	}
+
+	var provider AuthenticationProvider
+	if err := json.NewDecoder(resp.Body).Decode(&provider); err != nil {
+		return nil, err
+	}
+	return &provider, nil
+}
+
+// ListAuthProvidersRequest is the request payload for ListAuthProviders.
+type ListAuthProvidersRequest struct {
+	FeatureFlags
+	DelegatedAuthInfo
+
+	TenantID       string
+	MaxResults     *int
+	Token          *string
+	IncludeDeleted *bool
+}
+
+// GetField retrieves the value of a field by name.
+// nolint: goconst
+func (r *ListAuthProvidersRequest) GetField(name string) (any, bool) {
+	switch name {
+	case "TenantID":
+		return r.TenantID, true
+	case "MaxResults":
+		return EvalNullable(r.MaxResults)
+	case "Token":
+		return EvalNullable(r.Token)
+	case "IncludeDeleted":
+		return EvalNullable(r.IncludeDeleted)
+	default:
+		return nil, false
+	}
+}
+
+// ListAuthProviders lists the authentication providers for a tenant.
+// This is a proposed API; see AuthenticationProvider.
+// nolint: dupl
+func (c *Client) ListAuthProviders(
+	ctx context.Context,
+	req *ListAuthProvidersRequest,
+) (*List[*AuthenticationProvider], error) {
+	if req == nil {
+		return nil, fmt.Errorf("req is nil")
+	}
+	if req.TenantID == "" {
+		return nil, fmt.Errorf("tenant id is required")
+	}
+
+	u := c.BaseURL.JoinPath("v1", "tenants", url.PathEscape(req.TenantID), "auth-providers")
+	q := u.Query()
+	if req.MaxResults != nil {
+		q.Set("maxResults", strconv.Itoa(*req.MaxResults))
+	}
+	if req.Token != nil {
+		q.Set("token", *req.Token)
+	}
+	if req.IncludeDeleted != nil {
+		q.Set("includeDeleted", strconv.FormatBool(*req.IncludeDeleted))
+	}
+	u.RawQuery = q.Encode()
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	processFeatureFlags(httpReq, req.FeatureFlags)
+
+	if err := c.authenticate(req.DelegatedAuthInfo, httpReq); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient().Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeError(resp)
+	}
+
+	var out List[*AuthenticationProvider]
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateAuthProviderRequest is the request payload for UpdateAuthProvider.
+type UpdateAuthProviderRequest struct {
+	FeatureFlags
+	DelegatedAuthInfo
+
+	TenantID   string `json:"-"`
+	ProviderID string `json:"-"`
+	Version    int    `json:"-"`
+
+	Name     *string `json:"Name,omitempty"`
+	Issuer   *string `json:"Issuer,omitempty"`
+	Audience *string `json:"Audience,omitempty"`
+	Deleted  *bool   `json:"Deleted,omitempty"`
+}
+
+func (r *UpdateAuthProviderRequest) GetVersion() int {
+	return r.Version
+}
+
+// GetField retrieves the value of a field by name.
+// nolint: goconst
+func (r *UpdateAuthProviderRequest) GetField(name string) (any, bool) {
+	switch name {
+	case "TenantID":
+		return r.TenantID, true
+	case "ProviderID":
+		return r.ProviderID, true
+	case "Version":
+		return r.Version, true
+	case "Name":
+		return EvalNullable(r.Name)
+	case "Issuer":
+		return EvalNullable(r.Issuer)
+	case "Audience":
+		return EvalNullable(r.Audience)
+	case "Deleted":
+		return EvalNullable(r.Deleted)
+	default:
+		return nil, false
+	}
+}
+
+// UpdateAuthProvider updates an authentication provider.
+// This is a proposed API; see AuthenticationProvider.
+// nolint: dupl
+func (c *Client) UpdateAuthProvider(
+	ctx context.Context,
+	req *UpdateAuthProviderRequest,
+) (*AuthenticationProvider, error) {
+	if req == nil {
+		return nil, fmt.Errorf("req is nil")
+	}
+	if req.TenantID == "" {
+		return nil, fmt.Errorf("tenant id is required")
+	}
+	if req.ProviderID == "" {
+		return nil, fmt.Errorf("provider id is required")
+	}
+	if req.Issuer != nil {
+		if err := validateIssuer(*req.Issuer); err != nil {
+			return nil, err
+		}
+	}
+	if req.Audience != nil && *req.Audience == "" {
+		return nil, fmt.Errorf("audience must not be empty")
+	}
+
+	bodyBytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	u := c.BaseURL.JoinPath(
+		"v1",
+		"tenants",
+		url.PathEscape(req.TenantID),
+		"auth-providers",
+		url.PathEscape(req.ProviderID),
+	)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPatch, u.String(), bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("If-Match", strconv.Itoa(req.Version))
+	processFeatureFlags(httpReq, req.FeatureFlags)
+
+	if err := c.authenticate(req.DelegatedAuthInfo, httpReq); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient().Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeError(resp)
+	}
+
+	var provider AuthenticationProvider
+	if err := json.NewDecoder(resp.Body).Decode(&provider); err != nil {
+		return nil, err
+	}
+	return &provider, nil
+}
+
+// DeleteAuthProviderRequest is the request payload for DeleteAuthProvider.
+type DeleteAuthProviderRequest struct {
+	FeatureFlags
+	DelegatedAuthInfo
+
+	TenantID   string `json:"-"`
+	ProviderID string `json:"-"`
+	Version    int    `json:"-"`
+}
+
+func (r *DeleteAuthProviderRequest) GetVersion() int {
+	return r.Version
+}
+
+// GetField retrieves the value of a field by name.
+// nolint: goconst
+func (r *DeleteAuthProviderRequest) GetField(name string) (any, bool) {
+	switch name {
+	case "TenantID":
+		return r.TenantID, true
+	case "ProviderID":
+		return r.ProviderID, true
+	case "Version":
+		return r.Version, true
+	default:
+		return nil, false
+	}
+}
+
+// DeleteAuthProvider soft deletes an authentication provider.
+// This is a proposed API; see AuthenticationProvider.
+// nolint: dupl
+func (c *Client) DeleteAuthProvider(ctx context.Context, req *DeleteAuthProviderRequest) error {
+	if req == nil {
+		return fmt.Errorf("req is nil")
+	}
+	if req.TenantID == "" {
+		return fmt.Errorf("tenant id is required")
+	}
+	if req.ProviderID == "" {
+		return fmt.Errorf("provider id is required")
+	}
+
+	u := c.BaseURL.JoinPath(
+		"v1",
+		"tenants",
+		url.PathEscape(req.TenantID),
+		"auth-providers",
+		url.PathEscape(req.ProviderID),
+	)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodDelete, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	httpReq.Header.Set("If-Match", strconv.Itoa(req.Version))
+	processFeatureFlags(httpReq, req.FeatureFlags)
+
+	if err := c.authenticate(req.DelegatedAuthInfo, httpReq); err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient().Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return decodeError(resp)
+	}
+	return nil
+}
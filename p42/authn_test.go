@@ -0,0 +1,276 @@
+package p42_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/plan42-ai/sdk-go/internal/util"
+	"github.com/plan42-ai/sdk-go/p42"
+	"github.com/stretchr/testify/require"
+)
+
+func testAuthProvider(t *testing.T, providerID uuid.UUID, version int) p42.AuthenticationProvider {
+	t.Helper()
+	issuer, err := url.Parse("https://idp.example.com")
+	require.NoError(t, err)
+	now := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	return p42.AuthenticationProvider{
+		TenantID:   "abc",
+		ProviderID: providerID,
+		Name:       "idp",
+		Issuer:     issuer,
+		Audience:   "plan42",
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		Version:    version,
+	}
+}
+
+func TestCreateAuthProvider(t *testing.T) {
+	t.Parallel()
+	providerID := uuid.New()
+
+	handler := http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, http.MethodPut, r.Method)
+			require.Equal(t, "/v1/tenants/abc/auth-providers/"+providerID.String(), r.URL.Path)
+
+			var body map[string]any
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			require.Equal(t, map[string]any{"Name": "idp", "Issuer": "https://idp.example.com", "Audience": "plan42"}, body)
+
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(testAuthProvider(t, providerID, 1))
+		},
+	)
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := p42.NewClient(srv.URL)
+	provider, err := client.CreateAuthProvider(
+		context.Background(), &p42.CreateAuthProviderRequest{
+			TenantID:   "abc",
+			ProviderID: providerID.String(),
+			Name:       "idp",
+			Issuer:     "https://idp.example.com",
+			Audience:   "plan42",
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, testAuthProvider(t, providerID, 1), *provider)
+}
+
+func TestCreateAuthProviderValidation(t *testing.T) {
+	t.Parallel()
+	client := p42.NewClient("http://localhost")
+	providerID := uuid.NewString()
+
+	tests := []struct {
+		req *p42.CreateAuthProviderRequest
+		msg string
+	}{
+		{nil, "req is nil"},
+		{&p42.CreateAuthProviderRequest{ProviderID: providerID}, "tenant id is required"},
+		{&p42.CreateAuthProviderRequest{TenantID: "abc"}, "provider id must be a valid uuid"},
+		{&p42.CreateAuthProviderRequest{TenantID: "abc", ProviderID: "idp"}, "provider id must be a valid uuid"},
+		{&p42.CreateAuthProviderRequest{TenantID: "abc", ProviderID: providerID, Issuer: "idp.example.com"}, "issuer must be an https url"},
+		{&p42.CreateAuthProviderRequest{TenantID: "abc", ProviderID: providerID, Issuer: "http://idp.example.com"}, "issuer must be an https url"},
+		{&p42.CreateAuthProviderRequest{TenantID: "abc", ProviderID: providerID, Issuer: "https://idp.example.com"}, "audience is required"},
+	}
+	for _, tc := range tests {
+		_, err := client.CreateAuthProvider(context.Background(), tc.req)
+		require.EqualError(t, err, tc.msg)
+	}
+}
+
+func TestCreateAuthProviderConflictError(t *testing.T) {
+	t.Parallel()
+	providerID := uuid.New()
+
+	handler := http.HandlerFunc(
+		func(w http.ResponseWriter, _ *http.Request) {
+			current := testAuthProvider(t, providerID, 3)
+			w.WriteHeader(http.StatusConflict)
+			_ = json.NewEncoder(w).Encode(
+				p42.ConflictError{
+					ResponseCode: http.StatusConflict,
+					Message:      "exists",
+					ErrorType:    "Conflict",
+					Current:      &current,
+				},
+			)
+		},
+	)
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := p42.NewClient(srv.URL)
+	_, err := client.CreateAuthProvider(
+		context.Background(), &p42.CreateAuthProviderRequest{
+			TenantID:   "abc",
+			ProviderID: providerID.String(),
+			Issuer:     "https://idp.example.com",
+			Audience:   "plan42",
+		},
+	)
+	var clientErr *p42.ConflictError
+	require.ErrorAs(t, err, &clientErr)
+	require.Equal(t, p42.ObjectTypeAuthenticationProvider, clientErr.Current.ObjectType())
+	provider, ok := clientErr.Current.(*p42.AuthenticationProvider)
+	require.True(t, ok, "Expected Current to be of type *p42.AuthenticationProvider")
+	require.Equal(t, 3, provider.Version)
+	require.Equal(t, "https://idp.example.com", provider.Issuer.String())
+}
+
+func TestGetAuthProvider(t *testing.T) {
+	t.Parallel()
+	providerID := uuid.New()
+
+	handler := http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, http.MethodGet, r.Method)
+			require.Equal(t, "/v1/tenants/"+tenantIDThatNeedsEscaping+"/auth-providers/"+providerID.String(), r.URL.Path)
+			require.Equal(t, "/v1/tenants/"+escapedTenantID+"/auth-providers/"+providerID.String(), r.URL.EscapedPath())
+			require.Equal(t, "true", r.URL.Query().Get("includeDeleted"))
+			_ = json.NewEncoder(w).Encode(testAuthProvider(t, providerID, 2))
+		},
+	)
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := p42.NewClient(srv.URL)
+	provider, err := client.GetAuthProvider(
+		context.Background(), &p42.GetAuthProviderRequest{
+			TenantID:       tenantIDThatNeedsEscaping,
+			ProviderID:     providerID.String(),
+			IncludeDeleted: util.Pointer(true),
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, 2, provider.Version)
+}
+
+func TestListAuthProviders(t *testing.T) {
+	t.Parallel()
+	providerID := uuid.New()
+
+	handler := http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, http.MethodGet, r.Method)
+			require.Equal(t, "/v1/tenants/abc/auth-providers", r.URL.Path)
+			require.Equal(t, "10", r.URL.Query().Get("maxResults"))
+			require.Equal(t, "next", r.URL.Query().Get("token"))
+			_ = json.NewEncoder(w).Encode(
+				p42.List[*p42.AuthenticationProvider]{
+					Items: []*p42.AuthenticationProvider{util.Pointer(testAuthProvider(t, providerID, 1))},
+				},
+			)
+		},
+	)
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := p42.NewClient(srv.URL)
+	resp, err := client.ListAuthProviders(
+		context.Background(), &p42.ListAuthProvidersRequest{
+			TenantID:   "abc",
+			MaxResults: util.Pointer(10),
+			Token:      util.Pointer("next"),
+		},
+	)
+	require.NoError(t, err)
+	require.Len(t, resp.Items, 1)
+	require.Equal(t, providerID, resp.Items[0].ProviderID)
+	require.Nil(t, resp.NextToken)
+}
+
+func TestUpdateAuthProvider(t *testing.T) {
+	t.Parallel()
+	providerID := uuid.New()
+
+	handler := http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, http.MethodPatch, r.Method)
+			require.Equal(t, "/v1/tenants/abc/auth-providers/"+providerID.String(), r.URL.Path)
+			require.Equal(t, "2", r.Header.Get("If-Match"))
+
+			var body map[string]any
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			require.Equal(t, map[string]any{"Audience": "other"}, body)
+
+			provider := testAuthProvider(t, providerID, 3)
+			provider.Audience = "other"
+			_ = json.NewEncoder(w).Encode(provider)
+		},
+	)
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := p42.NewClient(srv.URL)
+	provider, err := client.UpdateAuthProvider(
+		context.Background(), &p42.UpdateAuthProviderRequest{
+			TenantID:   "abc",
+			ProviderID: providerID.String(),
+			Version:    2,
+			Audience:   util.Pointer("other"),
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, "other", provider.Audience)
+	require.Equal(t, 3, provider.Version)
+
+	_, err = client.UpdateAuthProvider(
+		context.Background(), &p42.UpdateAuthProviderRequest{
+			TenantID:   "abc",
+			ProviderID: providerID.String(),
+			Issuer:     util.Pointer("ftp://idp.example.com"),
+		},
+	)
+	require.EqualError(t, err, "issuer must be an https url")
+}
+
+func TestDeleteAuthProvider(t *testing.T) {
+	t.Parallel()
+	providerID := uuid.New()
+
+	handler := http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, http.MethodDelete, r.Method)
+			require.Equal(t, "/v1/tenants/abc/auth-providers/"+providerID.String(), r.URL.Path)
+			require.Equal(t, "4", r.Header.Get("If-Match"))
+			w.WriteHeader(http.StatusNoContent)
+		},
+	)
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := p42.NewClient(srv.URL)
+	err := client.DeleteAuthProvider(
+		context.Background(), &p42.DeleteAuthProviderRequest{
+			TenantID:   "abc",
+			ProviderID: providerID.String(),
+			Version:    4,
+		},
+	)
+	require.NoError(t, err)
+}
+
+func TestDeleteAuthProviderError(t *testing.T) {
+	t.Parallel()
+	srv, client := serveBadRequest()
+	defer srv.Close()
+
+	err := client.DeleteAuthProvider(
+		context.Background(),
+		&p42.DeleteAuthProviderRequest{TenantID: "abc", ProviderID: uuid.NewString(), Version: 1},
+	)
+	var clientErr *p42.Error
+	require.ErrorAs(t, err, &clientErr)
+	require.Equal(t, http.StatusBadRequest, clientErr.ResponseCode)
+}
@@ -0,0 +1,465 @@
+package p42
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/plan42-ai/sdk-go/internal/util"
+)
+
+// BulkOperationType identifies the change BulkUpdateTasks makes to each task.
+type BulkOperationType string
+
+const (
+	// BulkOperationMove moves each task to BulkTaskOperation.DestinationWorkstreamID.
+	BulkOperationMove BulkOperationType = "Move"
+
+	// BulkOperationAssign sets the assignee of each task. Only workstream tasks can be re-assigned.
+	BulkOperationAssign BulkOperationType = "Assign"
+
+	// BulkOperationDelete soft deletes each task.
+	BulkOperationDelete BulkOperationType = "Delete"
+
+	// BulkOperationSetModel sets the model of each task.
+	BulkOperationSetModel BulkOperationType = "SetModel"
+)
+
+// BulkTaskOperation is the change BulkUpdateTasks makes to each selected task.
+type BulkTaskOperation struct {
+	Type BulkOperationType
+
+	// DestinationWorkstreamID is the workstream to move tasks to, for BulkOperationMove.
+	DestinationWorkstreamID string
+
+	// AssignedToTenantID and AssignedToAI are the new assignee, for BulkOperationAssign. Nil fields are left
+	// unchanged.
+	AssignedToTenantID *string
+	AssignedToAI       *bool
+
+	// Model is the new model, for BulkOperationSetModel.
+	Model ModelType
+}
+
+// BulkTaskSelector selects the tasks BulkUpdateTasks operates on. If TaskIDs is set, those tasks are selected.
+// Otherwise the tasks of WorkstreamID, or of the whole tenant if it is nil, are listed. In both cases, Filter (if set)
+// narrows the selection.
+type BulkTaskSelector struct {
+	TaskIDs        []string
+	WorkstreamID   *string
+	Filter         func(task *Task) bool
+	IncludeDeleted bool
+}
+
+// BulkTaskRequest is the request payload for BulkUpdateTasks.
+type BulkTaskRequest struct {
+	FeatureFlags
+	DelegatedAuthInfo
+	TenantID  string
+	Selector  BulkTaskSelector
+	Operation BulkTaskOperation
+
+	// Concurrency is the number of tasks updated at once. Defaults to 4.
+	Concurrency int
+
+	// MaxConflictRetries is how many times an update that fails with a version conflict is retried, with fresh
+	// versions, before the task is reported as failed. Defaults to 5.
+	MaxConflictRetries int
+
+	// DryRun reports what would be done to each task without changing anything.
+	DryRun bool
+}
+
+// BulkTaskStatus is the outcome of a bulk operation on a single task.
+type BulkTaskStatus string
+
+const (
+	BulkTaskSucceeded BulkTaskStatus = "Succeeded"
+	BulkTaskFailed    BulkTaskStatus = "Failed"
+
+	// BulkTaskSkipped means the task already had the requested change.
+	BulkTaskSkipped BulkTaskStatus = "Skipped"
+
+	// BulkTaskWouldUpdate means a dry run found the task would be changed.
+	BulkTaskWouldUpdate BulkTaskStatus = "WouldUpdate"
+)
+
+// BulkTaskResult is the outcome of a bulk operation on a single task.
+type BulkTaskResult struct {
+	TaskID string         `json:"TaskId"`
+	Status BulkTaskStatus `json:"Status"`
+
+	// Task is the task after the operation. For deleted and skipped tasks and dry runs, it is the task as it was
+	// found.
+	Task *Task `json:"Task,omitempty"`
+
+	// Attempts is the number of times the update was sent.
+	Attempts int `json:"Attempts"`
+
+	Err          error  `json:"-"`
+	ErrorMessage string `json:"Error,omitempty"`
+}
+
+// BulkTaskReport is the result of BulkUpdateTasks. Results are in selection order.
+type BulkTaskReport struct {
+	Results   []BulkTaskResult `json:"Results"`
+	Succeeded int              `json:"Succeeded"`
+	Failed    int              `json:"Failed"`
+	Skipped   int              `json:"Skipped"`
+}
+
+const (
+	defaultBulkConcurrency        = 4
+	defaultBulkMaxConflictRetries = 5
+)
+
+// BulkUpdateTasks applies an operation to every selected task. Tasks are updated by a pool of workers; a failure on
+// one task doesn't stop the others. The returned error is only set if the selection itself fails; per-task failures
+// are reported in the BulkTaskReport.
+func BulkUpdateTasks(ctx context.Context, client *Client, req *BulkTaskRequest) (*BulkTaskReport, error) {
+	if req == nil {
+		return nil, fmt.Errorf("req is nil")
+	}
+	if req.TenantID == "" {
+		return nil, fmt.Errorf("tenant id is required")
+	}
+	if err := req.Operation.validate(); err != nil {
+		return nil, err
+	}
+	sel := &req.Selector
+	if len(sel.TaskIDs) == 0 && sel.WorkstreamID == nil && sel.Filter == nil {
+		return nil, fmt.Errorf("task ids, workstream id or filter is required")
+	}
+
+	b := &bulkRun{client: client, req: req}
+	tasks, err := b.selectTasks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	concurrency := req.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBulkConcurrency
+	}
+	report := &BulkTaskReport{Results: make([]BulkTaskResult, len(tasks))}
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for range min(concurrency, len(tasks)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				report.Results[i] = b.apply(ctx, tasks[i])
+			}
+		}()
+	}
+	for i := range tasks {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	for _, result := range report.Results {
+		switch result.Status {
+		case BulkTaskSucceeded:
+			report.Succeeded++
+		case BulkTaskFailed:
+			report.Failed++
+		case BulkTaskSkipped:
+			report.Skipped++
+		}
+	}
+	return report, nil
+}
+
+func (op *BulkTaskOperation) validate() error {
+	switch op.Type {
+	case BulkOperationMove:
+		if op.DestinationWorkstreamID == "" {
+			return fmt.Errorf("destination workstream id is required")
+		}
+	case BulkOperationAssign:
+		if op.AssignedToTenantID == nil && op.AssignedToAI == nil {
+			return fmt.Errorf("assigned to tenant id or assigned to ai is required")
+		}
+	case BulkOperationSetModel:
+		if op.Model == "" {
+			return fmt.Errorf("model is required")
+		}
+	case BulkOperationDelete:
+	default:
+		return fmt.Errorf("unsupported bulk operation %q", op.Type)
+	}
+	return nil
+}
+
+type bulkRun struct {
+	client *Client
+	req    *BulkTaskRequest
+}
+
+// selectedTask is a task chosen by the selector. Task is nil if it was selected by id and hasn't been fetched yet.
+type selectedTask struct {
+	id   string
+	task *Task
+}
+
+func (b *bulkRun) selectTasks(ctx context.Context) ([]selectedTask, error) {
+	sel := &b.req.Selector
+	var tasks []selectedTask
+	if len(sel.TaskIDs) > 0 {
+		for _, id := range sel.TaskIDs {
+			tasks = append(tasks, selectedTask{id: id})
+		}
+		return tasks, nil
+	}
+
+	var token *string
+	for {
+		var page []Task
+		var next *string
+		if sel.WorkstreamID != nil {
+			resp, err := b.client.ListWorkstreamTasks(
+				ctx, &ListWorkstreamTasksRequest{
+					FeatureFlags:      b.req.FeatureFlags,
+					DelegatedAuthInfo: b.req.DelegatedAuthInfo,
+					TenantID:          b.req.TenantID,
+					WorkstreamID:      *sel.WorkstreamID,
+					Token:             token,
+					IncludeDeleted:    util.Pointer(sel.IncludeDeleted),
+				},
+			)
+			if err != nil {
+				return nil, err
+			}
+			page, next = resp.Items, resp.NextToken
+		} else {
+			resp, err := b.client.ListTasks(
+				ctx, &ListTasksRequest{
+					FeatureFlags:      b.req.FeatureFlags,
+					DelegatedAuthInfo: b.req.DelegatedAuthInfo,
+					TenantID:          b.req.TenantID,
+					Token:             token,
+					IncludeDeleted:    util.Pointer(sel.IncludeDeleted),
+				},
+			)
+			if err != nil {
+				return nil, err
+			}
+			page, next = resp.Tasks, resp.NextToken
+		}
+		for i := range page {
+			if sel.Filter == nil || sel.Filter(&page[i]) {
+				tasks = append(tasks, selectedTask{id: page[i].TaskID, task: &page[i]})
+			}
+		}
+		if next == nil {
+			return tasks, nil
+		}
+		token = next
+	}
+}
+
+func (b *bulkRun) getTask(ctx context.Context, taskID string) (*Task, error) {
+	return b.client.GetTask(
+		ctx, &GetTaskRequest{
+			FeatureFlags:      b.req.FeatureFlags,
+			DelegatedAuthInfo: b.req.DelegatedAuthInfo,
+			TenantID:          b.req.TenantID,
+			TaskID:            taskID,
+			IncludeDeleted:    util.Pointer(true),
+		},
+	)
+}
+
+// apply runs the operation on a single task, refetching the task and retrying on version conflicts.
+func (b *bulkRun) apply(ctx context.Context, sel selectedTask) BulkTaskResult {
+	result := BulkTaskResult{TaskID: sel.id}
+	failed := func(err error) BulkTaskResult {
+		result.Status = BulkTaskFailed
+		result.Err = err
+		result.ErrorMessage = err.Error()
+		return result
+	}
+
+	maxRetries := b.req.MaxConflictRetries
+	if maxRetries <= 0 {
+		maxRetries = defaultBulkMaxConflictRetries
+	}
+	backoff := util.NewBackoff(50*time.Millisecond, 2*time.Second)
+
+	task := sel.task
+	for {
+		if task == nil {
+			var err error
+			if task, err = b.getTask(ctx, sel.id); err != nil {
+				return failed(err)
+			}
+		}
+		result.Task = task
+
+		// The selection may be stale, so filters are re-checked against fetched tasks as well.
+		if b.req.Selector.Filter != nil && task != sel.task && !b.req.Selector.Filter(task) {
+			result.Status = BulkTaskSkipped
+			return result
+		}
+		if done, err := b.alreadyApplied(task); err != nil {
+			return failed(err)
+		} else if done {
+			result.Status = BulkTaskSkipped
+			return result
+		}
+		if b.req.DryRun {
+			result.Status = BulkTaskWouldUpdate
+			return result
+		}
+
+		result.Attempts++
+		updated, err := b.update(ctx, task)
+		var conflictErr *ConflictError
+		switch {
+		case err == nil:
+			result.Status = BulkTaskSucceeded
+			if updated != nil {
+				result.Task = updated
+			}
+			return result
+		case errors.As(err, &conflictErr) && result.Attempts <= maxRetries:
+			task = nil
+			backoff.Backoff()
+			if err := backoff.Wait(ctx); err != nil {
+				return failed(err)
+			}
+		default:
+			return failed(err)
+		}
+	}
+}
+
+// alreadyApplied returns true if the task already has the operation's change, or an error if the operation can't be
+// applied to the task.
+func (b *bulkRun) alreadyApplied(task *Task) (bool, error) {
+	op := &b.req.Operation
+	switch op.Type {
+	case BulkOperationMove:
+		if task.WorkstreamID == nil {
+			return false, fmt.Errorf("task %s is not associated with a workstream", task.TaskID)
+		}
+		return *task.WorkstreamID == op.DestinationWorkstreamID, nil
+	case BulkOperationAssign:
+		if task.WorkstreamID == nil {
+			return false, fmt.Errorf("task %s is not associated with a workstream, so can't be re-assigned", task.TaskID)
+		}
+		sameTenant := op.AssignedToTenantID == nil ||
+			(task.AssignedToTenantID != nil && *task.AssignedToTenantID == *op.AssignedToTenantID)
+		sameAI := op.AssignedToAI == nil || task.AssignedToAI == *op.AssignedToAI
+		return sameTenant && sameAI, nil
+	case BulkOperationDelete:
+		return task.Deleted, nil
+	case BulkOperationSetModel:
+		return task.Model != nil && *task.Model == op.Model, nil
+	default:
+		return false, fmt.Errorf("unsupported bulk operation %q", op.Type)
+	}
+}
+
+// update sends the operation's change for a task, returning the updated task.
+func (b *bulkRun) update(ctx context.Context, task *Task) (*Task, error) {
+	op := &b.req.Operation
+	flags, auth, tenantID := b.req.FeatureFlags, b.req.DelegatedAuthInfo, b.req.TenantID
+
+	switch {
+	case op.Type == BulkOperationMove:
+		return b.move(ctx, task)
+	case op.Type == BulkOperationDelete && task.WorkstreamID != nil:
+		err := b.client.DeleteWorkstreamTask(
+			ctx, &DeleteWorkstreamTaskRequest{
+				FeatureFlags:      flags,
+				DelegatedAuthInfo: auth,
+				TenantID:          tenantID,
+				WorkstreamID:      *task.WorkstreamID,
+				TaskID:            task.TaskID,
+				Version:           task.Version,
+			},
+		)
+		return nil, err
+	case op.Type == BulkOperationDelete:
+		err := b.client.DeleteTask(
+			ctx, &DeleteTaskRequest{
+				FeatureFlags:      flags,
+				DelegatedAuthInfo: auth,
+				TenantID:          tenantID,
+				TaskID:            task.TaskID,
+				Version:           task.Version,
+			},
+		)
+		return nil, err
+	case task.WorkstreamID != nil:
+		req := &UpdateWorkstreamTaskRequest{
+			FeatureFlags:      flags,
+			DelegatedAuthInfo: auth,
+			TenantID:          tenantID,
+			WorkstreamID:      *task.WorkstreamID,
+			TaskID:            task.TaskID,
+			Version:           task.Version,
+		}
+		if op.Type == BulkOperationAssign {
+			req.AssignedToTenantID = op.AssignedToTenantID
+			req.AssignedToAI = op.AssignedToAI
+		} else {
+			req.Model = &op.Model
+		}
+		return b.client.UpdateWorkstreamTask(ctx, req)
+	default:
+		return b.client.UpdateTask(
+			ctx, &UpdateTaskRequest{
+				FeatureFlags:      flags,
+				DelegatedAuthInfo: auth,
+				TenantID:          tenantID,
+				TaskID:            task.TaskID,
+				Version:           task.Version,
+				Model:             &op.Model,
+			},
+		)
+	}
+}
+
+func (b *bulkRun) move(ctx context.Context, task *Task) (*Task, error) {
+	getWorkstream := func(id string) (*Workstream, error) {
+		return b.client.GetWorkstream(
+			ctx, &GetWorkstreamRequest{
+				FeatureFlags:      b.req.FeatureFlags,
+				DelegatedAuthInfo: b.req.DelegatedAuthInfo,
+				TenantID:          b.req.TenantID,
+				WorkstreamID:      id,
+			},
+		)
+	}
+	source, err := getWorkstream(*task.WorkstreamID)
+	if err != nil {
+		return nil, err
+	}
+	dest, err := getWorkstream(b.req.Operation.DestinationWorkstreamID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := b.client.MoveTask(
+		ctx, &MoveTaskRequest{
+			FeatureFlags:                 b.req.FeatureFlags,
+			DelegatedAuthInfo:            b.req.DelegatedAuthInfo,
+			TenantID:                     b.req.TenantID,
+			TaskID:                       task.TaskID,
+			DestinationWorkstreamID:      b.req.Operation.DestinationWorkstreamID,
+			TaskVersion:                  task.Version,
+			SourceWorkstreamVersion:      source.Version,
+			DestinationWorkstreamVersion: dest.Version,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &resp.Task, nil
+}
@@ -0,0 +1,346 @@
+package p42_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/plan42-ai/sdk-go/internal/util"
+	"github.com/plan42-ai/sdk-go/p42"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBulkServer keeps tasks in memory and enforces If-Match versions on updates and deletes.
+type fakeBulkServer struct {
+	mu    sync.Mutex
+	tasks map[string]*p42.Task
+	order []string
+
+	// bumps is the number of upcoming updates that find the task concurrently modified, and fail with a conflict.
+	bumps   int
+	updates int
+}
+
+func newFakeBulkServer(tasks ...p42.Task) *fakeBulkServer {
+	s := &fakeBulkServer{tasks: map[string]*p42.Task{}}
+	for i := range tasks {
+		s.tasks[tasks[i].TaskID] = &tasks[i]
+		s.order = append(s.order, tasks[i].TaskID)
+	}
+	return s
+}
+
+func (s *fakeBulkServer) handler(t *testing.T) http.Handler {
+	writeJSON := func(w http.ResponseWriter, status int, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		require.NoError(t, json.NewEncoder(w).Encode(v))
+	}
+	// checkVersion returns the task if the request's If-Match matches its version, or writes an error.
+	checkVersion := func(w http.ResponseWriter, r *http.Request, version int) *p42.Task {
+		task := s.tasks[r.PathValue("task")]
+		if task == nil {
+			writeJSON(w, http.StatusNotFound, p42.Error{ResponseCode: http.StatusNotFound, Message: "not found"})
+			return nil
+		}
+		if s.bumps > 0 {
+			s.bumps--
+			task.Version++
+		}
+		if version != task.Version {
+			writeJSON(w, http.StatusConflict, map[string]any{"ResponseCode": http.StatusConflict, "Message": "conflict"})
+			return nil
+		}
+		return task
+	}
+	ifMatch := func(r *http.Request) int {
+		v, _ := strconv.Atoi(r.Header.Get("If-Match"))
+		return v
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(
+		"GET /v1/tenants/ten/tasks", func(w http.ResponseWriter, _ *http.Request) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			var resp p42.ListTasksResponse
+			for _, id := range s.order {
+				resp.Tasks = append(resp.Tasks, *s.tasks[id])
+			}
+			writeJSON(w, http.StatusOK, resp)
+		},
+	)
+	mux.HandleFunc(
+		"GET /v1/tenants/ten/tasks/{task}", func(w http.ResponseWriter, r *http.Request) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			task := s.tasks[r.PathValue("task")]
+			if task == nil {
+				writeJSON(w, http.StatusNotFound, p42.Error{ResponseCode: http.StatusNotFound, Message: "not found"})
+				return
+			}
+			writeJSON(w, http.StatusOK, task)
+		},
+	)
+	mux.HandleFunc(
+		"PATCH /v1/tenants/ten/tasks/{task}", func(w http.ResponseWriter, r *http.Request) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.updates++
+			var req p42.UpdateTaskRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			if task := checkVersion(w, r, ifMatch(r)); task != nil {
+				task.Model = req.Model
+				task.Version++
+				writeJSON(w, http.StatusOK, task)
+			}
+		},
+	)
+	mux.HandleFunc(
+		"PATCH /v1/tenants/ten/workstreams/{ws}/tasks/{task}", func(w http.ResponseWriter, r *http.Request) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.updates++
+			var req p42.UpdateWorkstreamTaskRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			if task := checkVersion(w, r, ifMatch(r)); task != nil {
+				if req.AssignedToTenantID != nil {
+					task.AssignedToTenantID = req.AssignedToTenantID
+				}
+				if req.AssignedToAI != nil {
+					task.AssignedToAI = *req.AssignedToAI
+				}
+				task.Version++
+				writeJSON(w, http.StatusOK, task)
+			}
+		},
+	)
+	mux.HandleFunc(
+		"DELETE /v1/tenants/ten/tasks/{task}", func(w http.ResponseWriter, r *http.Request) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.updates++
+			if task := checkVersion(w, r, ifMatch(r)); task != nil {
+				task.Deleted = true
+				task.Version++
+				w.WriteHeader(http.StatusNoContent)
+			}
+		},
+	)
+	mux.HandleFunc(
+		"GET /v1/tenants/ten/workstreams/{ws}", func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, http.StatusOK, p42.Workstream{WorkstreamID: r.PathValue("ws"), TenantID: "ten", Version: 7})
+		},
+	)
+	mux.HandleFunc(
+		"POST /v1/tenants/ten/tasks/{task}/move", func(w http.ResponseWriter, r *http.Request) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.updates++
+			var req p42.MoveTaskRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			require.Equal(t, 7, req.SourceWorkstreamVersion)
+			if task := checkVersion(w, r, req.TaskVersion); task != nil {
+				task.WorkstreamID = &req.DestinationWorkstreamID
+				task.Version++
+				writeJSON(w, http.StatusOK, p42.MoveTaskResponse{Task: *task})
+			}
+		},
+	)
+	return mux
+}
+
+func bulkTask(id string, version int, model p42.ModelType) p42.Task {
+	return p42.Task{TaskID: id, Version: version, Model: util.Pointer(model), State: p42.TaskStatePending}
+}
+
+func statuses(report *p42.BulkTaskReport) []string {
+	var out []string
+	for _, r := range report.Results {
+		out = append(out, r.TaskID+":"+string(r.Status))
+	}
+	return out
+}
+
+func TestBulkUpdateTasksSetModel(t *testing.T) {
+	t.Parallel()
+	fake := newFakeBulkServer(
+		bulkTask("a", 1, p42.ModelTypeO3),
+		bulkTask("b", 4, p42.ModelTypeGpt5),
+		bulkTask("c", 2, p42.ModelTypeO3),
+	)
+	srv := httptest.NewServer(fake.handler(t))
+	defer srv.Close()
+
+	report, err := p42.BulkUpdateTasks(
+		context.Background(),
+		p42.NewClient(srv.URL),
+		&p42.BulkTaskRequest{
+			TenantID:  "ten",
+			Selector:  p42.BulkTaskSelector{Filter: func(*p42.Task) bool { return true }},
+			Operation: p42.BulkTaskOperation{Type: p42.BulkOperationSetModel, Model: p42.ModelTypeGpt5},
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a:Succeeded", "b:Skipped", "c:Succeeded"}, statuses(report))
+	require.Equal(t, 2, report.Succeeded)
+	require.Equal(t, 1, report.Skipped)
+	require.Equal(t, p42.ModelTypeGpt5, *report.Results[0].Task.Model)
+	require.Equal(t, 2, report.Results[0].Task.Version)
+}
+
+func TestBulkUpdateTasksDryRun(t *testing.T) {
+	t.Parallel()
+	fake := newFakeBulkServer(bulkTask("a", 1, p42.ModelTypeO3), bulkTask("b", 1, p42.ModelTypeGpt5))
+	srv := httptest.NewServer(fake.handler(t))
+	defer srv.Close()
+
+	report, err := p42.BulkUpdateTasks(
+		context.Background(),
+		p42.NewClient(srv.URL),
+		&p42.BulkTaskRequest{
+			TenantID:  "ten",
+			Selector:  p42.BulkTaskSelector{TaskIDs: []string{"a", "b", "missing"}},
+			Operation: p42.BulkTaskOperation{Type: p42.BulkOperationSetModel, Model: p42.ModelTypeGpt5},
+			DryRun:    true,
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a:WouldUpdate", "b:Skipped", "missing:Failed"}, statuses(report))
+	require.Equal(t, 1, report.Failed)
+	require.Equal(t, "not found", report.Results[2].ErrorMessage)
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	require.Zero(t, fake.updates)
+}
+
+func TestBulkUpdateTasksConflictRetry(t *testing.T) {
+	t.Parallel()
+	fake := newFakeBulkServer(bulkTask("a", 1, p42.ModelTypeO3))
+	fake.bumps = 2
+	srv := httptest.NewServer(fake.handler(t))
+	defer srv.Close()
+
+	report, err := p42.BulkUpdateTasks(
+		context.Background(),
+		p42.NewClient(srv.URL),
+		&p42.BulkTaskRequest{
+			TenantID:  "ten",
+			Selector:  p42.BulkTaskSelector{TaskIDs: []string{"a"}},
+			Operation: p42.BulkTaskOperation{Type: p42.BulkOperationDelete},
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a:Succeeded"}, statuses(report))
+	require.Equal(t, 3, report.Results[0].Attempts)
+
+	// Without enough retries, the task is reported as failed.
+	fake.mu.Lock()
+	fake.tasks["a"].Deleted = false
+	fake.bumps = 5
+	fake.mu.Unlock()
+	report, err = p42.BulkUpdateTasks(
+		context.Background(),
+		p42.NewClient(srv.URL),
+		&p42.BulkTaskRequest{
+			TenantID:           "ten",
+			Selector:           p42.BulkTaskSelector{TaskIDs: []string{"a"}},
+			Operation:          p42.BulkTaskOperation{Type: p42.BulkOperationDelete},
+			MaxConflictRetries: 1,
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a:Failed"}, statuses(report))
+	var conflictErr *p42.ConflictError
+	require.ErrorAs(t, report.Results[0].Err, &conflictErr)
+}
+
+func TestBulkUpdateTasksMoveAndAssign(t *testing.T) {
+	t.Parallel()
+	tasks := []p42.Task{
+		{TaskID: "a", Version: 1, WorkstreamID: util.Pointer("src")},
+		{TaskID: "b", Version: 1, WorkstreamID: util.Pointer("dst")},
+		{TaskID: "c", Version: 1},
+	}
+	fake := newFakeBulkServer(tasks...)
+	srv := httptest.NewServer(fake.handler(t))
+	defer srv.Close()
+	client := p42.NewClient(srv.URL)
+	ids := p42.BulkTaskSelector{TaskIDs: []string{"a", "b", "c"}}
+
+	report, err := p42.BulkUpdateTasks(
+		context.Background(),
+		client,
+		&p42.BulkTaskRequest{
+			TenantID:    "ten",
+			Selector:    ids,
+			Operation:   p42.BulkTaskOperation{Type: p42.BulkOperationMove, DestinationWorkstreamID: "dst"},
+			Concurrency: 1,
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a:Succeeded", "b:Skipped", "c:Failed"}, statuses(report))
+	require.Equal(t, "dst", *report.Results[0].Task.WorkstreamID)
+
+	report, err = p42.BulkUpdateTasks(
+		context.Background(),
+		client,
+		&p42.BulkTaskRequest{
+			TenantID:  "ten",
+			Selector:  ids,
+			Operation: p42.BulkTaskOperation{Type: p42.BulkOperationAssign, AssignedToAI: util.Pointer(true)},
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a:Succeeded", "b:Succeeded", "c:Failed"}, statuses(report))
+	require.True(t, slices.ContainsFunc(report.Results, func(r p42.BulkTaskResult) bool { return r.Task.AssignedToAI }))
+}
+
+func TestBulkUpdateTasksValidation(t *testing.T) {
+	t.Parallel()
+	client := p42.NewClient("http://localhost")
+	tests := []struct {
+		name     string
+		req      *p42.BulkTaskRequest
+		expected string
+	}{
+		{
+			name:     "no selector",
+			req:      &p42.BulkTaskRequest{TenantID: "ten", Operation: p42.BulkTaskOperation{Type: p42.BulkOperationDelete}},
+			expected: "task ids, workstream id or filter is required",
+		},
+		{
+			name: "move without destination",
+			req: &p42.BulkTaskRequest{
+				TenantID:  "ten",
+				Selector:  p42.BulkTaskSelector{TaskIDs: []string{"a"}},
+				Operation: p42.BulkTaskOperation{Type: p42.BulkOperationMove},
+			},
+			expected: "destination workstream id is required",
+		},
+		{
+			name: "unknown operation",
+			req: &p42.BulkTaskRequest{
+				TenantID:  "ten",
+				Selector:  p42.BulkTaskSelector{TaskIDs: []string{"a"}},
+				Operation: p42.BulkTaskOperation{Type: "Archive"},
+			},
+			expected: `unsupported bulk operation "Archive"`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(
+			tc.name, func(t *testing.T) {
+				t.Parallel()
+				_, err := p42.BulkUpdateTasks(context.Background(), client, tc.req)
+				require.ErrorContains(t, err, tc.expected)
+			},
+		)
+	}
+}
@@ -340,6 +340,7 @@ const (
 	ObjectTypeTenantGithubCreds      ObjectType = "TenantGithubCreds" // #nosec: G101: This is not a hard coded credential, it's an enum member that contains the work "cred".
 	ObjectTypeWorkstreamTaskConflict ObjectType = "WorkstreamTaskConflict"
 	ObjectTypeRunnerMessage          ObjectType = "RunnerMessage"
+	ObjectTypeAuthenticationProvider ObjectType = "AuthenticationProvider"
 )
 
 type ConflictObj interface {
@@ -417,6 +418,8 @@ func (e *ConflictError) UnmarshalJSON(b []byte) error {
 			current = &WorkstreamTaskConflict{}
 		case ObjectTypeTenantGithubCreds:
 			current = &TenantGithubCreds{}
+		case ObjectTypeAuthenticationProvider:
+			current = &AuthenticationProvider{}
 		default:
 			return fmt.Errorf("unknown object type %s", tmp.CurrentType)
 		}
@@ -831,10 +834,46 @@ func (t WebUITokenThumbprint) ObjectType() ObjectType {
 	return ObjectTypeWebUITokenThumbprint
 }
 
+// LogLevel is the severity of a TurnLog.
+type LogLevel string
+
+const (
+	LogLevelDebug LogLevel = "Debug"
+	LogLevelInfo  LogLevel = "Info"
+	LogLevelWarn  LogLevel = "Warn"
+	LogLevelError LogLevel = "Error"
+)
+
+// TurnLogStream is the output stream a TurnLog was written to.
+type TurnLogStream string
+
+const (
+	TurnLogStreamStdout TurnLogStream = "Stdout"
+	TurnLogStreamStderr TurnLogStream = "Stderr"
+)
+
+// TurnLogSource identifies what produced a TurnLog. Values other than the constants below are allowed.
+type TurnLogSource string
+
+const (
+	TurnLogSourceAgent  TurnLogSource = "Agent"
+	TurnLogSourceModel  TurnLogSource = "Model"
+	TurnLogSourceTool   TurnLogSource = "Tool"
+	TurnLogSourceSystem TurnLogSource = "System"
+)
+
 // TurnLog represents a single log entry for a turn.
+//
+// Only Timestamp and Message are required. The remaining fields are omitted when empty, so logs that don't use them
+// are encoded exactly as before.
 type TurnLog struct {
 	Timestamp time.Time `json:"Timestamp"`
 	Message   string    `json:"Message"`
+
+	Level      LogLevel       `json:"Level,omitempty"`
+	Stream     TurnLogStream  `json:"Stream,omitempty"`
+	Source     TurnLogSource  `json:"Source,omitempty"`
+	Attributes map[string]any `json:"Attributes,omitempty"`
 }
 
 // LastTurnLog represents the last log entry for a turn.
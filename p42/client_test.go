@@ -57,7 +57,7 @@ const (
 
 	tokenID         = "tok"
 	taskTitle       = "new"
-	turnStatus      = "Done"
+	turnStatus      = p42.TurnStatusDone
 	githubUserLogin = "octocat"
 )
 
@@ -5851,6 +5851,145 @@ func TestListOrgsForGithubConnectionPathEscaping(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestSearchRepos(t *testing.T) {
+	t.Parallel()
+
+	handler := http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, http.MethodGet, r.Method)
+			require.Equal(t, "/v1/tenants/abc/github-connections/conn/orgs/my-org/repos", r.URL.Path)
+			require.Equal(t, "application/json", r.Header.Get("Accept"))
+			require.Equal(t, "sdk", r.URL.Query().Get("search"))
+			require.Equal(t, "10", r.URL.Query().Get("maxResults"))
+			require.Equal(t, tokenID, r.URL.Query().Get("token"))
+
+			w.WriteHeader(http.StatusOK)
+			resp := p42.List[string]{Items: []string{"sdk-go"}}
+			_ = json.NewEncoder(w).Encode(resp)
+		},
+	)
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := p42.NewClient(srv.URL)
+	resp, err := client.SearchRepos(
+		context.Background(),
+		&p42.SearchReposRequest{
+			TenantID:     "abc",
+			ConnectionID: "conn",
+			OrgName:      "my-org",
+			Search:       "sdk",
+			MaxResults:   util.Pointer(10),
+			Token:        util.Pointer(tokenID),
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, []string{"sdk-go"}, resp.Items)
+}
+
+func TestSearchReposAll(t *testing.T) {
+	t.Parallel()
+
+	pages := map[string]p42.List[string]{
+		"":   {Items: []string{"repo-1", "repo-2"}, NextToken: util.Pointer("p2")},
+		"p2": {Items: []string{"repo-3"}},
+	}
+	handler := http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "repo", r.URL.Query().Get("search"))
+			w.WriteHeader(http.StatusOK)
+			_ = json.NewEncoder(w).Encode(pages[r.URL.Query().Get("token")])
+		},
+	)
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := p42.NewClient(srv.URL)
+	req := &p42.SearchReposRequest{TenantID: "abc", ConnectionID: "conn", OrgName: "my-org", Search: "repo"}
+	var repos []string
+	for repo, err := range client.SearchReposAll(context.Background(), req) {
+		require.NoError(t, err)
+		repos = append(repos, repo)
+	}
+	require.Equal(t, []string{"repo-1", "repo-2", "repo-3"}, repos)
+	require.Nil(t, req.Token)
+}
+
+func TestSearchReposError(t *testing.T) {
+	t.Parallel()
+	srv, client := serveBadRequest()
+	defer srv.Close()
+
+	_, err := client.SearchRepos(
+		context.Background(),
+		&p42.SearchReposRequest{TenantID: "abc", ConnectionID: "conn", OrgName: "my-org"},
+	)
+	require.Error(t, err)
+
+	for repo, err := range client.SearchReposAll(
+		context.Background(),
+		&p42.SearchReposRequest{TenantID: "abc", ConnectionID: "conn", OrgName: "my-org"},
+	) {
+		require.Empty(t, repo)
+		require.Error(t, err)
+	}
+}
+
+func TestSearchReposValidation(t *testing.T) {
+	t.Parallel()
+	client := p42.NewClient("http://localhost")
+
+	for _, maxResults := range []int{0, 500} {
+		_, err := client.SearchRepos(
+			context.Background(),
+			&p42.SearchReposRequest{
+				TenantID:     "abc",
+				ConnectionID: "conn",
+				OrgName:      "my-org",
+				MaxResults:   util.Pointer(maxResults),
+			},
+		)
+		require.EqualError(t, err, "max results must be between 1 and 499")
+	}
+
+	_, err := client.SearchRepos(context.Background(), &p42.SearchReposRequest{TenantID: "abc", ConnectionID: "conn"})
+	require.EqualError(t, err, "org name is required")
+}
+
+func TestSearchReposPathEscaping(t *testing.T) {
+	t.Parallel()
+
+	handler := http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			escapedPath := r.URL.EscapedPath()
+			parts := strings.Split(escapedPath, "/")
+			require.Equal(t, 9, len(parts), "path doesn't have correct # of parts: %s", escapedPath)
+			require.Equal(t, escapedTenantID, parts[3])
+			require.Equal(t, escapedGithubConnectionID, parts[5])
+			require.Equal(t, "org%2F..", parts[7])
+
+			w.WriteHeader(http.StatusOK)
+			_ = json.NewEncoder(w).Encode(p42.List[string]{})
+		},
+	)
+
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	client := p42.NewClient(srv.URL)
+	_, err := client.SearchRepos(
+		context.Background(),
+		&p42.SearchReposRequest{
+			TenantID:     tenantIDThatNeedsEscaping,
+			ConnectionID: githubConnectionIDThatNeedsEscaping,
+			OrgName:      "org/..",
+		},
+	)
+	require.NoError(t, err)
+}
+
 func TestUpdateGithubConnection(t *testing.T) {
 	t.Parallel()
 
@@ -7691,7 +7830,7 @@ func TestFeatureFlagsHeader(t *testing.T) {
 			status: http.StatusOK,
 			resp:   p42.Turn{},
 			call: func(c *p42.Client) error {
-				status := "s"
+				status := p42.TurnStatus("s")
 				_, err := c.UpdateTurn(
 					context.Background(), &p42.UpdateTurnRequest{
 						FeatureFlags: p42.FeatureFlags{FeatureFlags: map[string]bool{"ff": true}},
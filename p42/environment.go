@@ -186,6 +186,24 @@ func (c *Client) CreateEnvironment(ctx context.Context, req *CreateEnvironmentRe
 	return &out, nil
 }
 
+// Environment returns the environment the request creates, for validation.
+func (r *CreateEnvironmentRequest) Environment() *Environment {
+	return &Environment{
+		TenantID:           r.TenantID,
+		EnvironmentID:      r.EnvironmentID,
+		Name:               r.Name,
+		Description:        r.Description,
+		Context:            r.Context,
+		Repos:              r.Repos,
+		SetupScript:        r.SetupScript,
+		DockerImage:        r.DockerImage,
+		AllowedHosts:       r.AllowedHosts,
+		EnvVars:            r.EnvVars,
+		RunnerID:           r.RunnerID,
+		GithubConnectionID: r.GithubConnectionID,
+	}
+}
+
 // GetEnvironmentRequest is the request payload for GetEnvironment.
 type GetEnvironmentRequest struct {
 	FeatureFlags
@@ -324,6 +342,45 @@ func (r *UpdateEnvironmentRequest) GetField(name string) (any, bool) {
 	}
 }
 
+// ApplyTo returns a copy of env with the request's updates applied, for validation.
+func (r *UpdateEnvironmentRequest) ApplyTo(env *Environment) *Environment {
+	ret := *env
+	if r.Name != nil {
+		ret.Name = *r.Name
+	}
+	if r.Description != nil {
+		ret.Description = *r.Description
+	}
+	if r.Context != nil {
+		ret.Context = *r.Context
+	}
+	if r.Repos != nil {
+		ret.Repos = *r.Repos
+	}
+	if r.SetupScript != nil {
+		ret.SetupScript = *r.SetupScript
+	}
+	if r.DockerImage != nil {
+		ret.DockerImage = *r.DockerImage
+	}
+	if r.AllowedHosts != nil {
+		ret.AllowedHosts = *r.AllowedHosts
+	}
+	if r.EnvVars != nil {
+		ret.EnvVars = *r.EnvVars
+	}
+	if r.Deleted != nil {
+		ret.Deleted = *r.Deleted
+	}
+	if r.RunnerID != nil {
+		ret.RunnerID = r.RunnerID
+	}
+	if r.GithubConnectionID != nil {
+		ret.GithubConnectionID = r.GithubConnectionID
+	}
+	return &ret
+}
+
 // UpdateEnvironment updates an existing environment.
 // nolint: dupl
 func (c *Client) UpdateEnvironment(ctx context.Context, req *UpdateEnvironmentRequest) (*Environment, error) {
@@ -0,0 +1,179 @@
+package p42
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/plan42-ai/sdk-go/internal/util"
+)
+
+// CloneSecretsMode controls what CloneEnvironment does with the values of secret env vars.
+type CloneSecretsMode string
+
+const (
+	// CloneSecretsCopy copies secret values, and secret references, unchanged.
+	CloneSecretsCopy CloneSecretsMode = "copy"
+
+	// CloneSecretsStrip leaves secret env vars out of the clone.
+	CloneSecretsStrip CloneSecretsMode = "strip"
+
+	// CloneSecretsPrompt asks CloneEnvironmentRequest.PromptSecret for the value of each secret env var.
+	CloneSecretsPrompt CloneSecretsMode = "prompt"
+)
+
+// CloneEnvironmentRequest is the request for CloneEnvironment.
+type CloneEnvironmentRequest struct {
+	FeatureFlags
+	DelegatedAuthInfo
+
+	SourceTenantID      string
+	SourceEnvironmentID string
+
+	// DestinationTenantID is the tenant to create the clone in. Defaults to SourceTenantID.
+	DestinationTenantID string
+
+	// DestinationEnvironmentID is the ID of the clone. It must not already exist in the destination tenant.
+	DestinationEnvironmentID string
+
+	// Name, if set, replaces the source environment's name.
+	Name *string
+
+	// RunnerID and GithubConnectionID, if set, re-point the clone at a different runner or GitHub connection. If
+	// they are nil, the clone keeps the source's, unless it is cloned to another tenant: runners and connections
+	// belong to a tenant, so the clone then uses the destination tenant's defaults.
+	RunnerID           *string
+	GithubConnectionID *string
+
+	// Secrets controls what happens to secret env vars. Defaults to CloneSecretsCopy.
+	Secrets CloneSecretsMode
+
+	// PromptSecret returns the value of a secret env var of the clone, when Secrets is CloneSecretsPrompt. It is
+	// passed the source env var. If it returns an empty value, the env var is left out of the clone.
+	PromptSecret func(ctx context.Context, envVar EnvVar) (string, error)
+
+	// Resolver, if set, is used to validate the clone before it is created. See Environment.Validate.
+	Resolver EnvironmentResolver
+}
+
+// CloneEnvironment copies an environment to a new environment ID, possibly in another tenant, and returns the
+// clone. If the destination environment already exists, including as a deleted environment, CloneEnvironment
+// returns a *ConflictError whose Current is the existing environment, without prompting for any secrets.
+func CloneEnvironment(ctx context.Context, client *Client, req *CloneEnvironmentRequest) (*Environment, error) {
+	if req == nil {
+		return nil, fmt.Errorf("req is nil")
+	}
+	if req.SourceTenantID == "" {
+		return nil, fmt.Errorf("source tenant id is required")
+	}
+	if req.SourceEnvironmentID == "" {
+		return nil, fmt.Errorf("source environment id is required")
+	}
+	if req.DestinationEnvironmentID == "" {
+		return nil, fmt.Errorf("destination environment id is required")
+	}
+	secrets := req.Secrets
+	switch secrets {
+	case "":
+		secrets = CloneSecretsCopy
+	case CloneSecretsCopy, CloneSecretsStrip:
+	case CloneSecretsPrompt:
+		if req.PromptSecret == nil {
+			return nil, fmt.Errorf("prompt secret is required to prompt for secrets")
+		}
+	default:
+		return nil, fmt.Errorf("invalid secrets mode %q", secrets)
+	}
+	dstTenantID := req.DestinationTenantID
+	if dstTenantID == "" {
+		dstTenantID = req.SourceTenantID
+	}
+
+	src, err := client.GetEnvironment(
+		ctx, &GetEnvironmentRequest{
+			FeatureFlags:      req.FeatureFlags,
+			DelegatedAuthInfo: req.DelegatedAuthInfo,
+			TenantID:          req.SourceTenantID,
+			EnvironmentID:     req.SourceEnvironmentID,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := client.GetEnvironment(
+		ctx, &GetEnvironmentRequest{
+			FeatureFlags:      req.FeatureFlags,
+			DelegatedAuthInfo: req.DelegatedAuthInfo,
+			TenantID:          dstTenantID,
+			EnvironmentID:     req.DestinationEnvironmentID,
+			IncludeDeleted:    util.Pointer(true),
+		},
+	)
+	switch {
+	case err == nil:
+		return nil, &ConflictError{
+			ResponseCode: http.StatusConflict,
+			Message: fmt.Sprintf(
+				"environment %s already exists in tenant %s", req.DestinationEnvironmentID, dstTenantID,
+			),
+			Current: existing,
+		}
+	case !isNotFound(err):
+		return nil, err
+	}
+
+	create := &CreateEnvironmentRequest{
+		FeatureFlags:       req.FeatureFlags,
+		DelegatedAuthInfo:  req.DelegatedAuthInfo,
+		TenantID:           dstTenantID,
+		EnvironmentID:      req.DestinationEnvironmentID,
+		Name:               src.Name,
+		Description:        src.Description,
+		Context:            src.Context,
+		Repos:              slices.Clone(src.Repos),
+		SetupScript:        src.SetupScript,
+		DockerImage:        src.DockerImage,
+		AllowedHosts:       slices.Clone(src.AllowedHosts),
+		RunnerID:           req.RunnerID,
+		GithubConnectionID: req.GithubConnectionID,
+	}
+	if req.Name != nil {
+		create.Name = *req.Name
+	}
+	if dstTenantID == req.SourceTenantID {
+		if create.RunnerID == nil {
+			create.RunnerID = src.RunnerID
+		}
+		if create.GithubConnectionID == nil {
+			create.GithubConnectionID = src.GithubConnectionID
+		}
+	}
+
+	create.EnvVars = make([]EnvVar, 0, len(src.EnvVars))
+	for _, envVar := range src.EnvVars {
+		if envVar.IsSecret {
+			switch secrets {
+			case CloneSecretsStrip:
+				continue
+			case CloneSecretsPrompt:
+				envVar.Value, err = req.PromptSecret(ctx, envVar)
+				if err != nil {
+					return nil, err
+				}
+				if envVar.Value == "" {
+					continue
+				}
+			}
+		}
+		create.EnvVars = append(create.EnvVars, envVar)
+	}
+
+	if req.Resolver != nil {
+		if err := create.Environment().Validate(ctx, req.Resolver); err != nil {
+			return nil, err
+		}
+	}
+	return client.CreateEnvironment(ctx, create)
+}
@@ -0,0 +1,172 @@
+package p42_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/plan42-ai/sdk-go/internal/util"
+	"github.com/plan42-ai/sdk-go/p42"
+	"github.com/stretchr/testify/require"
+)
+
+func newCloneTestServer(t *testing.T) (*fakeEnvironmentServer, *p42.Client) {
+	t.Helper()
+	fake, client := newTestEnvironmentServer(t)
+	fake.envs["ten/env-1"] = &p42.Environment{
+		TenantID:      "ten",
+		EnvironmentID: "env-1",
+		Name:          "backend",
+		Repos:         []string{"org/api"},
+		EnvVars: []p42.EnvVar{
+			{Name: "LOG_LEVEL", Value: "debug"},
+			{Name: "API_KEY", Value: "s3cret", IsSecret: true},
+			{Name: "DB_PASSWORD", Value: "vault://db/password", IsSecret: true},
+		},
+		RunnerID:           util.Pointer("runner-1"),
+		GithubConnectionID: util.Pointer("conn-1"),
+		Version:            3,
+	}
+	return fake, client
+}
+
+func TestCloneEnvironment(t *testing.T) {
+	t.Parallel()
+	fake, client := newCloneTestServer(t)
+
+	env, err := p42.CloneEnvironment(
+		context.Background(), client, &p42.CloneEnvironmentRequest{
+			SourceTenantID:           "ten",
+			SourceEnvironmentID:      "env-1",
+			DestinationEnvironmentID: "env-2",
+			Name:                     util.Pointer("backend-staging"),
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, "env-2", env.EnvironmentID)
+	require.Equal(t, "backend-staging", env.Name)
+	require.Equal(t, 1, env.Version)
+	require.Equal(t, fake.envs["ten/env-1"].EnvVars, env.EnvVars)
+
+	// Within a tenant, the clone keeps the source's runner and connection.
+	require.Equal(t, "runner-1", *env.RunnerID)
+	require.Equal(t, "conn-1", *env.GithubConnectionID)
+}
+
+func TestCloneEnvironmentToTenant(t *testing.T) {
+	t.Parallel()
+	_, client := newCloneTestServer(t)
+
+	env, err := p42.CloneEnvironment(
+		context.Background(), client, &p42.CloneEnvironmentRequest{
+			SourceTenantID:           "ten",
+			SourceEnvironmentID:      "env-1",
+			DestinationTenantID:      "prod",
+			DestinationEnvironmentID: "env-1",
+			RunnerID:                 util.Pointer("runner-9"),
+			Secrets:                  p42.CloneSecretsStrip,
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, "prod", env.TenantID)
+	require.Equal(t, []p42.EnvVar{{Name: "LOG_LEVEL", Value: "debug"}}, env.EnvVars)
+
+	// Runners and connections that aren't remapped revert to the destination tenant's defaults.
+	require.Equal(t, "runner-9", *env.RunnerID)
+	require.Equal(t, "default", *env.GithubConnectionID)
+}
+
+func TestCloneEnvironmentPromptSecrets(t *testing.T) {
+	t.Parallel()
+	_, client := newCloneTestServer(t)
+
+	var prompted []string
+	env, err := p42.CloneEnvironment(
+		context.Background(), client, &p42.CloneEnvironmentRequest{
+			SourceTenantID:           "ten",
+			SourceEnvironmentID:      "env-1",
+			DestinationEnvironmentID: "env-2",
+			Secrets:                  p42.CloneSecretsPrompt,
+			PromptSecret: func(_ context.Context, envVar p42.EnvVar) (string, error) {
+				prompted = append(prompted, envVar.Name)
+				if envVar.Name == "API_KEY" {
+					return "staging-key", nil
+				}
+				return "", nil
+			},
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, []string{"API_KEY", "DB_PASSWORD"}, prompted)
+	require.Equal(
+		t, []p42.EnvVar{
+			{Name: "LOG_LEVEL", Value: "debug"},
+			{Name: "API_KEY", Value: "staging-key", IsSecret: true},
+		}, env.EnvVars,
+	)
+}
+
+func TestCloneEnvironmentConflict(t *testing.T) {
+	t.Parallel()
+	fake, client := newCloneTestServer(t)
+	fake.envs["ten/env-2"] = &p42.Environment{TenantID: "ten", EnvironmentID: "env-2", Name: "other", Version: 7}
+
+	_, err := p42.CloneEnvironment(
+		context.Background(), client, &p42.CloneEnvironmentRequest{
+			SourceTenantID:           "ten",
+			SourceEnvironmentID:      "env-1",
+			DestinationEnvironmentID: "env-2",
+			Secrets:                  p42.CloneSecretsPrompt,
+			PromptSecret: func(context.Context, p42.EnvVar) (string, error) {
+				return "", errors.New("should not prompt")
+			},
+		},
+	)
+	var conflict *p42.ConflictError
+	require.ErrorAs(t, err, &conflict)
+	require.EqualError(t, err, "environment env-2 already exists in tenant ten")
+	require.Equal(t, "other", conflict.Current.(*p42.Environment).Name)
+	require.Equal(t, "other", fake.envs["ten/env-2"].Name)
+}
+
+func TestCloneEnvironmentErrors(t *testing.T) {
+	t.Parallel()
+	_, client := newCloneTestServer(t)
+	valid := func() *p42.CloneEnvironmentRequest {
+		return &p42.CloneEnvironmentRequest{
+			SourceTenantID:           "ten",
+			SourceEnvironmentID:      "env-1",
+			DestinationEnvironmentID: "env-2",
+		}
+	}
+
+	tests := []struct {
+		update func(req *p42.CloneEnvironmentRequest)
+		msg    string
+	}{
+		{func(req *p42.CloneEnvironmentRequest) { req.SourceTenantID = "" }, "source tenant id is required"},
+		{func(req *p42.CloneEnvironmentRequest) { req.SourceEnvironmentID = "" }, "source environment id is required"},
+		{func(req *p42.CloneEnvironmentRequest) { req.DestinationEnvironmentID = "" }, "destination environment id is required"},
+		{
+			func(req *p42.CloneEnvironmentRequest) { req.Secrets = p42.CloneSecretsPrompt },
+			"prompt secret is required to prompt for secrets",
+		},
+		{func(req *p42.CloneEnvironmentRequest) { req.Secrets = "keep" }, `invalid secrets mode "keep"`},
+		{
+			func(req *p42.CloneEnvironmentRequest) { req.Name = util.Pointer(" ") },
+			"invalid environment: Name: is required",
+		},
+	}
+	for _, tc := range tests {
+		req := valid()
+		req.Resolver = p42.NewEnvironmentResolver(client, p42.FeatureFlags{}, p42.DelegatedAuthInfo{})
+		req.RunnerID = util.Pointer("default")
+		req.GithubConnectionID = util.Pointer("default")
+		tc.update(req)
+		_, err := p42.CloneEnvironment(context.Background(), client, req)
+		require.EqualError(t, err, tc.msg)
+	}
+
+	_, err := p42.CloneEnvironment(context.Background(), client, nil)
+	require.EqualError(t, err, "req is nil")
+}
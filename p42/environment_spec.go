@@ -0,0 +1,410 @@
+package p42
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/plan42-ai/sdk-go/internal/util"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvironmentSpec is the declarative, YAML form of an Environment. Applying a spec makes the environment match it:
+// fields omitted from the spec are cleared, and RunnerID and GithubConnectionID revert to the default.
+type EnvironmentSpec struct {
+	EnvironmentID      string       `yaml:"id"`
+	Name               string       `yaml:"name"`
+	Description        string       `yaml:"description,omitempty"`
+	Context            string       `yaml:"context,omitempty"`
+	Repos              []string     `yaml:"repos,omitempty"`
+	SetupScript        string       `yaml:"setupScript,omitempty"`
+	DockerImage        string       `yaml:"dockerImage,omitempty"`
+	AllowedHosts       []string     `yaml:"allowedHosts,omitempty"`
+	EnvVars            []EnvVarSpec `yaml:"envVars,omitempty"`
+	RunnerID           *string      `yaml:"runnerId,omitempty"`
+	GithubConnectionID *string      `yaml:"githubConnectionId,omitempty"`
+}
+
+// EnvVarSpec is the YAML form of an EnvVar. The value of a secret env var can be a secret reference, such as
+// env://API_KEY or file:///run/secrets/api-key, so that the secret itself stays out of the spec.
+type EnvVarSpec struct {
+	Name   string `yaml:"name"`
+	Value  string `yaml:"value"`
+	Secret bool   `yaml:"secret,omitempty"`
+}
+
+// ParseEnvironmentSpec decodes a YAML environment spec. Unknown fields are rejected.
+func ParseEnvironmentSpec(r io.Reader) (*EnvironmentSpec, error) {
+	decoder := yaml.NewDecoder(r)
+	decoder.KnownFields(true)
+	var spec EnvironmentSpec
+	if err := decoder.Decode(&spec); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("environment spec is empty")
+		}
+		return nil, fmt.Errorf("unable to parse environment spec: %w", err)
+	}
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
+
+func (s *EnvironmentSpec) validate() error {
+	if s.EnvironmentID == "" {
+		return fmt.Errorf("environment spec: id is required")
+	}
+	if s.Name == "" {
+		return fmt.Errorf("environment spec: name is required")
+	}
+	seen := make(map[string]bool, len(s.EnvVars))
+	for _, envVar := range s.EnvVars {
+		if envVar.Name == "" {
+			return fmt.Errorf("environment spec: env var name is required")
+		}
+		if seen[envVar.Name] {
+			return fmt.Errorf("environment spec: duplicate env var %s", envVar.Name)
+		}
+		seen[envVar.Name] = true
+	}
+	return nil
+}
+
+// Environment returns the environment the spec describes, for validation.
+func (s *EnvironmentSpec) Environment(tenantID string) *Environment {
+	return &Environment{
+		TenantID:           tenantID,
+		EnvironmentID:      s.EnvironmentID,
+		Name:               s.Name,
+		Description:        s.Description,
+		Context:            s.Context,
+		Repos:              s.Repos,
+		SetupScript:        s.SetupScript,
+		DockerImage:        s.DockerImage,
+		AllowedHosts:       s.AllowedHosts,
+		EnvVars:            s.envVars(),
+		RunnerID:           s.RunnerID,
+		GithubConnectionID: s.GithubConnectionID,
+	}
+}
+
+func (s *EnvironmentSpec) envVars() []EnvVar {
+	ret := make([]EnvVar, 0, len(s.EnvVars))
+	for _, envVar := range s.EnvVars {
+		ret = append(ret, EnvVar{Name: envVar.Name, Value: envVar.Value, IsSecret: envVar.Secret})
+	}
+	return ret
+}
+
+// EnvironmentPlanAction is what applying an EnvironmentPlan does.
+type EnvironmentPlanAction string
+
+const (
+	EnvironmentPlanCreate EnvironmentPlanAction = "Create"
+	EnvironmentPlanUpdate EnvironmentPlanAction = "Update"
+	EnvironmentPlanNone   EnvironmentPlanAction = "None"
+)
+
+// EnvironmentChange is a single field level change in an EnvironmentPlan. Env vars are compared one by one, and
+// their changes are reported with a Field of the form EnvVars.NAME. The values of secret env vars are masked with
+// MaskSecretValue.
+type EnvironmentChange struct {
+	Field string `json:"Field"`
+	Old   any    `json:"Old,omitempty"`
+	New   any    `json:"New,omitempty"`
+}
+
+// EnvironmentPlan describes the changes needed to make an environment match an EnvironmentSpec.
+type EnvironmentPlan struct {
+	Action        EnvironmentPlanAction `json:"Action"`
+	EnvironmentID string                `json:"EnvironmentId"`
+
+	// Version is the version of the environment the plan was computed against. Applying an update fails with a
+	// ConflictError if the environment has changed since.
+	Version int                 `json:"Version,omitempty"`
+	Changes []EnvironmentChange `json:"Changes"`
+
+	current *Environment
+	create  *CreateEnvironmentRequest
+	update  *UpdateEnvironmentRequest
+}
+
+// String formats the plan for display, with one line per change.
+func (p *EnvironmentPlan) String() string {
+	var sb strings.Builder
+	switch p.Action {
+	case EnvironmentPlanCreate:
+		fmt.Fprintf(&sb, "environment %s will be created\n", p.EnvironmentID)
+	case EnvironmentPlanUpdate:
+		fmt.Fprintf(&sb, "environment %s will be updated (version %d)\n", p.EnvironmentID, p.Version)
+	default:
+		fmt.Fprintf(&sb, "environment %s is up to date\n", p.EnvironmentID)
+		return sb.String()
+	}
+	for _, change := range p.Changes {
+		switch {
+		case change.Old == nil:
+			fmt.Fprintf(&sb, "  + %s: %s\n", change.Field, formatChangeValue(change.New))
+		case change.New == nil:
+			fmt.Fprintf(&sb, "  - %s: %s\n", change.Field, formatChangeValue(change.Old))
+		default:
+			fmt.Fprintf(
+				&sb, "  ~ %s: %s -> %s\n", change.Field, formatChangeValue(change.Old), formatChangeValue(change.New),
+			)
+		}
+	}
+	return sb.String()
+}
+
+func formatChangeValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		if v == SecretMask {
+			return v
+		}
+		return strconv.Quote(v)
+	case []string:
+		return fmt.Sprintf("%q", v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// PlanEnvironmentRequest is the request for PlanEnvironment.
+type PlanEnvironmentRequest struct {
+	FeatureFlags
+	DelegatedAuthInfo
+	TenantID string
+	Spec     *EnvironmentSpec
+
+	// ResolveSecrets resolves the secret references of secret env vars before comparing and applying them, so that
+	// the environment stores the secrets themselves. Otherwise the references are stored, to be resolved when a
+	// runner starts the environment.
+	ResolveSecrets bool
+}
+
+// PlanEnvironment compares spec with the current state of the environment it names, and returns the plan that
+// makes the environment match it. If the environment doesn't exist, the plan creates it; if it was deleted, the
+// plan restores it.
+func PlanEnvironment(ctx context.Context, client *Client, req *PlanEnvironmentRequest) (*EnvironmentPlan, error) {
+	if req == nil {
+		return nil, fmt.Errorf("req is nil")
+	}
+	if req.TenantID == "" {
+		return nil, fmt.Errorf("tenant id is required")
+	}
+	if req.Spec == nil {
+		return nil, fmt.Errorf("spec is required")
+	}
+	if err := req.Spec.validate(); err != nil {
+		return nil, err
+	}
+	spec := req.Spec
+	envVars := spec.envVars()
+	if req.ResolveSecrets {
+		var err error
+		envVars, err = ResolveEnvVars(ctx, envVars)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	current, err := client.GetEnvironment(
+		ctx, &GetEnvironmentRequest{
+			FeatureFlags:      req.FeatureFlags,
+			DelegatedAuthInfo: req.DelegatedAuthInfo,
+			TenantID:          req.TenantID,
+			EnvironmentID:     spec.EnvironmentID,
+			IncludeDeleted:    util.Pointer(true),
+		},
+	)
+	switch {
+	case isNotFound(err):
+		return planEnvironmentCreate(req, envVars), nil
+	case err != nil:
+		return nil, err
+	}
+
+	plan := &EnvironmentPlan{
+		Action:        EnvironmentPlanNone,
+		EnvironmentID: spec.EnvironmentID,
+		Version:       current.Version,
+		current:       current,
+	}
+	update := &UpdateEnvironmentRequest{
+		FeatureFlags:      req.FeatureFlags,
+		DelegatedAuthInfo: req.DelegatedAuthInfo,
+		TenantID:          req.TenantID,
+		EnvironmentID:     spec.EnvironmentID,
+		Version:           current.Version,
+	}
+	diffString := func(field string, old, updated string, dst **string) {
+		if old != updated {
+			change := EnvironmentChange{Field: field, Old: nilIfEmpty(old), New: nilIfEmpty(updated)}
+			plan.Changes = append(plan.Changes, change)
+			*dst = &updated
+		}
+	}
+	diffList := func(field string, old, updated []string, dst **[]string) {
+		if !slices.Equal(old, updated) {
+			change := EnvironmentChange{Field: field, Old: nilIfEmpty(old), New: nilIfEmpty(updated)}
+			plan.Changes = append(plan.Changes, change)
+			if updated == nil {
+				updated = []string{}
+			}
+			*dst = &updated
+		}
+	}
+
+	if current.Deleted {
+		plan.Changes = append(plan.Changes, EnvironmentChange{Field: "Deleted", Old: true, New: false})
+		update.Deleted = util.Pointer(false)
+	}
+	diffString("Name", current.Name, spec.Name, &update.Name)
+	diffString("Description", current.Description, spec.Description, &update.Description)
+	diffString("Context", current.Context, spec.Context, &update.Context)
+	diffList("Repos", current.Repos, spec.Repos, &update.Repos)
+	diffString("SetupScript", current.SetupScript, spec.SetupScript, &update.SetupScript)
+	diffString("DockerImage", current.DockerImage, spec.DockerImage, &update.DockerImage)
+	diffList("AllowedHosts", current.AllowedHosts, spec.AllowedHosts, &update.AllowedHosts)
+	if changes := diffEnvVars(current.EnvVars, envVars); len(changes) > 0 {
+		plan.Changes = append(plan.Changes, changes...)
+		update.EnvVars = &envVars
+	}
+	diffString(
+		"RunnerID", defaultEnvironmentID(current.RunnerID), defaultEnvironmentID(spec.RunnerID), &update.RunnerID,
+	)
+	diffString(
+		"GithubConnectionID",
+		defaultEnvironmentID(current.GithubConnectionID),
+		defaultEnvironmentID(spec.GithubConnectionID),
+		&update.GithubConnectionID,
+	)
+
+	if len(plan.Changes) > 0 {
+		plan.Action = EnvironmentPlanUpdate
+		plan.update = update
+	}
+	return plan, nil
+}
+
+func planEnvironmentCreate(req *PlanEnvironmentRequest, envVars []EnvVar) *EnvironmentPlan {
+	spec := req.Spec
+	create := &CreateEnvironmentRequest{
+		FeatureFlags:       req.FeatureFlags,
+		DelegatedAuthInfo:  req.DelegatedAuthInfo,
+		TenantID:           req.TenantID,
+		EnvironmentID:      spec.EnvironmentID,
+		Name:               spec.Name,
+		Description:        spec.Description,
+		Context:            spec.Context,
+		Repos:              spec.Repos,
+		SetupScript:        spec.SetupScript,
+		DockerImage:        spec.DockerImage,
+		AllowedHosts:       spec.AllowedHosts,
+		EnvVars:            envVars,
+		RunnerID:           spec.RunnerID,
+		GithubConnectionID: spec.GithubConnectionID,
+	}
+	if create.Repos == nil {
+		create.Repos = []string{}
+	}
+	if create.AllowedHosts == nil {
+		create.AllowedHosts = []string{}
+	}
+
+	plan := &EnvironmentPlan{Action: EnvironmentPlanCreate, EnvironmentID: spec.EnvironmentID, create: create}
+	add := func(field string, value any) {
+		if value != nil {
+			plan.Changes = append(plan.Changes, EnvironmentChange{Field: field, New: value})
+		}
+	}
+	add("Name", nilIfEmpty(spec.Name))
+	add("Description", nilIfEmpty(spec.Description))
+	add("Context", nilIfEmpty(spec.Context))
+	add("Repos", nilIfEmpty(spec.Repos))
+	add("SetupScript", nilIfEmpty(spec.SetupScript))
+	add("DockerImage", nilIfEmpty(spec.DockerImage))
+	add("AllowedHosts", nilIfEmpty(spec.AllowedHosts))
+	plan.Changes = append(plan.Changes, diffEnvVars(nil, create.EnvVars)...)
+	if spec.RunnerID != nil {
+		add("RunnerID", *spec.RunnerID)
+	}
+	if spec.GithubConnectionID != nil {
+		add("GithubConnectionID", *spec.GithubConnectionID)
+	}
+	return plan
+}
+
+// diffEnvVars compares env vars by name. Changes are reported in the order of updated, followed by removals.
+func diffEnvVars(old, updated []EnvVar) []EnvironmentChange {
+	display := func(envVar EnvVar) any {
+		if envVar.IsSecret {
+			return MaskSecretValue(envVar.Value)
+		}
+		return envVar.Value
+	}
+
+	oldByName := make(map[string]EnvVar, len(old))
+	for _, envVar := range old {
+		oldByName[envVar.Name] = envVar
+	}
+	var changes []EnvironmentChange
+	for _, envVar := range updated {
+		field := "EnvVars." + envVar.Name
+		prev, ok := oldByName[envVar.Name]
+		delete(oldByName, envVar.Name)
+		switch {
+		case !ok:
+			changes = append(changes, EnvironmentChange{Field: field, New: display(envVar)})
+		case prev != envVar:
+			changes = append(changes, EnvironmentChange{Field: field, Old: display(prev), New: display(envVar)})
+		}
+	}
+	for _, envVar := range old {
+		if _, ok := oldByName[envVar.Name]; ok {
+			changes = append(changes, EnvironmentChange{Field: "EnvVars." + envVar.Name, Old: display(envVar)})
+		}
+	}
+
+	// Reordering env vars is a change to the environment, even though no single env var changed.
+	if len(changes) == 0 && !slices.Equal(old, updated) {
+		changes = append(changes, EnvironmentChange{Field: "EnvVars", Old: envVarNames(old), New: envVarNames(updated)})
+	}
+	return changes
+}
+
+func envVarNames(envVars []EnvVar) []string {
+	ret := make([]string, 0, len(envVars))
+	for _, envVar := range envVars {
+		ret = append(ret, envVar.Name)
+	}
+	return ret
+}
+
+func nilIfEmpty[T string | []string](v T) any {
+	if len(v) == 0 {
+		return nil
+	}
+	return v
+}
+
+// ApplyEnvironmentPlan applies a plan returned by PlanEnvironment, and returns the resulting environment. A plan
+// with nothing to change returns the environment as it was when planned.
+func ApplyEnvironmentPlan(ctx context.Context, client *Client, plan *EnvironmentPlan) (*Environment, error) {
+	if plan == nil {
+		return nil, fmt.Errorf("plan is nil")
+	}
+	switch plan.Action {
+	case EnvironmentPlanCreate:
+		return client.CreateEnvironment(ctx, plan.create)
+	case EnvironmentPlanUpdate:
+		return client.UpdateEnvironment(ctx, plan.update)
+	default:
+		return plan.current, nil
+	}
+}
@@ -0,0 +1,233 @@
+package p42_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/plan42-ai/sdk-go/internal/util"
+	"github.com/plan42-ai/sdk-go/p42"
+	"github.com/stretchr/testify/require"
+)
+
+const testEnvironmentSpec = `
+id: env-1
+name: backend
+description: Backend services
+repos:
+  - org/api
+  - org/web
+dockerImage: golang:1.24
+allowedHosts: [proxy.golang.org]
+envVars:
+  - name: LOG_LEVEL
+    value: debug
+  - name: API_KEY
+    value: s3cret
+    secret: true
+runnerId: runner-1
+`
+
+// fakeEnvironmentServer keeps environments in memory, validates them, and enforces If-Match versions on updates.
+type fakeEnvironmentServer struct {
+	mu sync.Mutex
+
+	// envs is keyed by tenant/environment.
+	envs map[string]*p42.Environment
+}
+
+func (s *fakeEnvironmentServer) handler(t *testing.T) http.Handler {
+	writeJSON := func(w http.ResponseWriter, status int, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		require.NoError(t, json.NewEncoder(w).Encode(v))
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(
+		"GET /v1/tenants/{tenant}/environments/{env}", func(w http.ResponseWriter, r *http.Request) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			env, ok := s.envs[envKey(r)]
+			if !ok {
+				writeJSON(w, http.StatusNotFound, p42.Error{ResponseCode: http.StatusNotFound, Message: "not found"})
+				return
+			}
+			writeJSON(w, http.StatusOK, env)
+		},
+	)
+	mux.HandleFunc(
+		"PUT /v1/tenants/{tenant}/environments/{env}", func(w http.ResponseWriter, r *http.Request) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if _, ok := s.envs[envKey(r)]; ok {
+				writeJSON(w, http.StatusConflict, map[string]any{"ResponseCode": http.StatusConflict, "Message": "exists"})
+				return
+			}
+			req := p42.CreateEnvironmentRequest{TenantID: r.PathValue("tenant"), EnvironmentID: r.PathValue("env")}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			env := req.Environment()
+			if err := env.Validate(r.Context(), nil); err != nil {
+				writeJSON(w, http.StatusBadRequest, p42.Error{ResponseCode: http.StatusBadRequest, Message: err.Error()})
+				return
+			}
+			env.Version = 1
+			s.envs[envKey(r)] = env
+			writeJSON(w, http.StatusCreated, env)
+		},
+	)
+	mux.HandleFunc(
+		"PATCH /v1/tenants/{tenant}/environments/{env}", func(w http.ResponseWriter, r *http.Request) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			env := s.envs[envKey(r)]
+			if r.Header.Get("If-Match") != strconv.Itoa(env.Version) {
+				writeJSON(w, http.StatusConflict, map[string]any{"ResponseCode": http.StatusConflict, "Message": "conflict"})
+				return
+			}
+			var req p42.UpdateEnvironmentRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			updated := req.ApplyTo(env)
+			if err := updated.Validate(r.Context(), nil); err != nil {
+				writeJSON(w, http.StatusBadRequest, p42.Error{ResponseCode: http.StatusBadRequest, Message: err.Error()})
+				return
+			}
+			*env = *updated
+			env.Version++
+			writeJSON(w, http.StatusOK, env)
+		},
+	)
+	return mux
+}
+
+func envKey(r *http.Request) string {
+	return r.PathValue("tenant") + "/" + r.PathValue("env")
+}
+
+func newTestEnvironmentServer(t *testing.T) (*fakeEnvironmentServer, *p42.Client) {
+	t.Helper()
+	fake := &fakeEnvironmentServer{envs: map[string]*p42.Environment{}}
+	srv := httptest.NewServer(fake.handler(t))
+	t.Cleanup(srv.Close)
+	return fake, p42.NewClient(srv.URL)
+}
+
+func planTestEnvironment(t *testing.T, client *p42.Client, spec string) *p42.EnvironmentPlan {
+	t.Helper()
+	parsed, err := p42.ParseEnvironmentSpec(strings.NewReader(spec))
+	require.NoError(t, err)
+	plan, err := p42.PlanEnvironment(
+		context.Background(), client, &p42.PlanEnvironmentRequest{TenantID: "ten", Spec: parsed},
+	)
+	require.NoError(t, err)
+	return plan
+}
+
+func TestPlanEnvironmentCreate(t *testing.T) {
+	t.Parallel()
+	fake, client := newTestEnvironmentServer(t)
+
+	plan := planTestEnvironment(t, client, testEnvironmentSpec)
+	require.Equal(t, p42.EnvironmentPlanCreate, plan.Action)
+	require.Contains(t, plan.Changes, p42.EnvironmentChange{Field: "EnvVars.API_KEY", New: p42.SecretMask})
+	require.NotContains(t, plan.String(), "s3cret")
+	require.Contains(t, plan.String(), `+ EnvVars.LOG_LEVEL: "debug"`)
+
+	env, err := p42.ApplyEnvironmentPlan(context.Background(), client, plan)
+	require.NoError(t, err)
+	require.Equal(t, "backend", env.Name)
+	require.Equal(t, "runner-1", *env.RunnerID)
+	require.Equal(t, p42.EnvVar{Name: "API_KEY", Value: "s3cret", IsSecret: true}, fake.envs["ten/env-1"].EnvVars[1])
+
+	// Applying the same spec again changes nothing.
+	plan = planTestEnvironment(t, client, testEnvironmentSpec)
+	require.Equal(t, p42.EnvironmentPlanNone, plan.Action)
+	require.Empty(t, plan.Changes)
+	require.Equal(t, "environment env-1 is up to date\n", plan.String())
+}
+
+func TestPlanEnvironmentUpdate(t *testing.T) {
+	t.Parallel()
+	fake, client := newTestEnvironmentServer(t)
+	_, err := p42.ApplyEnvironmentPlan(context.Background(), client, planTestEnvironment(t, client, testEnvironmentSpec))
+	require.NoError(t, err)
+
+	updated := strings.NewReplacer(
+		"description: Backend services\n", "",
+		"golang:1.24", "golang:1.25",
+		"value: s3cret", "value: rotated",
+		"  - name: LOG_LEVEL\n    value: debug\n", "",
+		"runnerId: runner-1\n", "",
+	).Replace(testEnvironmentSpec)
+	plan := planTestEnvironment(t, client, updated)
+	require.Equal(t, p42.EnvironmentPlanUpdate, plan.Action)
+	require.Equal(t, 1, plan.Version)
+	require.Equal(
+		t, []p42.EnvironmentChange{
+			{Field: "Description", Old: "Backend services"},
+			{Field: "DockerImage", Old: "golang:1.24", New: "golang:1.25"},
+			{Field: "EnvVars.API_KEY", Old: p42.SecretMask, New: p42.SecretMask},
+			{Field: "EnvVars.LOG_LEVEL", Old: "debug"},
+			{Field: "RunnerID", Old: "runner-1", New: "default"},
+		}, plan.Changes,
+	)
+	require.Equal(
+		t, `environment env-1 will be updated (version 1)
+  - Description: "Backend services"
+  ~ DockerImage: "golang:1.24" -> "golang:1.25"
+  ~ EnvVars.API_KEY: ******** -> ********
+  - EnvVars.LOG_LEVEL: "debug"
+  ~ RunnerID: "runner-1" -> "default"
+`, plan.String(),
+	)
+
+	env, err := p42.ApplyEnvironmentPlan(context.Background(), client, plan)
+	require.NoError(t, err)
+	require.Equal(t, 2, env.Version)
+	require.Empty(t, env.Description)
+	require.Equal(t, []p42.EnvVar{{Name: "API_KEY", Value: "rotated", IsSecret: true}}, env.EnvVars)
+
+	// A plan computed against an older version fails to apply.
+	fake.envs["ten/env-1"].Deleted = true
+	plan = planTestEnvironment(t, client, testEnvironmentSpec)
+	require.Equal(t, p42.EnvironmentChange{Field: "Deleted", Old: true, New: false}, plan.Changes[0])
+	fake.envs["ten/env-1"].Version++
+	_, err = p42.ApplyEnvironmentPlan(context.Background(), client, plan)
+	var conflictErr *p42.ConflictError
+	require.ErrorAs(t, err, &conflictErr)
+}
+
+func TestParseEnvironmentSpecErrors(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		spec string
+		msg  string
+	}{
+		{"", "environment spec is empty"},
+		{"name: x\n", "environment spec: id is required"},
+		{"id: e\n", "environment spec: name is required"},
+		{"id: e\nname: x\nenvVars:\n  - value: v\n", "environment spec: env var name is required"},
+		{"id: e\nname: x\nenvVars:\n  - name: A\n  - name: A\n", "environment spec: duplicate env var A"},
+		{
+			"id: e\nname: x\nimage: y\n",
+			"unable to parse environment spec: yaml: unmarshal errors:\n  line 3: field image not found in type p42.EnvironmentSpec",
+		},
+	}
+	for _, tc := range tests {
+		_, err := p42.ParseEnvironmentSpec(strings.NewReader(tc.spec))
+		require.EqualError(t, err, tc.msg)
+	}
+
+	_, err := p42.PlanEnvironment(context.Background(), p42.NewClient("http://localhost"), &p42.PlanEnvironmentRequest{})
+	require.EqualError(t, err, "tenant id is required")
+	_, err = p42.PlanEnvironment(
+		context.Background(), p42.NewClient("http://localhost"),
+		&p42.PlanEnvironmentRequest{TenantID: "ten", Spec: &p42.EnvironmentSpec{Name: "x", RunnerID: util.Pointer("r")}},
+	)
+	require.EqualError(t, err, "environment spec: id is required")
+}
@@ -0,0 +1,249 @@
+package p42
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Limits enforced by the service on environments.
+const (
+	MaxEnvironmentRepos        = 50
+	MaxEnvironmentAllowedHosts = 50
+	MaxEnvironmentEnvVars      = 50
+	MaxEnvironmentSetupScript  = 512 * 1024
+)
+
+var (
+	githubOwnerRE = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9-]{0,38})$`)
+	githubRepoRE  = regexp.MustCompile(`^[A-Za-z0-9._-]{1,100}$`)
+	hostLabelRE   = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9-]{0,61}[A-Za-z0-9])?$`)
+	envVarNameRE  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+	// dockerImageRE follows the reference grammar of the docker distribution project: an optional registry host
+	// and port, a path of lower case components, and an optional tag and digest.
+	dockerImageRE = regexp.MustCompile(
+		`^(?:(?:[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?(?:\.[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?)*` +
+			`|\[[0-9A-Fa-f:]+\])(?::[0-9]+)?/)?` +
+			`[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*` +
+			`(?::[A-Za-z0-9_][A-Za-z0-9_.-]{0,127})?` +
+			`(?:@[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9A-Fa-f]{32,})?$`,
+	)
+)
+
+// EnvironmentFieldError is a single problem found by Environment.Validate. Field names the offending field, with
+// an index for list elements, for example Repos[1] or EnvVars[0].Name.
+type EnvironmentFieldError struct {
+	Field   string `json:"Field"`
+	Message string `json:"Message"`
+}
+
+func (e *EnvironmentFieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// EnvironmentValidationError is returned by Environment.Validate when the environment is invalid. It lists every
+// problem found, rather than only the first.
+type EnvironmentValidationError struct {
+	Errors []*EnvironmentFieldError `json:"Errors"`
+}
+
+func (e *EnvironmentValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+	return "invalid environment: " + strings.Join(msgs, "; ")
+}
+
+func (e *EnvironmentValidationError) Unwrap() []error {
+	ret := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		ret = append(ret, err)
+	}
+	return ret
+}
+
+func (e *EnvironmentValidationError) add(field string, format string, args ...any) {
+	e.Errors = append(e.Errors, &EnvironmentFieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// EnvironmentResolver checks that the objects an environment refers to exist.
+type EnvironmentResolver interface {
+	RunnerExists(ctx context.Context, tenantID string, runnerID string) (bool, error)
+	GithubConnectionExists(ctx context.Context, tenantID string, connectionID string) (bool, error)
+}
+
+// NewEnvironmentResolver returns an EnvironmentResolver that looks up runners and GitHub connections with client.
+// Deleted runners don't exist.
+func NewEnvironmentResolver(client *Client, flags FeatureFlags, auth DelegatedAuthInfo) EnvironmentResolver {
+	return &clientEnvironmentResolver{client: client, flags: flags, auth: auth}
+}
+
+type clientEnvironmentResolver struct {
+	client *Client
+	flags  FeatureFlags
+	auth   DelegatedAuthInfo
+}
+
+func (r *clientEnvironmentResolver) RunnerExists(ctx context.Context, tenantID string, runnerID string) (bool, error) {
+	_, err := r.client.GetRunner(
+		ctx, &GetRunnerRequest{
+			FeatureFlags:      r.flags,
+			DelegatedAuthInfo: r.auth,
+			TenantID:          tenantID,
+			RunnerID:          runnerID,
+		},
+	)
+	return existsResult(err)
+}
+
+func (r *clientEnvironmentResolver) GithubConnectionExists(
+	ctx context.Context,
+	tenantID string,
+	connectionID string,
+) (bool, error) {
+	_, err := r.client.GetGithubConnection(
+		ctx, &GetGithubConnectionRequest{
+			FeatureFlags:      r.flags,
+			DelegatedAuthInfo: r.auth,
+			TenantID:          tenantID,
+			ConnectionID:      connectionID,
+		},
+	)
+	return existsResult(err)
+}
+
+func existsResult(err error) (bool, error) {
+	switch {
+	case err == nil:
+		return true, nil
+	case isNotFound(err):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// Validate checks the environment for problems the service would reject, or that would make tasks fail when they
+// run in it. If resolver is not nil, it also checks that RunnerID and GithubConnectionID refer to existing objects.
+// The CLI validates environments before creating or updating them; runners may validate them before executing a
+// task.
+//
+// Validate returns an *EnvironmentValidationError listing every problem found, or the error returned by resolver.
+func (e *Environment) Validate(ctx context.Context, resolver EnvironmentResolver) error {
+	verr := &EnvironmentValidationError{}
+
+	if strings.TrimSpace(e.Name) == "" {
+		verr.add("Name", "is required")
+	}
+	if len(e.SetupScript) > MaxEnvironmentSetupScript {
+		verr.add("SetupScript", "must be at most %d bytes", MaxEnvironmentSetupScript)
+	}
+	if e.DockerImage != "" && !dockerImageRE.MatchString(e.DockerImage) {
+		verr.add("DockerImage", "%q is not a valid image reference", e.DockerImage)
+	}
+
+	if len(e.Repos) > MaxEnvironmentRepos {
+		verr.add("Repos", "at most %d repos can be specified", MaxEnvironmentRepos)
+	}
+	seenRepos := make(map[string]bool, len(e.Repos))
+	for i, repo := range e.Repos {
+		field := fmt.Sprintf("Repos[%d]", i)
+		if !isValidRepo(repo) {
+			verr.add(field, "%q must be of the form owner/name", repo)
+			continue
+		}
+		if seenRepos[strings.ToLower(repo)] {
+			verr.add(field, "duplicate repo %s", repo)
+		}
+		seenRepos[strings.ToLower(repo)] = true
+	}
+
+	if len(e.AllowedHosts) > MaxEnvironmentAllowedHosts {
+		verr.add("AllowedHosts", "at most %d hosts can be specified", MaxEnvironmentAllowedHosts)
+	}
+	seenHosts := make(map[string]bool, len(e.AllowedHosts))
+	for i, host := range e.AllowedHosts {
+		field := fmt.Sprintf("AllowedHosts[%d]", i)
+		if !isValidHostPattern(host) {
+			verr.add(field, "%q must be a host name, optionally prefixed with *.", host)
+			continue
+		}
+		if seenHosts[strings.ToLower(host)] {
+			verr.add(field, "duplicate host %s", host)
+		}
+		seenHosts[strings.ToLower(host)] = true
+	}
+
+	if len(e.EnvVars) > MaxEnvironmentEnvVars {
+		verr.add("EnvVars", "at most %d env vars can be specified", MaxEnvironmentEnvVars)
+	}
+	seenEnvVars := make(map[string]bool, len(e.EnvVars))
+	for i, envVar := range e.EnvVars {
+		field := fmt.Sprintf("EnvVars[%d].Name", i)
+		if !envVarNameRE.MatchString(envVar.Name) {
+			verr.add(field, "%q must contain only letters, digits and _, and not start with a digit", envVar.Name)
+			continue
+		}
+		if seenEnvVars[envVar.Name] {
+			verr.add(field, "duplicate env var %s", envVar.Name)
+		}
+		seenEnvVars[envVar.Name] = true
+	}
+
+	if resolver != nil {
+		if id := defaultEnvironmentID(e.RunnerID); id != environmentDefaultID {
+			ok, err := resolver.RunnerExists(ctx, e.TenantID, id)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				verr.add("RunnerID", "runner %s does not exist", id)
+			}
+		}
+		if id := defaultEnvironmentID(e.GithubConnectionID); id != environmentDefaultID {
+			ok, err := resolver.GithubConnectionExists(ctx, e.TenantID, id)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				verr.add("GithubConnectionID", "github connection %s does not exist", id)
+			}
+		}
+	}
+
+	if len(verr.Errors) > 0 {
+		return verr
+	}
+	return nil
+}
+
+func isValidRepo(repo string) bool {
+	owner, name, ok := strings.Cut(repo, "/")
+	return ok &&
+		githubOwnerRE.MatchString(owner) &&
+		githubRepoRE.MatchString(name) &&
+		name != "." && name != ".."
+}
+
+// isValidHostPattern reports whether host is a DNS name, or a DNS name prefixed with the wildcard label *. IP
+// addresses are rejected, since allowed hosts must present publicly trusted certificates.
+func isValidHostPattern(host string) bool {
+	host = strings.TrimPrefix(host, "*.")
+	if len(host) > 253 {
+		return false
+	}
+	labels := strings.Split(host, ".")
+	if len(labels) < 2 {
+		return false
+	}
+	for _, label := range labels {
+		if !hostLabelRE.MatchString(label) {
+			return false
+		}
+	}
+	tld := labels[len(labels)-1]
+	return strings.Trim(tld, "0123456789") != ""
+}
@@ -0,0 +1,140 @@
+package p42_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/plan42-ai/sdk-go/internal/util"
+	"github.com/plan42-ai/sdk-go/p42"
+	"github.com/stretchr/testify/require"
+)
+
+func validEnvironment() *p42.Environment {
+	return &p42.Environment{
+		TenantID:     "ten",
+		Name:         "backend",
+		Repos:        []string{"org/api", "my-org/web.site"},
+		DockerImage:  "ghcr.io/org/image:1.2",
+		AllowedHosts: []string{"proxy.golang.org", "*.npmjs.org"},
+		EnvVars:      []p42.EnvVar{{Name: "LOG_LEVEL", Value: "debug"}, {Name: "_TOKEN2", Value: "env://TOKEN", IsSecret: true}},
+	}
+}
+
+func TestEnvironmentValidate(t *testing.T) {
+	t.Parallel()
+	require.NoError(t, validEnvironment().Validate(context.Background(), nil))
+
+	images := []string{
+		"ubuntu",
+		"ubuntu:24.04",
+		"library/ubuntu",
+		"localhost:5000/team/app_1",
+		"123456789012.dkr.ecr.us-west-2.amazonaws.com/app:v1",
+		"alpine@sha256:" + strings.Repeat("a", 64),
+	}
+	for _, image := range images {
+		env := validEnvironment()
+		env.DockerImage = image
+		require.NoError(t, env.Validate(context.Background(), nil), image)
+	}
+}
+
+func TestEnvironmentValidateErrors(t *testing.T) {
+	t.Parallel()
+	env := &p42.Environment{
+		Name:         " ",
+		Repos:        []string{"org/api", "Org/API", "api", "org/..", "-org/api"},
+		DockerImage:  "Ubuntu:latest",
+		AllowedHosts: []string{"https://example.com", "example.com", "EXAMPLE.com", "localhost", "10.0.0.1", "*.*.com"},
+		EnvVars:      []p42.EnvVar{{Name: "A"}, {Name: "A"}, {Name: "1A"}, {Name: "A-B"}},
+		SetupScript:  strings.Repeat("x", p42.MaxEnvironmentSetupScript+1),
+	}
+	err := env.Validate(context.Background(), nil)
+	var verr *p42.EnvironmentValidationError
+	require.ErrorAs(t, err, &verr)
+
+	var fields []string
+	for _, fieldErr := range verr.Errors {
+		fields = append(fields, fieldErr.Field)
+	}
+	require.Equal(
+		t, []string{
+			"Name",
+			"SetupScript",
+			"DockerImage",
+			"Repos[1]",
+			"Repos[2]",
+			"Repos[3]",
+			"Repos[4]",
+			"AllowedHosts[0]",
+			"AllowedHosts[2]",
+			"AllowedHosts[3]",
+			"AllowedHosts[4]",
+			"AllowedHosts[5]",
+			"EnvVars[1].Name",
+			"EnvVars[2].Name",
+			"EnvVars[3].Name",
+		}, fields,
+	)
+	require.Equal(t, "Repos[1]: duplicate repo Org/API", verr.Errors[3].Error())
+	require.Equal(t, `Repos[2]: "api" must be of the form owner/name`, verr.Errors[4].Error())
+
+	// Each problem can be matched on its own.
+	var fieldErr *p42.EnvironmentFieldError
+	require.ErrorAs(t, err, &fieldErr)
+	require.Equal(t, "Name", fieldErr.Field)
+	require.True(t, strings.HasPrefix(err.Error(), "invalid environment: Name: is required; SetupScript: must be at most"))
+
+	env = validEnvironment()
+	env.Repos = make([]string, p42.MaxEnvironmentRepos+1)
+	for i := range env.Repos {
+		env.Repos[i] = "org/repo" + strings.Repeat("x", i)
+	}
+	require.EqualError(t, env.Validate(context.Background(), nil), "invalid environment: Repos: at most 50 repos can be specified")
+}
+
+func TestEnvironmentValidateReferences(t *testing.T) {
+	t.Parallel()
+	mux := http.NewServeMux()
+	mux.HandleFunc(
+		"GET /v1/tenants/ten/runners/runner-1", func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(`{"TenantId":"ten","RunnerId":"runner-1"}`))
+		},
+	)
+	mux.HandleFunc(
+		"GET /v1/tenants/ten/github-connections/{id}", func(w http.ResponseWriter, r *http.Request) {
+			if r.PathValue("id") == "broken" {
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = w.Write([]byte(`{"ResponseCode":500,"Message":"boom"}`))
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"ResponseCode":404,"Message":"not found"}`))
+		},
+	)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	resolver := p42.NewEnvironmentResolver(p42.NewClient(srv.URL), p42.FeatureFlags{}, p42.DelegatedAuthInfo{})
+
+	env := validEnvironment()
+	env.RunnerID = util.Pointer("runner-1")
+	env.GithubConnectionID = util.Pointer("default")
+	require.NoError(t, env.Validate(context.Background(), resolver))
+
+	env.GithubConnectionID = util.Pointer("conn-1")
+	require.EqualError(
+		t, env.Validate(context.Background(), resolver),
+		"invalid environment: GithubConnectionID: github connection conn-1 does not exist",
+	)
+
+	// Errors looking up references are returned as is, rather than reported as problems with the environment.
+	env.GithubConnectionID = util.Pointer("broken")
+	err := env.Validate(context.Background(), resolver)
+	var verr *p42.EnvironmentValidationError
+	require.False(t, errors.As(err, &verr))
+	require.EqualError(t, err, "boom")
+}
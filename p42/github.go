@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"iter"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -474,6 +475,138 @@ func (c *Client) ListOrgsForGithubConnection(
 	return &out, nil
 }
 
+// SearchReposRequest represents the request parameters for SearchRepos.
+type SearchReposRequest struct {
+	FeatureFlags
+	DelegatedAuthInfo
+
+	TenantID     string
+	ConnectionID string
+	OrgName      string
+	Search       string
+	MaxResults   *int
+	Token        *string
+}
+
+// GetField retrieves the value of a field by name.
+// nolint: goconst
+func (r *SearchReposRequest) GetField(name string) (any, bool) {
+	switch name {
+	case "TenantID":
+		return r.TenantID, true
+	case "ConnectionID":
+		return r.ConnectionID, true
+	case "OrgName":
+		return r.OrgName, true
+	case "Search":
+		return r.Search, true
+	case "MaxResults":
+		return EvalNullable(r.MaxResults)
+	case "Token":
+		return EvalNullable(r.Token)
+	default:
+		return nil, false
+	}
+}
+
+// SearchRepos searches the repositories of a GitHub org associated with a GitHub connection. It returns a single
+// page of repository names; use SearchReposAll to iterate over every page.
+func (c *Client) SearchRepos(ctx context.Context, req *SearchReposRequest) (*List[string], error) {
+	if req == nil {
+		return nil, fmt.Errorf("req is nil")
+	}
+	if req.TenantID == "" {
+		return nil, fmt.Errorf("tenant id is required")
+	}
+	if req.ConnectionID == "" {
+		return nil, fmt.Errorf("connection id is required")
+	}
+	if req.OrgName == "" {
+		return nil, fmt.Errorf("org name is required")
+	}
+	if req.MaxResults != nil && (*req.MaxResults < 1 || *req.MaxResults >= 500) {
+		return nil, fmt.Errorf("max results must be between 1 and 499")
+	}
+
+	u := c.BaseURL.JoinPath(
+		"v1",
+		"tenants",
+		url.PathEscape(req.TenantID),
+		"github-connections",
+		url.PathEscape(req.ConnectionID),
+		"orgs",
+		url.PathEscape(req.OrgName),
+		"repos",
+	)
+	q := u.Query()
+	q.Set("search", req.Search)
+	if req.MaxResults != nil {
+		q.Set("maxResults", strconv.Itoa(*req.MaxResults))
+	}
+	if req.Token != nil {
+		q.Set("token", *req.Token)
+	}
+	u.RawQuery = q.Encode()
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	processFeatureFlags(httpReq, req.FeatureFlags)
+
+	err = c.authenticate(req.DelegatedAuthInfo, httpReq)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient().Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, decodeError(resp)
+	}
+
+	var out List[string]
+	err = json.NewDecoder(resp.Body).Decode(&out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SearchReposAll returns every repository matching a search, starting at req.Token and fetching pages lazily as the
+// sequence is iterated. If a request fails, the error is yielded with an empty name and iteration stops. req is not
+// modified.
+func (c *Client) SearchReposAll(ctx context.Context, req *SearchReposRequest) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		if req == nil {
+			yield("", fmt.Errorf("req is nil"))
+			return
+		}
+		page := *req
+		for {
+			resp, err := c.SearchRepos(ctx, &page)
+			if err != nil {
+				yield("", err)
+				return
+			}
+			for _, repo := range resp.Items {
+				if !yield(repo, nil) {
+					return
+				}
+			}
+			if resp.NextToken == nil {
+				return
+			}
+			page.Token = resp.NextToken
+		}
+	}
+}
+
 // UpdateGithubConnectionRequest contains fields for updating a GitHub connection.
 type UpdateGithubConnectionRequest struct {
 	FeatureFlags
@@ -489,9 +622,36 @@ type UpdateGithubConnectionRequest struct {
 	GithubUserID    *int       `json:"GithubUserID,omitempty"`
 	OAuthToken      *string    `json:"OAuthToken,omitempty"`
 	RefreshToken    *string    `json:"RefreshToken,omitempty"`
+	TokenExpiry     *time.Time `json:"TokenExpiry,omitempty"`
 	State           *string    `json:"State,omitempty"`
 	StateExpiry     *time.Time `json:"StateExpiry,omitempty"`
 	Name            *string    `json:"Name,omitempty"`
+
+	// ClearTokenExpiry removes the stored token expiry, for tokens that do not expire. It is sent as an empty
+	// TokenExpiry and can't be combined with TokenExpiry.
+	ClearTokenExpiry bool `json:"-"`
+}
+
+func (r UpdateGithubConnectionRequest) MarshalJSON() ([]byte, error) {
+	type updateGithubConnectionRequestAlias UpdateGithubConnectionRequest
+	return marshalClearingTokenExpiry(updateGithubConnectionRequestAlias(r), r.ClearTokenExpiry)
+}
+
+// marshalClearingTokenExpiry marshals v and, if clearExpiry is set, sends TokenExpiry as "", which clears it.
+func marshalClearingTokenExpiry(v any, clearExpiry bool) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || !clearExpiry {
+		return data, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	if _, ok := fields["TokenExpiry"]; ok {
+		return nil, fmt.Errorf("TokenExpiry and ClearTokenExpiry can't both be set")
+	}
+	fields["TokenExpiry"] = json.RawMessage(`""`)
+	return json.Marshal(fields)
 }
 
 func (r *UpdateGithubConnectionRequest) GetVersion() int {
@@ -520,6 +680,8 @@ func (r *UpdateGithubConnectionRequest) GetField(name string) (any, bool) {
 		return EvalNullable(r.OAuthToken)
 	case "RefreshToken":
 		return EvalNullable(r.RefreshToken)
+	case "TokenExpiry":
+		return EvalNullable(r.TokenExpiry)
 	case "State":
 		return EvalNullable(r.State)
 	case "StateExpiry":
@@ -722,6 +884,15 @@ type UpdateTenantGithubCredsRequest struct {
 	StateExpiry     *time.Time `json:"StateExpiry,omitempty"`
 	GithubUserLogin *string    `json:"GithubUserLogin,omitempty"`
 	GithubUserID    *int       `json:"GithubUserID,omitempty"`
+
+	// ClearTokenExpiry removes the stored token expiry, for tokens that do not expire. It is sent as an empty
+	// TokenExpiry and can't be combined with TokenExpiry.
+	ClearTokenExpiry bool `json:"-"`
+}
+
+func (r UpdateTenantGithubCredsRequest) MarshalJSON() ([]byte, error) {
+	type updateTenantGithubCredsRequestAlias UpdateTenantGithubCredsRequest
+	return marshalClearingTokenExpiry(updateTenantGithubCredsRequestAlias(r), r.ClearTokenExpiry)
 }
 
 // GetField retrieves the value of a field by name.
@@ -0,0 +1,297 @@
+package p42
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/plan42-ai/clock"
+)
+
+// DefaultGithubAPIURL is the base URL of the GitHub REST API.
+const DefaultGithubAPIURL = "https://api.github.com"
+
+// GithubAppConfig holds configuration for GithubApp.
+type GithubAppConfig struct {
+	// AppID is the GitHub App's ID (or client ID). It is the issuer of the app JWT.
+	AppID string
+
+	// PrivateKey is the app's PEM encoded RSA private key, in PKCS #1 or PKCS #8 form.
+	PrivateKey []byte
+
+	// BaseURL is the GitHub API URL. Defaults to DefaultGithubAPIURL. Set it for GitHub Enterprise Server.
+	BaseURL string
+
+	// HTTPClient is used to mint installation tokens. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+
+	// Clock is used to sign JWTs and check token expiry. Defaults to the real clock.
+	Clock clock.Clock
+
+	// RefreshBefore is how long before expiry a cached installation token is replaced. Defaults to 5 minutes.
+	RefreshBefore time.Duration
+}
+
+// GithubInstallationToken is an installation access token for a GitHub App installation.
+type GithubInstallationToken struct {
+	Token               string            `json:"token"`
+	ExpiresAt           time.Time         `json:"expires_at"`
+	Permissions         map[string]string `json:"permissions,omitempty"`
+	RepositorySelection string            `json:"repository_selection,omitempty"`
+}
+
+// GithubApp mints installation access tokens for the GithubOrgs a GitHub App is installed in. Tokens are cached per
+// installation until they near expiry. It is safe for concurrent use.
+type GithubApp struct {
+	cfg     GithubAppConfig
+	key     *rsa.PrivateKey
+	baseURL *url.URL
+
+	mu            sync.Mutex
+	installations map[int]*githubInstallation
+}
+
+// githubInstallation caches the token of one installation. mu is held while minting, so that concurrent requests for
+// the same installation mint a single token.
+type githubInstallation struct {
+	mu    sync.Mutex
+	token *GithubInstallationToken
+}
+
+const (
+	defaultGithubAppRefreshBefore = 5 * time.Minute
+
+	// GitHub rejects app JWTs valid for more than 10 minutes. iat is backdated to allow for clock drift.
+	githubAppJWTTTL      = 9 * time.Minute
+	githubAppJWTBackdate = time.Minute
+)
+
+// NewGithubApp creates a GithubApp.
+func NewGithubApp(cfg *GithubAppConfig) (*GithubApp, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("cfg is nil")
+	}
+	if cfg.AppID == "" {
+		return nil, fmt.Errorf("app id is required")
+	}
+	key, err := parseGithubAppKey(cfg.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	a := &GithubApp{
+		cfg:           *cfg,
+		key:           key,
+		installations: make(map[int]*githubInstallation),
+	}
+	if a.cfg.BaseURL == "" {
+		a.cfg.BaseURL = DefaultGithubAPIURL
+	}
+	a.baseURL, err = url.Parse(a.cfg.BaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base url: %w", err)
+	}
+	if a.cfg.HTTPClient == nil {
+		a.cfg.HTTPClient = http.DefaultClient
+	}
+	if a.cfg.Clock == nil {
+		a.cfg.Clock = clock.NewRealClock()
+	}
+	if a.cfg.RefreshBefore <= 0 {
+		a.cfg.RefreshBefore = defaultGithubAppRefreshBefore
+	}
+	return a, nil
+}
+
+func parseGithubAppKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("private key must be PEM encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key must be an RSA key")
+	}
+	return key, nil
+}
+
+// JWT returns a newly signed app JWT, used to authenticate as the app itself.
+func (a *GithubApp) JWT() (string, error) {
+	now := a.cfg.Clock.Now()
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(
+		map[string]any{
+			"iss": a.cfg.AppID,
+			"iat": now.Add(-githubAppJWTBackdate).Unix(),
+			"exp": now.Add(githubAppJWTTTL).Unix(),
+		},
+	)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// InstallationToken returns an installation access token for org, minting a new one if there is no cached token or
+// the cached token expires within RefreshBefore.
+func (a *GithubApp) InstallationToken(ctx context.Context, org *GithubOrg) (*GithubInstallationToken, error) {
+	if org == nil {
+		return nil, fmt.Errorf("org is nil")
+	}
+	if org.Deleted {
+		return nil, fmt.Errorf("org %s is deleted", org.OrgName)
+	}
+	if org.InstallationID <= 0 {
+		return nil, fmt.Errorf("org %s has no installation id", org.OrgName)
+	}
+
+	inst := a.installation(org.InstallationID)
+	inst.mu.Lock()
+	defer inst.mu.Unlock()
+	if inst.token != nil && a.cfg.Clock.Now().Add(a.cfg.RefreshBefore).Before(inst.token.ExpiresAt) {
+		return inst.token, nil
+	}
+
+	token, err := a.mint(ctx, org.InstallationID)
+	if err != nil {
+		return nil, err
+	}
+	inst.token = token
+	return token, nil
+}
+
+// Invalidate discards the cached token for org, so that the next request mints a new one. It does nothing if org is
+// nil.
+func (a *GithubApp) Invalidate(org *GithubOrg) {
+	if org == nil {
+		return
+	}
+	inst := a.installation(org.InstallationID)
+	inst.mu.Lock()
+	defer inst.mu.Unlock()
+	inst.token = nil
+}
+
+func (a *GithubApp) installation(id int) *githubInstallation {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	inst, ok := a.installations[id]
+	if !ok {
+		inst = &githubInstallation{}
+		a.installations[id] = inst
+	}
+	return inst
+}
+
+func (a *GithubApp) mint(ctx context.Context, installationID int) (*GithubInstallationToken, error) {
+	jwt, err := a.JWT()
+	if err != nil {
+		return nil, err
+	}
+
+	u := a.baseURL.JoinPath("app", "installations", strconv.Itoa(installationID), "access_tokens")
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", "application/vnd.github+json")
+	httpReq.Header.Set("Authorization", "Bearer "+jwt)
+
+	resp, err := a.cfg.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("unable to mint installation token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		var body struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		return nil, fmt.Errorf("unable to mint installation token: status %d: %s", resp.StatusCode, body.Message)
+	}
+
+	var token GithubInstallationToken
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, fmt.Errorf("unable to decode installation token: %w", err)
+	}
+	if token.Token == "" {
+		return nil, fmt.Errorf("unable to mint installation token: response has no token")
+	}
+	return &token, nil
+}
+
+// Transport returns an http.RoundTripper that authenticates requests with an installation token for org. base
+// sends the requests; if nil, http.DefaultTransport is used. A 401 response discards the cached token, so that the
+// next request uses a new one. Only requests to the app's BaseURL carry the token; requests to any other host are
+// sent through base unchanged.
+func (a *GithubApp) Transport(org *GithubOrg, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &githubAppTransport{app: a, org: org, base: base}
+}
+
+type githubAppTransport struct {
+	app  *GithubApp
+	org  *GithubOrg
+	base http.RoundTripper
+}
+
+func (t *githubAppTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.app.isAPIRequest(req) {
+		return t.base.RoundTrip(req)
+	}
+	token, err := t.app.InstallationToken(req.Context(), t.org)
+	if err != nil {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, err
+	}
+
+	// RoundTrippers must not modify the request they are given.
+	authed := req.Clone(req.Context())
+	authed.Header.Set("Authorization", "Bearer "+token.Token)
+	resp, err := t.base.RoundTrip(authed)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		t.app.Invalidate(t.org)
+	}
+	return resp, err
+}
+
+// isAPIRequest reports whether req is sent to the GitHub API at BaseURL, so that installation tokens never leak to
+// other hosts.
+func (a *GithubApp) isAPIRequest(req *http.Request) bool {
+	return req.URL != nil &&
+		strings.EqualFold(req.URL.Scheme, a.baseURL.Scheme) &&
+		strings.EqualFold(req.URL.Host, a.baseURL.Host)
+}
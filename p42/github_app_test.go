@@ -0,0 +1,252 @@
+package p42_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/plan42-ai/clock"
+	"github.com/plan42-ai/sdk-go/p42"
+	"github.com/stretchr/testify/require"
+)
+
+var githubAppNow = time.Date(2025, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+// fakeGithubAPI stands in for the GitHub API. It mints installation tokens valid for an hour and serves a
+// /repos endpoint that requires one.
+type fakeGithubAPI struct {
+	*httptest.Server
+	t     *testing.T
+	key   *rsa.PublicKey
+	clock *clock.FakeClock
+
+	mints   atomic.Int32
+	revoked atomic.Bool
+}
+
+func newFakeGithubAPI(t *testing.T, key *rsa.PublicKey, clk *clock.FakeClock) *fakeGithubAPI {
+	t.Helper()
+	f := &fakeGithubAPI{t: t, key: key, clock: clk}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /app/installations/{id}/access_tokens", f.mint)
+	mux.HandleFunc("GET /repos/org/repo", f.repo)
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeGithubAPI) mint(w http.ResponseWriter, r *http.Request) {
+	jwt, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	require.True(f.t, ok)
+	parts := strings.Split(jwt, ".")
+	require.Len(f.t, parts, 3)
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(f.t, err)
+	require.NoError(f.t, rsa.VerifyPKCS1v15(f.key, crypto.SHA256, digest[:], signature))
+
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(f.t, err)
+	var claims struct {
+		Iss string `json:"iss"`
+		Iat int64  `json:"iat"`
+		Exp int64  `json:"exp"`
+	}
+	require.NoError(f.t, json.Unmarshal(claimsJSON, &claims))
+	require.Equal(f.t, "123", claims.Iss)
+	require.LessOrEqual(f.t, claims.Exp-claims.Iat, int64(600))
+
+	if r.PathValue("id") != "42" {
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(map[string]string{"message": "Not Found"})
+		return
+	}
+	n := f.mints.Add(1)
+	f.revoked.Store(false)
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(
+		p42.GithubInstallationToken{
+			Token:       "ghs_" + strconv.Itoa(int(n)),
+			ExpiresAt:   f.clock.Now().Add(time.Hour),
+			Permissions: map[string]string{"contents": "read"},
+		},
+	)
+}
+
+func (f *fakeGithubAPI) repo(w http.ResponseWriter, r *http.Request) {
+	if f.revoked.Load() || r.Header.Get("Authorization") != "Bearer ghs_"+strconv.Itoa(int(f.mints.Load())) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	_, _ = w.Write([]byte(`{"full_name":"org/repo"}`))
+}
+
+func newTestGithubApp(t *testing.T) (*p42.GithubApp, *fakeGithubAPI, *clock.FakeClock) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	clk := clock.NewFakeClock(githubAppNow)
+	api := newFakeGithubAPI(t, &key.PublicKey, clk)
+
+	app, err := p42.NewGithubApp(
+		&p42.GithubAppConfig{
+			AppID:      "123",
+			PrivateKey: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+			BaseURL:    api.URL,
+			Clock:      clk,
+		},
+	)
+	require.NoError(t, err)
+	return app, api, clk
+}
+
+func TestGithubAppInstallationToken(t *testing.T) {
+	t.Parallel()
+	app, api, clk := newTestGithubApp(t)
+	org := &p42.GithubOrg{OrgName: "org", InstallationID: 42}
+
+	var wg sync.WaitGroup
+	tokens := make([]*p42.GithubInstallationToken, 5)
+	errs := make([]error, 5)
+	for i := range tokens {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tokens[i], errs[i] = app.InstallationToken(context.Background(), org)
+		}()
+	}
+	wg.Wait()
+	for i := range tokens {
+		require.NoError(t, errs[i])
+		require.Equal(t, "ghs_1", tokens[i].Token)
+	}
+	require.Equal(t, int32(1), api.mints.Load())
+
+	// The cached token is replaced once it expires within RefreshBefore.
+	clk.Advance(50 * time.Minute)
+	token, err := app.InstallationToken(context.Background(), org)
+	require.NoError(t, err)
+	require.Equal(t, "ghs_1", token.Token)
+	clk.Advance(6 * time.Minute)
+	token, err = app.InstallationToken(context.Background(), org)
+	require.NoError(t, err)
+	require.Equal(t, "ghs_2", token.Token)
+	require.Equal(t, map[string]string{"contents": "read"}, token.Permissions)
+}
+
+func TestGithubAppInstallationTokenErrors(t *testing.T) {
+	t.Parallel()
+	app, _, _ := newTestGithubApp(t)
+
+	tests := []struct {
+		org *p42.GithubOrg
+		msg string
+	}{
+		{nil, "org is nil"},
+		{&p42.GithubOrg{OrgName: "org"}, "org org has no installation id"},
+		{&p42.GithubOrg{OrgName: "org", InstallationID: 42, Deleted: true}, "org org is deleted"},
+		{&p42.GithubOrg{OrgName: "org", InstallationID: 7}, "unable to mint installation token: status 404: Not Found"},
+	}
+	for _, tc := range tests {
+		_, err := app.InstallationToken(context.Background(), tc.org)
+		require.EqualError(t, err, tc.msg)
+	}
+	require.NotPanics(t, func() { app.Invalidate(nil) })
+}
+
+func TestGithubAppTransport(t *testing.T) {
+	t.Parallel()
+	app, api, _ := newTestGithubApp(t)
+	org := &p42.GithubOrg{OrgName: "org", InstallationID: 42}
+	client := &http.Client{Transport: app.Transport(org, nil)}
+
+	get := func() int {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, api.URL+"/repos/org/repo", nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		require.Empty(t, req.Header.Get("Authorization"))
+		return resp.StatusCode
+	}
+
+	require.Equal(t, http.StatusOK, get())
+	require.Equal(t, http.StatusOK, get())
+	require.Equal(t, int32(1), api.mints.Load())
+
+	// A revoked token is discarded after the 401, and the next request mints a new one.
+	api.revoked.Store(true)
+	require.Equal(t, http.StatusUnauthorized, get())
+	require.Equal(t, http.StatusOK, get())
+	require.Equal(t, int32(2), api.mints.Load())
+}
+
+func TestGithubAppTransportOtherHost(t *testing.T) {
+	t.Parallel()
+	app, api, _ := newTestGithubApp(t)
+	org := &p42.GithubOrg{OrgName: "org", InstallationID: 42}
+	client := &http.Client{Transport: app.Transport(org, nil)}
+
+	var auth atomic.Value
+	other := httptest.NewServer(
+		http.HandlerFunc(
+			func(_ http.ResponseWriter, r *http.Request) {
+				auth.Store(r.Header.Get("Authorization"))
+			},
+		),
+	)
+	t.Cleanup(other.Close)
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, other.URL+"/repos/org/repo", nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "", auth.Load())
+	require.Equal(t, int32(0), api.mints.Load())
+}
+
+func TestNewGithubAppValidation(t *testing.T) {
+	t.Parallel()
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	ecDER, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	require.NoError(t, err)
+
+	tests := []struct {
+		cfg *p42.GithubAppConfig
+		msg string
+	}{
+		{nil, "cfg is nil"},
+		{&p42.GithubAppConfig{}, "app id is required"},
+		{&p42.GithubAppConfig{AppID: "123", PrivateKey: []byte("nope")}, "private key must be PEM encoded"},
+		{
+			&p42.GithubAppConfig{
+				AppID:      "123",
+				PrivateKey: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: ecDER}),
+			},
+			"private key must be an RSA key",
+		},
+	}
+	for _, tc := range tests {
+		_, err := p42.NewGithubApp(tc.cfg)
+		require.EqualError(t, err, tc.msg)
+	}
+}
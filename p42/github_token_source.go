@@ -0,0 +1,425 @@
+package p42
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/plan42-ai/clock"
+	"github.com/plan42-ai/sdk-go/internal/util"
+)
+
+// DefaultGithubTokenURL is GitHub's OAuth token endpoint.
+const DefaultGithubTokenURL = "https://github.com/login/oauth/access_token"
+
+// ErrGithubTokenUnavailable is returned by GithubTokenSource.Token when the stored token can't be used or refreshed,
+// for example because it has expired and there is no refresh token. The user must re-authorize the connection.
+var ErrGithubTokenUnavailable = errors.New("github token unavailable")
+
+// GithubTokenProvider supplies a GitHub token. GithubTokenSource implements it.
+type GithubTokenProvider interface {
+	Token(ctx context.Context) (string, error)
+}
+
+var _ GithubTokenProvider = (*GithubTokenSource)(nil)
+
+// GithubTokenSourceConfig holds configuration for GithubTokenSource.
+type GithubTokenSourceConfig struct {
+	Client   *Client
+	TenantID string
+
+	// ConnectionID selects the GithubConnection whose token is used. If empty, the tenant's TenantGithubCreds are
+	// used instead.
+	ConnectionID string
+
+	FeatureFlags  FeatureFlags
+	DelegatedAuth DelegatedAuthInfo
+
+	// ClientID and ClientSecret identify the GitHub App that issued the token. They are sent to TokenURL when
+	// refreshing.
+	ClientID     string
+	ClientSecret string
+
+	// TokenURL is the OAuth token endpoint. Defaults to DefaultGithubTokenURL.
+	TokenURL string
+
+	// HTTPClient is used to call TokenURL. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+
+	// Clock is used to check expiry. Defaults to the real clock.
+	Clock clock.Clock
+
+	// RefreshBefore is how long before expiry a token is refreshed. Defaults to 5 minutes.
+	RefreshBefore time.Duration
+
+	// MaxConflictRetries is the number of times saving a rotated token is retried after a version conflict.
+	// Defaults to 3.
+	MaxConflictRetries int
+}
+
+// GithubTokenSource returns a valid GitHub OAuth token for a GithubConnection or a tenant's TenantGithubCreds. When
+// the stored token is near expiry it is refreshed against the OAuth token endpoint, and the rotated tokens are saved
+// back with optimistic concurrency. Concurrent callers share a single refresh. It is safe for concurrent use.
+type GithubTokenSource struct {
+	cfg   GithubTokenSourceConfig
+	store githubTokenStore
+
+	mu      sync.Mutex
+	current *githubTokenRecord
+	flight  *githubTokenFlight
+}
+
+type githubTokenFlight struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+// githubTokenRecord is the token state shared by GithubConnection and TenantGithubCreds.
+type githubTokenRecord struct {
+	OAuthToken   *string
+	RefreshToken *string
+	TokenExpiry  *time.Time
+	Version      int
+}
+
+type githubTokenStore interface {
+	get(ctx context.Context) (*githubTokenRecord, error)
+	update(ctx context.Context, version int, token *githubOAuthToken) (*githubTokenRecord, error)
+}
+
+const (
+	defaultGithubRefreshBefore      = 5 * time.Minute
+	defaultGithubMaxConflictRetries = 3
+)
+
+// NewGithubTokenSource creates a GithubTokenSource. The stored token is loaded on first use.
+func NewGithubTokenSource(cfg *GithubTokenSourceConfig) (*GithubTokenSource, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("cfg is nil")
+	}
+	if cfg.Client == nil {
+		return nil, fmt.Errorf("client is required")
+	}
+	if cfg.TenantID == "" {
+		return nil, fmt.Errorf("tenant id is required")
+	}
+	if cfg.ClientID == "" {
+		return nil, fmt.Errorf("client id is required")
+	}
+	s := &GithubTokenSource{cfg: *cfg}
+	if s.cfg.TokenURL == "" {
+		s.cfg.TokenURL = DefaultGithubTokenURL
+	}
+	if s.cfg.HTTPClient == nil {
+		s.cfg.HTTPClient = http.DefaultClient
+	}
+	if s.cfg.Clock == nil {
+		s.cfg.Clock = clock.NewRealClock()
+	}
+	if s.cfg.RefreshBefore <= 0 {
+		s.cfg.RefreshBefore = defaultGithubRefreshBefore
+	}
+	if s.cfg.MaxConflictRetries <= 0 {
+		s.cfg.MaxConflictRetries = defaultGithubMaxConflictRetries
+	}
+	if s.cfg.ConnectionID != "" {
+		s.store = &githubConnectionStore{cfg: &s.cfg}
+	} else {
+		s.store = &tenantGithubCredsStore{cfg: &s.cfg}
+	}
+	return s, nil
+}
+
+// Token returns a valid OAuth token, refreshing it first if it expires within RefreshBefore.
+func (s *GithubTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	if s.valid(s.current) {
+		token := *s.current.OAuthToken
+		s.mu.Unlock()
+		return token, nil
+	}
+	flight := s.flight
+	if flight == nil {
+		flight = &githubTokenFlight{done: make(chan struct{})}
+		s.flight = flight
+		// The refresh outlives a caller that gives up, so that the other callers sharing it still get a token.
+		go s.refresh(context.WithoutCancel(ctx), flight)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-flight.done:
+		return flight.token, flight.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// Invalidate discards the cached token, so that the next call to Token reloads it. Call it when GitHub rejects a
+// token returned by Token.
+func (s *GithubTokenSource) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current = nil
+}
+
+func (s *GithubTokenSource) valid(rec *githubTokenRecord) bool {
+	if rec == nil || rec.OAuthToken == nil || *rec.OAuthToken == "" {
+		return false
+	}
+	// Tokens without an expiry never expire.
+	return rec.TokenExpiry == nil || s.cfg.Clock.Now().Add(s.cfg.RefreshBefore).Before(*rec.TokenExpiry)
+}
+
+func (s *GithubTokenSource) refresh(ctx context.Context, flight *githubTokenFlight) {
+	rec, err := s.load(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err == nil {
+		s.current = rec
+		flight.token = *rec.OAuthToken
+	}
+	flight.err = err
+	s.flight = nil
+	close(flight.done)
+}
+
+// load fetches the stored token and, if it is near expiry, refreshes it and saves the rotated tokens.
+func (s *GithubTokenSource) load(ctx context.Context) (*githubTokenRecord, error) {
+	rec, err := s.store.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if s.valid(rec) {
+		// Another process may have already refreshed it.
+		return rec, nil
+	}
+	if rec.RefreshToken == nil || *rec.RefreshToken == "" {
+		return nil, fmt.Errorf("%w: token expired and no refresh token is stored", ErrGithubTokenUnavailable)
+	}
+
+	refreshToken := *rec.RefreshToken
+	token, err := s.exchange(ctx, refreshToken)
+	if errors.Is(err, ErrGithubTokenUnavailable) {
+		// Refresh tokens are single use, so GitHub rejects ours if another process redeemed it first. That process
+		// stores the rotated tokens, so reload them before giving up.
+		var latest *githubTokenRecord
+		latest, err = s.reloadRotated(ctx, refreshToken, err)
+		if err != nil {
+			return nil, err
+		}
+		if s.valid(latest) {
+			return latest, nil
+		}
+		rec, refreshToken = latest, *latest.RefreshToken
+		token, err = s.exchange(ctx, refreshToken)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		updated, err := s.store.update(ctx, rec.Version, token)
+		if err == nil {
+			return updated, nil
+		}
+		var conflictErr *ConflictError
+		if !errors.As(err, &conflictErr) || attempt >= s.cfg.MaxConflictRetries {
+			return nil, err
+		}
+
+		rec, err = s.store.get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		// If another process rotated the token concurrently, keep its tokens rather than overwriting them. Ours
+		// remain valid, but only one pair can be stored.
+		if (rec.RefreshToken == nil || *rec.RefreshToken != refreshToken) && s.valid(rec) {
+			return rec, nil
+		}
+	}
+}
+
+// reloadRotated fetches the stored token after GitHub rejected refreshToken. It returns the stored record if its
+// access token is valid or its refresh token differs from refreshToken, and rejected otherwise.
+func (s *GithubTokenSource) reloadRotated(
+	ctx context.Context,
+	refreshToken string,
+	rejected error,
+) (*githubTokenRecord, error) {
+	rec, err := s.store.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if s.valid(rec) {
+		return rec, nil
+	}
+	if rec.RefreshToken == nil || *rec.RefreshToken == "" || *rec.RefreshToken == refreshToken {
+		return nil, rejected
+	}
+	return rec, nil
+}
+
+// githubOAuthToken is the response of an OAuth refresh_token grant.
+type githubOAuthToken struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int    `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+
+	expiry *time.Time
+}
+
+func (s *GithubTokenSource) exchange(ctx context.Context, refreshToken string) (*githubOAuthToken, error) {
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", refreshToken)
+	form.Set("client_id", s.cfg.ClientID)
+	if s.cfg.ClientSecret != "" {
+		form.Set("client_secret", s.cfg.ClientSecret)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.cfg.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("unable to refresh github token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var token githubOAuthToken
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil && resp.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("unable to decode github token response: %w", err)
+	}
+	// GitHub reports OAuth errors with a 200 status, so check the error field as well as the status.
+	switch {
+	case token.Error == "bad_refresh_token":
+		return nil, fmt.Errorf("%w: %s", ErrGithubTokenUnavailable, token.ErrorDescription)
+	case token.Error != "":
+		return nil, fmt.Errorf("unable to refresh github token: %s: %s", token.Error, token.ErrorDescription)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("unable to refresh github token: unexpected status %d", resp.StatusCode)
+	case token.AccessToken == "":
+		return nil, fmt.Errorf("unable to refresh github token: response has no access token")
+	}
+	if token.ExpiresIn > 0 {
+		token.expiry = util.Pointer(s.cfg.Clock.Now().Add(time.Duration(token.ExpiresIn) * time.Second).UTC())
+	}
+	return &token, nil
+}
+
+type githubConnectionStore struct {
+	cfg *GithubTokenSourceConfig
+}
+
+func (g *githubConnectionStore) get(ctx context.Context) (*githubTokenRecord, error) {
+	req := &GetGithubConnectionRequest{
+		FeatureFlags:      g.cfg.FeatureFlags,
+		DelegatedAuthInfo: g.cfg.DelegatedAuth,
+		TenantID:          g.cfg.TenantID,
+		ConnectionID:      g.cfg.ConnectionID,
+	}
+	conn, err := g.cfg.Client.GetGithubConnection(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return githubConnectionRecord(conn), nil
+}
+
+func (g *githubConnectionStore) update(
+	ctx context.Context,
+	version int,
+	token *githubOAuthToken,
+) (*githubTokenRecord, error) {
+	req := &UpdateGithubConnectionRequest{
+		FeatureFlags:      g.cfg.FeatureFlags,
+		DelegatedAuthInfo: g.cfg.DelegatedAuth,
+		TenantID:          g.cfg.TenantID,
+		ConnectionID:      g.cfg.ConnectionID,
+		Version:           version,
+		OAuthToken:        util.Pointer(token.AccessToken),
+		TokenExpiry:       token.expiry,
+		ClearTokenExpiry:  token.expiry == nil,
+	}
+	if token.RefreshToken != "" {
+		req.RefreshToken = util.Pointer(token.RefreshToken)
+	}
+	conn, err := g.cfg.Client.UpdateGithubConnection(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return githubConnectionRecord(conn), nil
+}
+
+func githubConnectionRecord(conn *GithubConnection) *githubTokenRecord {
+	return &githubTokenRecord{
+		OAuthToken:   conn.OAuthToken,
+		RefreshToken: conn.RefreshToken,
+		TokenExpiry:  conn.TokenExpiry,
+		Version:      conn.Version,
+	}
+}
+
+type tenantGithubCredsStore struct {
+	cfg *GithubTokenSourceConfig
+}
+
+func (t *tenantGithubCredsStore) get(ctx context.Context) (*githubTokenRecord, error) {
+	req := &GetTenantGithubCredsRequest{
+		FeatureFlags:      t.cfg.FeatureFlags,
+		DelegatedAuthInfo: t.cfg.DelegatedAuth,
+		TenantID:          t.cfg.TenantID,
+	}
+	creds, err := t.cfg.Client.GetTenantGithubCreds(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return tenantGithubCredsRecord(creds), nil
+}
+
+func (t *tenantGithubCredsStore) update(
+	ctx context.Context,
+	version int,
+	token *githubOAuthToken,
+) (*githubTokenRecord, error) {
+	req := &UpdateTenantGithubCredsRequest{
+		FeatureFlags:      t.cfg.FeatureFlags,
+		DelegatedAuthInfo: t.cfg.DelegatedAuth,
+		TenantID:          t.cfg.TenantID,
+		Version:           version,
+		OAuthToken:        util.Pointer(token.AccessToken),
+		TokenExpiry:       token.expiry,
+		ClearTokenExpiry:  token.expiry == nil,
+	}
+	if token.RefreshToken != "" {
+		req.RefreshToken = util.Pointer(token.RefreshToken)
+	}
+	creds, err := t.cfg.Client.UpdateTenantGithubCreds(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return tenantGithubCredsRecord(creds), nil
+}
+
+func tenantGithubCredsRecord(creds *TenantGithubCreds) *githubTokenRecord {
+	return &githubTokenRecord{
+		OAuthToken:   creds.OAuthToken,
+		RefreshToken: creds.RefreshToken,
+		TokenExpiry:  creds.TokenExpiry,
+		Version:      creds.TenantVersion,
+	}
+}
@@ -0,0 +1,362 @@
+package p42_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/plan42-ai/clock"
+	"github.com/plan42-ai/sdk-go/internal/util"
+	"github.com/plan42-ai/sdk-go/p42"
+	"github.com/stretchr/testify/require"
+)
+
+var githubTokenNow = time.Date(2025, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+// fakeGithubTokenServer stores a single GithubConnection and TenantGithubCreds and implements the OAuth refresh grant.
+type fakeGithubTokenServer struct {
+	*httptest.Server
+	t *testing.T
+
+	mu        sync.Mutex
+	conn      p42.GithubConnection
+	creds     p42.TenantGithubCreds
+	conflicts int
+	oauthErr  string
+	noExpiry  bool
+	// refreshToken is the refresh token that the OAuth grant accepts. GitHub rejects any other.
+	refreshToken string
+	// rotate, if set, runs before the next OAuth grant to simulate another process redeeming the refresh token.
+	rotate func()
+
+	gets      atomic.Int32
+	refreshes atomic.Int32
+}
+
+func newFakeGithubTokenServer(t *testing.T, expiry time.Time) *fakeGithubTokenServer {
+	t.Helper()
+	f := &fakeGithubTokenServer{
+		t:            t,
+		refreshToken: "refresh-0",
+		conn: p42.GithubConnection{
+			TenantID:     "ten",
+			ConnectionID: "conn",
+			OAuthToken:   util.Pointer("token-0"),
+			RefreshToken: util.Pointer("refresh-0"),
+			TokenExpiry:  util.Pointer(expiry),
+			Version:      1,
+		},
+		creds: p42.TenantGithubCreds{
+			TenantID:      "ten",
+			OAuthToken:    util.Pointer("token-0"),
+			RefreshToken:  util.Pointer("refresh-0"),
+			TokenExpiry:   util.Pointer(expiry),
+			TenantVersion: 1,
+		},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/tenants/ten/github-connections/conn", f.getConnection)
+	mux.HandleFunc("PATCH /v1/tenants/ten/github-connections/conn", f.updateConnection)
+	mux.HandleFunc("GET /v1/tenants/ten/githubcreds", f.getCreds)
+	mux.HandleFunc("PATCH /v1/tenants/ten/githubcreds", f.updateCreds)
+	mux.HandleFunc("POST /oauth", f.oauth)
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeGithubTokenServer) getConnection(w http.ResponseWriter, _ *http.Request) {
+	f.gets.Add(1)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_ = json.NewEncoder(w).Encode(f.conn)
+}
+
+func (f *fakeGithubTokenServer) updateConnection(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var req p42.UpdateGithubConnectionRequest
+	clearExpiry := decodeTokenUpdate(f.t, r, &req)
+	if f.conflicts > 0 {
+		// Simulate a concurrent edit that doesn't touch the tokens.
+		f.conflicts--
+		f.conn.Version++
+	}
+	if r.Header.Get("If-Match") != strconv.Itoa(f.conn.Version) {
+		w.WriteHeader(http.StatusConflict)
+		_ = json.NewEncoder(w).Encode(
+			p42.ConflictError{ResponseCode: http.StatusConflict, ErrorType: "Conflict", Current: &f.conn},
+		)
+		return
+	}
+	f.conn.OAuthToken, f.conn.RefreshToken = req.OAuthToken, req.RefreshToken
+	f.conn.TokenExpiry = updatedExpiry(f.conn.TokenExpiry, req.TokenExpiry, clearExpiry)
+	f.conn.Version++
+	_ = json.NewEncoder(w).Encode(f.conn)
+}
+
+func (f *fakeGithubTokenServer) getCreds(w http.ResponseWriter, _ *http.Request) {
+	f.gets.Add(1)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_ = json.NewEncoder(w).Encode(f.creds)
+}
+
+func (f *fakeGithubTokenServer) updateCreds(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var req p42.UpdateTenantGithubCredsRequest
+	clearExpiry := decodeTokenUpdate(f.t, r, &req)
+	require.Equal(f.t, strconv.Itoa(f.creds.TenantVersion), r.Header.Get("If-Match"))
+	f.creds.OAuthToken, f.creds.RefreshToken = req.OAuthToken, req.RefreshToken
+	f.creds.TokenExpiry = updatedExpiry(f.creds.TokenExpiry, req.TokenExpiry, clearExpiry)
+	f.creds.TenantVersion++
+	_ = json.NewEncoder(w).Encode(f.creds)
+}
+
+func (f *fakeGithubTokenServer) oauth(w http.ResponseWriter, r *http.Request) {
+	n := f.refreshes.Add(1)
+	require.NoError(f.t, r.ParseForm())
+	require.Equal(f.t, "refresh_token", r.PostForm.Get("grant_type"))
+	require.Equal(f.t, "client", r.PostForm.Get("client_id"))
+	require.Equal(f.t, "secret", r.PostForm.Get("client_secret"))
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.rotate != nil {
+		f.rotate()
+		f.rotate = nil
+	}
+	oauthErr := f.oauthErr
+	if oauthErr == "" && r.PostForm.Get("refresh_token") != f.refreshToken {
+		oauthErr = "bad_refresh_token"
+	}
+	if oauthErr != "" {
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": oauthErr, "error_description": "nope"})
+		return
+	}
+	f.refreshToken = "refresh-" + strconv.Itoa(int(n))
+	resp := map[string]any{
+		"access_token":  "token-" + strconv.Itoa(int(n)),
+		"refresh_token": f.refreshToken,
+	}
+	if !f.noExpiry {
+		resp["expires_in"] = 28800
+	}
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// decodeTokenUpdate decodes an update request into req and reports whether it clears TokenExpiry, which is sent as
+// "" and so can't be decoded into a time.
+func decodeTokenUpdate(t *testing.T, r *http.Request, req any) bool {
+	var fields map[string]json.RawMessage
+	require.NoError(t, json.NewDecoder(r.Body).Decode(&fields))
+	clearExpiry := string(fields["TokenExpiry"]) == `""`
+	if clearExpiry {
+		delete(fields, "TokenExpiry")
+	}
+	body, err := json.Marshal(fields)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(body, req))
+	return clearExpiry
+}
+
+func updatedExpiry(current *time.Time, update *time.Time, clearExpiry bool) *time.Time {
+	switch {
+	case clearExpiry:
+		return nil
+	case update != nil:
+		return update
+	default:
+		return current
+	}
+}
+
+func (f *fakeGithubTokenServer) source(t *testing.T, connectionID string) *p42.GithubTokenSource {
+	t.Helper()
+	source, err := p42.NewGithubTokenSource(
+		&p42.GithubTokenSourceConfig{
+			Client:       p42.NewClient(f.URL),
+			TenantID:     "ten",
+			ConnectionID: connectionID,
+			ClientID:     "client",
+			ClientSecret: "secret",
+			TokenURL:     f.URL + "/oauth",
+			Clock:        clock.NewFakeClock(githubTokenNow),
+		},
+	)
+	require.NoError(t, err)
+	return source
+}
+
+func TestGithubTokenSourceValidToken(t *testing.T) {
+	t.Parallel()
+	srv := newFakeGithubTokenServer(t, githubTokenNow.Add(time.Hour))
+	source := srv.source(t, "conn")
+
+	for range 3 {
+		token, err := source.Token(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, "token-0", token)
+	}
+	require.Equal(t, int32(1), srv.gets.Load())
+	require.Equal(t, int32(0), srv.refreshes.Load())
+
+	source.Invalidate()
+	_, err := source.Token(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int32(2), srv.gets.Load())
+}
+
+func TestGithubTokenSourceRefreshesConnection(t *testing.T) {
+	t.Parallel()
+	// The token is still valid, but expires within RefreshBefore.
+	srv := newFakeGithubTokenServer(t, githubTokenNow.Add(time.Minute))
+	source := srv.source(t, "conn")
+
+	var wg sync.WaitGroup
+	tokens := make([]string, 10)
+	errs := make([]error, 10)
+	for i := range tokens {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tokens[i], errs[i] = source.Token(context.Background())
+		}()
+	}
+	wg.Wait()
+	for i := range tokens {
+		require.NoError(t, errs[i])
+		require.Equal(t, "token-1", tokens[i])
+	}
+
+	require.Equal(t, int32(1), srv.refreshes.Load())
+	require.Equal(t, "token-1", *srv.conn.OAuthToken)
+	require.Equal(t, "refresh-1", *srv.conn.RefreshToken)
+	require.Equal(t, githubTokenNow.Add(8*time.Hour), *srv.conn.TokenExpiry)
+	require.Equal(t, 2, srv.conn.Version)
+}
+
+func TestGithubTokenSourceRetriesConflicts(t *testing.T) {
+	t.Parallel()
+	srv := newFakeGithubTokenServer(t, githubTokenNow.Add(-time.Hour))
+	srv.conflicts = 2
+	source := srv.source(t, "conn")
+
+	token, err := source.Token(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "token-1", token)
+	require.Equal(t, int32(1), srv.refreshes.Load())
+	require.Equal(t, "token-1", *srv.conn.OAuthToken)
+	require.Equal(t, 4, srv.conn.Version)
+}
+
+func TestGithubTokenSourceRefreshesTenantCreds(t *testing.T) {
+	t.Parallel()
+	srv := newFakeGithubTokenServer(t, githubTokenNow.Add(-time.Hour))
+	source := srv.source(t, "")
+
+	token, err := source.Token(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "token-1", token)
+	require.Equal(t, "refresh-1", *srv.creds.RefreshToken)
+	require.Equal(t, 2, srv.creds.TenantVersion)
+	require.Equal(t, "token-0", *srv.conn.OAuthToken)
+}
+
+func TestGithubTokenSourceUnavailable(t *testing.T) {
+	t.Parallel()
+	srv := newFakeGithubTokenServer(t, githubTokenNow.Add(-time.Hour))
+	srv.oauthErr = "bad_refresh_token"
+	_, err := srv.source(t, "conn").Token(context.Background())
+	require.ErrorIs(t, err, p42.ErrGithubTokenUnavailable)
+
+	srv = newFakeGithubTokenServer(t, githubTokenNow.Add(-time.Hour))
+	srv.conn.RefreshToken = nil
+	_, err = srv.source(t, "conn").Token(context.Background())
+	require.ErrorIs(t, err, p42.ErrGithubTokenUnavailable)
+	require.Equal(t, int32(0), srv.refreshes.Load())
+
+	srv = newFakeGithubTokenServer(t, githubTokenNow.Add(-time.Hour))
+	srv.oauthErr = "incorrect_client_credentials"
+	_, err = srv.source(t, "conn").Token(context.Background())
+	require.EqualError(t, err, "unable to refresh github token: incorrect_client_credentials: nope")
+}
+
+func TestGithubTokenSourceRefreshTokenRedeemedElsewhere(t *testing.T) {
+	t.Parallel()
+	// Another process redeems the refresh token first and stores a valid token.
+	srv := newFakeGithubTokenServer(t, githubTokenNow.Add(-time.Hour))
+	srv.rotate = func() {
+		srv.conn.OAuthToken = util.Pointer("token-9")
+		srv.conn.RefreshToken = util.Pointer("refresh-9")
+		srv.conn.TokenExpiry = util.Pointer(githubTokenNow.Add(8 * time.Hour))
+		srv.conn.Version++
+		srv.refreshToken = "refresh-9"
+	}
+	token, err := srv.source(t, "conn").Token(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "token-9", token)
+	require.Equal(t, int32(1), srv.refreshes.Load())
+	require.Equal(t, int32(2), srv.gets.Load())
+
+	// The stored access token has already expired, so the stored refresh token is redeemed instead.
+	srv = newFakeGithubTokenServer(t, githubTokenNow.Add(-time.Hour))
+	srv.rotate = func() {
+		srv.conn.OAuthToken = util.Pointer("token-9")
+		srv.conn.RefreshToken = util.Pointer("refresh-9")
+		srv.conn.Version++
+		srv.refreshToken = "refresh-9"
+	}
+	token, err = srv.source(t, "conn").Token(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "token-2", token)
+	require.Equal(t, int32(2), srv.refreshes.Load())
+	require.Equal(t, "refresh-2", *srv.conn.RefreshToken)
+	require.Equal(t, 3, srv.conn.Version)
+
+	// The stored tokens haven't changed, so the refresh token really is bad.
+	srv = newFakeGithubTokenServer(t, githubTokenNow.Add(-time.Hour))
+	srv.refreshToken = "refresh-9"
+	_, err = srv.source(t, "conn").Token(context.Background())
+	require.ErrorIs(t, err, p42.ErrGithubTokenUnavailable)
+	require.Equal(t, int32(1), srv.refreshes.Load())
+	require.Equal(t, int32(2), srv.gets.Load())
+}
+
+func TestGithubTokenSourceClearsExpiry(t *testing.T) {
+	t.Parallel()
+	srv := newFakeGithubTokenServer(t, githubTokenNow.Add(-time.Hour))
+	srv.noExpiry = true
+	source := srv.source(t, "conn")
+
+	token, err := source.Token(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "token-1", token)
+	require.Nil(t, srv.conn.TokenExpiry)
+
+	srv = newFakeGithubTokenServer(t, githubTokenNow.Add(-time.Hour))
+	srv.noExpiry = true
+	token, err = srv.source(t, "").Token(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "token-1", token)
+	require.Nil(t, srv.creds.TokenExpiry)
+}
+
+func TestUpdateGithubConnectionRequestClearTokenExpiry(t *testing.T) {
+	t.Parallel()
+	req := p42.UpdateGithubConnectionRequest{OAuthToken: util.Pointer("token"), ClearTokenExpiry: true}
+	body, err := json.Marshal(req)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"OAuthToken": "token", "TokenExpiry": ""}`, string(body))
+
+	req.TokenExpiry = util.Pointer(githubTokenNow)
+	_, err = json.Marshal(req)
+	require.ErrorContains(t, err, "TokenExpiry and ClearTokenExpiry can't both be set")
+}
@@ -0,0 +1,423 @@
+package p42
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/plan42-ai/clock"
+)
+
+// ErrInvalidToken is wrapped by every error JWTVerifier.Verify returns for a token that fails verification.
+var ErrInvalidToken = errors.New("invalid token")
+
+// JWTClaims are the registered claims of a verified token, plus the raw claims set.
+type JWTClaims struct {
+	Issuer    string
+	Subject   string
+	Audience  []string
+	ExpiresAt time.Time
+	NotBefore time.Time
+	IssuedAt  time.Time
+
+	// Raw holds every claim in the token, including the registered ones.
+	Raw map[string]any
+}
+
+// JWTVerifierConfig holds configuration for JWTVerifier.
+type JWTVerifierConfig struct {
+	// Provider supplies the issuer and audience that tokens must match.
+	Provider *AuthenticationProvider
+
+	// JWKSURL is the URL of the provider's JSON Web Key Set.
+	JWKSURL string
+
+	// HTTPClient fetches the key set. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+
+	// Clock is used to check expiry. Defaults to the real clock.
+	Clock clock.Clock
+
+	// Leeway is the clock skew allowed when checking exp, nbf and iat. Defaults to 1 minute.
+	Leeway time.Duration
+
+	// CacheTTL is how long a fetched key set is used before being fetched again. A token signed with an unknown key
+	// also refreshes the key set, at most once per MinRefreshInterval. CacheTTL defaults to 1 hour and
+	// MinRefreshInterval to 1 minute.
+	CacheTTL           time.Duration
+	MinRefreshInterval time.Duration
+}
+
+// JWTVerifier verifies Auth Provider tokens offline against an AuthenticationProvider. It checks the signature
+// against the provider's JWKS, the iss and aud claims against the provider, and the token's validity period. RS256,
+// RS384, RS512, ES256, ES384 and ES512 signatures are supported. It is safe for concurrent use.
+type JWTVerifier struct {
+	cfg JWTVerifierConfig
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+const (
+	defaultJWTLeeway              = time.Minute
+	defaultJWKSCacheTTL           = time.Hour
+	defaultJWKSMinRefreshInterval = time.Minute
+)
+
+// NewJWTVerifier creates a JWTVerifier. Keys are fetched on first use.
+func NewJWTVerifier(cfg *JWTVerifierConfig) (*JWTVerifier, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("cfg is nil")
+	}
+	if cfg.Provider == nil || cfg.Provider.Issuer == nil {
+		return nil, fmt.Errorf("provider with an issuer is required")
+	}
+	if cfg.Provider.Audience == "" {
+		return nil, fmt.Errorf("provider audience is required")
+	}
+	if cfg.JWKSURL == "" {
+		return nil, fmt.Errorf("jwks url is required")
+	}
+	v := &JWTVerifier{cfg: *cfg}
+	if v.cfg.HTTPClient == nil {
+		v.cfg.HTTPClient = http.DefaultClient
+	}
+	if v.cfg.Clock == nil {
+		v.cfg.Clock = clock.NewRealClock()
+	}
+	if v.cfg.Leeway <= 0 {
+		v.cfg.Leeway = defaultJWTLeeway
+	}
+	if v.cfg.CacheTTL <= 0 {
+		v.cfg.CacheTTL = defaultJWKSCacheTTL
+	}
+	if v.cfg.MinRefreshInterval <= 0 {
+		v.cfg.MinRefreshInterval = defaultJWKSMinRefreshInterval
+	}
+	return v, nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ"`
+}
+
+type jwtClaimsJSON struct {
+	Issuer    string          `json:"iss"`
+	Subject   string          `json:"sub"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt *json.Number    `json:"exp"`
+	NotBefore *json.Number    `json:"nbf"`
+	IssuedAt  *json.Number    `json:"iat"`
+}
+
+func invalidToken(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalidToken, fmt.Sprintf(format, args...))
+}
+
+// Verify verifies a compact serialized JWT and returns its claims. Errors caused by the token wrap ErrInvalidToken;
+// other errors, such as a failure to fetch the key set, don't.
+func (v *JWTVerifier) Verify(ctx context.Context, token string) (*JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, invalidToken("malformed jwt")
+	}
+
+	var header jwtHeader
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, invalidToken("malformed header: %v", err)
+	}
+	hash, err := jwtHash(header.Alg)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, invalidToken("malformed signature: %v", err)
+	}
+
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	h := hash.New()
+	h.Write([]byte(parts[0] + "." + parts[1]))
+	if err := verifySignature(header.Alg, key, hash, h.Sum(nil), signature); err != nil {
+		return nil, err
+	}
+
+	return v.checkClaims(parts[1])
+}
+
+func decodeJWTPart(part string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+func jwtHash(alg string) (crypto.Hash, error) {
+	switch alg {
+	case "RS256", "ES256":
+		return crypto.SHA256, nil
+	case "RS384", "ES384":
+		return crypto.SHA384, nil
+	case "RS512", "ES512":
+		return crypto.SHA512, nil
+	default:
+		return 0, invalidToken("unsupported alg %q", alg)
+	}
+}
+
+// minRSAKeyBits is the smallest RSA modulus accepted for signatures.
+const minRSAKeyBits = 2048
+
+// ecAlgCurves maps each EC alg to the only curve it may be used with.
+var ecAlgCurves = map[string]string{
+	"ES256": "P-256",
+	"ES384": "P-384",
+	"ES512": "P-521",
+}
+
+func verifySignature(alg string, key crypto.PublicKey, hash crypto.Hash, digest, signature []byte) error {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return invalidToken("alg %s does not match an RSA key", alg)
+		}
+		if k.N.BitLen() < minRSAKeyBits {
+			return invalidToken("rsa key is too small: %d bits", k.N.BitLen())
+		}
+		if err := rsa.VerifyPKCS1v15(k, hash, digest, signature); err != nil {
+			return invalidToken("bad signature")
+		}
+	case *ecdsa.PublicKey:
+		if curve := k.Curve.Params().Name; ecAlgCurves[alg] != curve {
+			return invalidToken("alg %s does not match an EC key on curve %s", alg, curve)
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return invalidToken("bad signature")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return invalidToken("bad signature")
+		}
+	default:
+		return invalidToken("unsupported key type %T", key)
+	}
+	return nil
+}
+
+func (v *JWTVerifier) checkClaims(part string) (*JWTClaims, error) {
+	var raw map[string]any
+	if err := decodeJWTPart(part, &raw); err != nil {
+		return nil, invalidToken("malformed claims: %v", err)
+	}
+	var tmp jwtClaimsJSON
+	if err := decodeJWTPart(part, &tmp); err != nil {
+		return nil, invalidToken("malformed claims: %v", err)
+	}
+
+	claims := &JWTClaims{Issuer: tmp.Issuer, Subject: tmp.Subject, Raw: raw}
+	if len(tmp.Audience) > 0 {
+		var single string
+		if err := json.Unmarshal(tmp.Audience, &single); err == nil {
+			claims.Audience = []string{single}
+		} else if err := json.Unmarshal(tmp.Audience, &claims.Audience); err != nil {
+			return nil, invalidToken("malformed aud claim")
+		}
+	}
+	var err error
+	for _, c := range []struct {
+		name string
+		in   *json.Number
+		out  *time.Time
+	}{
+		{"exp", tmp.ExpiresAt, &claims.ExpiresAt},
+		{"nbf", tmp.NotBefore, &claims.NotBefore},
+		{"iat", tmp.IssuedAt, &claims.IssuedAt},
+	} {
+		if *c.out, err = numericDate(c.in); err != nil {
+			return nil, invalidToken("malformed %s claim", c.name)
+		}
+	}
+
+	provider := v.cfg.Provider
+	now := v.cfg.Clock.Now()
+	switch {
+	case claims.Issuer != provider.Issuer.String():
+		return nil, invalidToken("unexpected issuer %q", claims.Issuer)
+	case !slices.Contains(claims.Audience, provider.Audience):
+		return nil, invalidToken("audience does not include %q", provider.Audience)
+	case claims.ExpiresAt.IsZero():
+		return nil, invalidToken("missing exp claim")
+	case !now.Before(claims.ExpiresAt.Add(v.cfg.Leeway)):
+		return nil, invalidToken("token expired at %s", claims.ExpiresAt.Format(time.RFC3339))
+	case !claims.NotBefore.IsZero() && now.Add(v.cfg.Leeway).Before(claims.NotBefore):
+		return nil, invalidToken("token not valid before %s", claims.NotBefore.Format(time.RFC3339))
+	case !claims.IssuedAt.IsZero() && now.Add(v.cfg.Leeway).Before(claims.IssuedAt):
+		return nil, invalidToken("token issued in the future")
+	}
+	return claims, nil
+}
+
+func numericDate(n *json.Number) (time.Time, error) {
+	if n == nil {
+		return time.Time{}, nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(int64(f), 0), nil
+}
+
+// key returns the key with the given id, fetching the key set if it is stale or doesn't contain the key.
+func (v *JWTVerifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := v.cfg.Clock.Now()
+	stale := v.keys == nil || now.Sub(v.fetchedAt) >= v.cfg.CacheTTL
+	if !stale {
+		if key, ok := v.lookup(kid); ok {
+			return key, nil
+		}
+		if now.Sub(v.fetchedAt) < v.cfg.MinRefreshInterval {
+			return nil, invalidToken("unknown key id %q", kid)
+		}
+	}
+
+	keys, err := v.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v.keys, v.fetchedAt = keys, now
+	if key, ok := v.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, invalidToken("unknown key id %q", kid)
+}
+
+// lookup finds a key by id. A token without a kid matches the only key of a single key set.
+func (v *JWTVerifier) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, true
+		}
+	}
+	key, ok := v.keys[kid]
+	return key, ok
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (v *JWTVerifier) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, v.cfg.JWKSURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", "application/json")
+
+	resp, err := v.cfg.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch jwks: unexpected status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("unable to decode jwks: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		// Keys of unsupported types are skipped rather than failing the whole set.
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("rsa exponent too large")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("point is not on curve")
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+	}
+}
@@ -0,0 +1,272 @@
+package p42_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/plan42-ai/clock"
+	"github.com/plan42-ai/sdk-go/p42"
+	"github.com/stretchr/testify/require"
+)
+
+var jwtNow = time.Date(2025, time.January, 1, 12, 0, 0, 0, time.UTC)
+
+type jwksServer struct {
+	*httptest.Server
+	keys    []map[string]string
+	fetches atomic.Int32
+}
+
+func newJWKSServer(t *testing.T) *jwksServer {
+	t.Helper()
+	s := &jwksServer{}
+	s.Server = httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, _ *http.Request) {
+				s.fetches.Add(1)
+				w.Header().Set("Content-Type", "application/json")
+				_ = json.NewEncoder(w).Encode(map[string]any{"keys": s.keys})
+			},
+		),
+	)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (s *jwksServer) addRSA(kid string, key *rsa.PublicKey) {
+	s.keys = append(
+		s.keys, map[string]string{
+			"kty": "RSA",
+			"kid": kid,
+			"use": "sig",
+			"n":   b64(key.N.Bytes()),
+			"e":   b64(big.NewInt(int64(key.E)).Bytes()),
+		},
+	)
+}
+
+func (s *jwksServer) addEC(kid string, key *ecdsa.PublicKey) {
+	size := (key.Curve.Params().BitSize + 7) / 8
+	s.keys = append(
+		s.keys, map[string]string{
+			"kty": "EC",
+			"kid": kid,
+			"crv": key.Curve.Params().Name,
+			"x":   b64(key.X.FillBytes(make([]byte, size))),
+			"y":   b64(key.Y.FillBytes(make([]byte, size))),
+		},
+	)
+}
+
+func signJWT(t *testing.T, alg string, kid string, key crypto.Signer, claims map[string]any) string {
+	t.Helper()
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	require.NoError(t, err)
+	body, err := json.Marshal(claims)
+	require.NoError(t, err)
+	signingInput := b64(header) + "." + b64(body)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	var sig []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		require.NoError(t, err)
+		size := (k.Curve.Params().BitSize + 7) / 8
+		sig = append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...)
+	}
+	return signingInput + "." + b64(sig)
+}
+
+func validClaims() map[string]any {
+	return map[string]any{
+		"iss": "https://idp.example.com",
+		"sub": "user-1",
+		"aud": []string{"other", "plan42"},
+		"exp": jwtNow.Add(time.Hour).Unix(),
+		"iat": jwtNow.Add(-time.Minute).Unix(),
+	}
+}
+
+func newTestVerifier(t *testing.T, jwksURL string, clk clock.Clock) *p42.JWTVerifier {
+	t.Helper()
+	issuer, err := url.Parse("https://idp.example.com")
+	require.NoError(t, err)
+	verifier, err := p42.NewJWTVerifier(
+		&p42.JWTVerifierConfig{
+			Provider: &p42.AuthenticationProvider{Issuer: issuer, Audience: "plan42"},
+			JWKSURL:  jwksURL,
+			Clock:    clk,
+		},
+	)
+	require.NoError(t, err)
+	return verifier
+}
+
+func TestJWTVerifierRSAAndEC(t *testing.T) {
+	t.Parallel()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	srv := newJWKSServer(t)
+	srv.addRSA("rsa", &rsaKey.PublicKey)
+	srv.addEC("ec", &ecKey.PublicKey)
+	verifier := newTestVerifier(t, srv.URL, clock.NewFakeClock(jwtNow))
+
+	claims, err := verifier.Verify(context.Background(), signJWT(t, "RS256", "rsa", rsaKey, validClaims()))
+	require.NoError(t, err)
+	require.Equal(t, "user-1", claims.Subject)
+	require.Equal(t, []string{"other", "plan42"}, claims.Audience)
+	require.Equal(t, jwtNow.Add(time.Hour), claims.ExpiresAt.UTC())
+	require.Equal(t, "user-1", claims.Raw["sub"])
+
+	_, err = verifier.Verify(context.Background(), signJWT(t, "ES256", "ec", ecKey, validClaims()))
+	require.NoError(t, err)
+	require.Equal(t, int32(1), srv.fetches.Load())
+}
+
+func TestJWTVerifierRejectsInvalidTokens(t *testing.T) {
+	t.Parallel()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	srv := newJWKSServer(t)
+	srv.addRSA("rsa", &key.PublicKey)
+	verifier := newTestVerifier(t, srv.URL, clock.NewFakeClock(jwtNow))
+
+	with := func(name string, value any) map[string]any {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+	valid := signJWT(t, "RS256", "rsa", key, validClaims())
+	parts := strings.Split(valid, ".")
+
+	tests := map[string]string{
+		"not a jwt": "malformed jwt",
+		signJWT(t, "RS256", "rsa", key, with("iss", "https://evil.example.com")):            "unexpected issuer",
+		signJWT(t, "RS256", "rsa", key, with("aud", "other")):                               "audience does not include",
+		signJWT(t, "RS256", "rsa", key, with("exp", nil)):                                   "missing exp claim",
+		signJWT(t, "RS256", "rsa", key, with("exp", jwtNow.Add(-time.Hour).Unix())):         "token expired",
+		signJWT(t, "RS256", "rsa", key, with("nbf", jwtNow.Add(time.Hour).Unix())):          "token not valid before",
+		signJWT(t, "RS256", "rsa", otherKey, validClaims()):                                 "bad signature",
+		signJWT(t, "RS256", "unknown", key, validClaims()):                                  "unknown key id",
+		parts[0] + "." + b64([]byte(`{"iss":"https://evil.example.com"}`)) + "." + parts[2]: "bad signature",
+		b64([]byte(`{"alg":"none"}`)) + "." + parts[1] + ".":                                "unsupported alg",
+	}
+	for token, msg := range tests {
+		_, err := verifier.Verify(context.Background(), token)
+		require.ErrorIs(t, err, p42.ErrInvalidToken, msg)
+		require.ErrorContains(t, err, msg)
+	}
+
+	// An expiry within the leeway is still accepted.
+	_, err = verifier.Verify(
+		context.Background(),
+		signJWT(t, "RS256", "rsa", key, with("exp", jwtNow.Add(-30*time.Second).Unix())),
+	)
+	require.NoError(t, err)
+}
+
+func TestJWTVerifierRejectsWeakKeys(t *testing.T) {
+	t.Parallel()
+	// #nosec G403: The key is deliberately too small.
+	smallKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	srv := newJWKSServer(t)
+	srv.addRSA("small", &smallKey.PublicKey)
+	srv.addEC("p384", &p384Key.PublicKey)
+	verifier := newTestVerifier(t, srv.URL, clock.NewFakeClock(jwtNow))
+
+	tests := map[string]string{
+		signJWT(t, "RS256", "small", smallKey, validClaims()): "rsa key is too small: 1024 bits",
+		signJWT(t, "ES256", "p384", p384Key, validClaims()):   "alg ES256 does not match an EC key on curve P-384",
+		signJWT(t, "RS256", "p384", p384Key, validClaims()):   "alg RS256 does not match an EC key on curve P-384",
+	}
+	for token, msg := range tests {
+		_, err := verifier.Verify(context.Background(), token)
+		require.ErrorIs(t, err, p42.ErrInvalidToken, msg)
+		require.ErrorContains(t, err, msg)
+	}
+}
+
+func TestJWTVerifierRefreshesKeys(t *testing.T) {
+	t.Parallel()
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	srv := newJWKSServer(t)
+	srv.addRSA("old", &oldKey.PublicKey)
+	clk := clock.NewFakeClock(jwtNow)
+	verifier := newTestVerifier(t, srv.URL, clk)
+
+	_, err = verifier.Verify(context.Background(), signJWT(t, "RS256", "old", oldKey, validClaims()))
+	require.NoError(t, err)
+
+	// The provider rotates its keys. Unknown key ids only trigger a refetch once the refresh interval has passed.
+	srv.addRSA("new", &newKey.PublicKey)
+	token := signJWT(t, "RS256", "new", newKey, validClaims())
+	_, err = verifier.Verify(context.Background(), token)
+	require.ErrorContains(t, err, "unknown key id")
+	require.Equal(t, int32(1), srv.fetches.Load())
+
+	clk.Advance(2 * time.Minute)
+	_, err = verifier.Verify(context.Background(), token)
+	require.NoError(t, err)
+	require.Equal(t, int32(2), srv.fetches.Load())
+}
+
+func TestNewJWTVerifierValidation(t *testing.T) {
+	t.Parallel()
+	issuer, err := url.Parse("https://idp.example.com")
+	require.NoError(t, err)
+
+	tests := []struct {
+		cfg *p42.JWTVerifierConfig
+		msg string
+	}{
+		{nil, "cfg is nil"},
+		{&p42.JWTVerifierConfig{JWKSURL: "https://idp.example.com/jwks"}, "provider with an issuer is required"},
+		{&p42.JWTVerifierConfig{Provider: &p42.AuthenticationProvider{Issuer: issuer}}, "provider audience is required"},
+		{&p42.JWTVerifierConfig{Provider: &p42.AuthenticationProvider{Issuer: issuer, Audience: "plan42"}}, "jwks url is required"},
+	}
+	for _, tc := range tests {
+		_, err := p42.NewJWTVerifier(tc.cfg)
+		require.EqualError(t, err, tc.msg)
+	}
+}
@@ -0,0 +1,109 @@
+package p42
+
+import (
+	"fmt"
+	"slices"
+)
+
+// TurnStatus represents the status of a turn. While a turn runs, its status may be arbitrary text set by the agent;
+// the constants below are the statuses the service itself assigns meaning to.
+type TurnStatus string
+
+const (
+	TurnStatusPending   TurnStatus = "Pending"
+	TurnStatusSucceeded TurnStatus = "Succeeded"
+	TurnStatusDone      TurnStatus = "Done"
+	TurnStatusFailed    TurnStatus = "Failed"
+)
+
+// LifecycleWildcard stands for every state a Lifecycle doesn't declare. As a key in a Lifecycle's transitions, it
+// gives the transitions of undeclared states; as a target, it allows moving to any undeclared state.
+const LifecycleWildcard = "*"
+
+// InvalidTransitionError is returned when an update would move an object between two states that its lifecycle
+// doesn't connect.
+type InvalidTransitionError struct {
+	Lifecycle string
+	From      string
+	To        string
+}
+
+func (e *InvalidTransitionError) Error() string {
+	return fmt.Sprintf("invalid %s transition from %q to %q", e.Lifecycle, e.From, e.To)
+}
+
+// Lifecycle is a state machine that declares the legal transitions between the states of an object. Staying in the
+// same state is always legal.
+type Lifecycle[S ~string] struct {
+	name        string
+	transitions map[S][]S
+}
+
+// NewLifecycle creates a Lifecycle named name (used in errors) from a map of each state to the states it can move
+// to. Terminal states must still be present in the map, with an empty slice. Undeclared states are only legal if the
+// map uses LifecycleWildcard.
+func NewLifecycle[S ~string](name string, transitions map[S][]S) *Lifecycle[S] {
+	return &Lifecycle[S]{name: name, transitions: transitions}
+}
+
+// Next returns the states that can be reached from state in one transition. The result may include
+// LifecycleWildcard.
+func (l *Lifecycle[S]) Next(state S) []S {
+	return slices.Clone(l.transitions[l.resolve(state)])
+}
+
+// IsTerminal returns true if state has no outgoing transitions.
+func (l *Lifecycle[S]) IsTerminal(state S) bool {
+	next, ok := l.transitions[l.resolve(state)]
+	return ok && len(next) == 0
+}
+
+// CanTransition returns true if moving from one state to the other is legal.
+func (l *Lifecycle[S]) CanTransition(from, to S) bool {
+	if from == to {
+		return true
+	}
+	next, ok := l.transitions[l.resolve(from)]
+	return ok && slices.Contains(next, l.resolve(to))
+}
+
+// Validate returns an *InvalidTransitionError if moving from one state to the other is illegal.
+func (l *Lifecycle[S]) Validate(from, to S) error {
+	if l.CanTransition(from, to) {
+		return nil
+	}
+	return &InvalidTransitionError{Lifecycle: l.name, From: string(from), To: string(to)}
+}
+
+// resolve maps undeclared states to LifecycleWildcard.
+func (l *Lifecycle[S]) resolve(state S) S {
+	if _, ok := l.transitions[state]; ok {
+		return state
+	}
+	return LifecycleWildcard
+}
+
+// TurnLifecycle declares the legal transitions between turn statuses. A turn is created Pending, moves through any
+// statuses its agent reports, and ends Succeeded or Failed. Done is also accepted as a successful terminal status.
+var TurnLifecycle = NewLifecycle(
+	"turn",
+	map[TurnStatus][]TurnStatus{
+		TurnStatusPending:   {LifecycleWildcard, TurnStatusSucceeded, TurnStatusDone, TurnStatusFailed},
+		LifecycleWildcard:   {LifecycleWildcard, TurnStatusSucceeded, TurnStatusDone, TurnStatusFailed},
+		TurnStatusSucceeded: {},
+		TurnStatusDone:      {},
+		TurnStatusFailed:    {},
+	},
+)
+
+// TaskLifecycle declares the legal transitions between task states. A task moves between executing turns and
+// awaiting review of their output until it is completed.
+var TaskLifecycle = NewLifecycle(
+	"task",
+	map[TaskState][]TaskState{
+		TaskStatePending:            {TaskStateExecuting, TaskStateCompleted},
+		TaskStateExecuting:          {TaskStatePending, TaskStateAwaitingCodeReview, TaskStateCompleted},
+		TaskStateAwaitingCodeReview: {TaskStatePending, TaskStateExecuting, TaskStateCompleted},
+		TaskStateCompleted:          {},
+	},
+)
@@ -0,0 +1,113 @@
+package p42_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/plan42-ai/sdk-go/internal/util"
+	"github.com/plan42-ai/sdk-go/p42"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTurnLifecycle(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		from, to p42.TurnStatus
+		legal    bool
+	}{
+		{p42.TurnStatusPending, "Cloning repo", true},
+		{"Cloning repo", "Running tests", true},
+		{"Running tests", p42.TurnStatusSucceeded, true},
+		{"Running tests", p42.TurnStatusDone, true},
+		{"Running tests", p42.TurnStatusFailed, true},
+		{p42.TurnStatusPending, p42.TurnStatusFailed, true},
+		{p42.TurnStatusDone, p42.TurnStatusDone, true},
+		{"Running tests", p42.TurnStatusPending, false},
+		{p42.TurnStatusDone, "Running tests", false},
+		{p42.TurnStatusFailed, p42.TurnStatusDone, false},
+		{p42.TurnStatusSucceeded, "Running tests", false},
+		{p42.TurnStatusSucceeded, p42.TurnStatusFailed, false},
+	}
+	for _, tc := range tests {
+		require.Equal(t, tc.legal, p42.TurnLifecycle.CanTransition(tc.from, tc.to), "%s -> %s", tc.from, tc.to)
+	}
+
+	require.True(t, p42.TurnLifecycle.IsTerminal(p42.TurnStatusSucceeded))
+	require.True(t, p42.TurnLifecycle.IsTerminal(p42.TurnStatusDone))
+	require.True(t, p42.TurnLifecycle.IsTerminal(p42.TurnStatusFailed))
+	require.False(t, p42.TurnLifecycle.IsTerminal("Running tests"))
+	require.ElementsMatch(
+		t,
+		[]p42.TurnStatus{p42.LifecycleWildcard, p42.TurnStatusSucceeded, p42.TurnStatusDone, p42.TurnStatusFailed},
+		p42.TurnLifecycle.Next("Running tests"),
+	)
+
+	err := p42.TurnLifecycle.Validate(p42.TurnStatusDone, "Running tests")
+	var transitionErr *p42.InvalidTransitionError
+	require.ErrorAs(t, err, &transitionErr)
+	require.Equal(t, `invalid turn transition from "Done" to "Running tests"`, err.Error())
+}
+
+func TestTaskLifecycle(t *testing.T) {
+	t.Parallel()
+	require.True(t, p42.TaskLifecycle.CanTransition(p42.TaskStatePending, p42.TaskStateExecuting))
+	require.True(t, p42.TaskLifecycle.CanTransition(p42.TaskStateExecuting, p42.TaskStateAwaitingCodeReview))
+	require.True(t, p42.TaskLifecycle.CanTransition(p42.TaskStateAwaitingCodeReview, p42.TaskStateExecuting))
+	require.True(t, p42.TaskLifecycle.CanTransition(p42.TaskStateAwaitingCodeReview, p42.TaskStateCompleted))
+	require.False(t, p42.TaskLifecycle.CanTransition(p42.TaskStatePending, p42.TaskStateAwaitingCodeReview))
+	require.False(t, p42.TaskLifecycle.CanTransition(p42.TaskStateCompleted, p42.TaskStateExecuting))
+	require.False(t, p42.TaskLifecycle.CanTransition(p42.TaskStateExecuting, "Paused"))
+	require.True(t, p42.TaskLifecycle.IsTerminal(p42.TaskStateCompleted))
+}
+
+func TestUpdateTurnValidatesTransition(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(
+		http.HandlerFunc(
+			func(http.ResponseWriter, *http.Request) {
+				t.Error("request should not be sent")
+			},
+		),
+	)
+	defer srv.Close()
+
+	_, err := p42.NewClient(srv.URL).UpdateTurn(
+		context.Background(), &p42.UpdateTurnRequest{
+			TenantID:      "ten",
+			TaskID:        "task",
+			TurnIndex:     1,
+			Status:        util.Pointer(p42.TurnStatus("Running")),
+			CurrentStatus: util.Pointer(p42.TurnStatusDone),
+		},
+	)
+	var transitionErr *p42.InvalidTransitionError
+	require.ErrorAs(t, err, &transitionErr)
+	require.Equal(t, "Done", transitionErr.From)
+}
+
+func TestUpdateWorkstreamTaskValidatesTransition(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(
+		http.HandlerFunc(
+			func(http.ResponseWriter, *http.Request) {
+				t.Error("request should not be sent")
+			},
+		),
+	)
+	defer srv.Close()
+
+	_, err := p42.NewClient(srv.URL).UpdateWorkstreamTask(
+		context.Background(), &p42.UpdateWorkstreamTaskRequest{
+			TenantID:     "ten",
+			WorkstreamID: "ws",
+			TaskID:       "task",
+			State:        util.Pointer(p42.TaskStateExecuting),
+			CurrentState: util.Pointer(p42.TaskStateCompleted),
+		},
+	)
+	var transitionErr *p42.InvalidTransitionError
+	require.ErrorAs(t, err, &transitionErr)
+	require.Equal(t, "task", transitionErr.Lifecycle)
+}
@@ -0,0 +1,438 @@
+package p42
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/plan42-ai/sdk-go/internal/util"
+	"github.com/plan42-ai/sdk-go/p42/sse"
+)
+
+// LogArchiveFormat is the encoding of a log archive.
+type LogArchiveFormat string
+
+const (
+	// LogArchiveFormatJSONL encodes one ArchivedLog per line. It is the only format that can be imported.
+	LogArchiveFormatJSONL LogArchiveFormat = "jsonl"
+
+	// LogArchiveFormatText encodes one human-readable line per log: its timestamp, task, turn and message.
+	LogArchiveFormatText LogArchiveFormat = "text"
+)
+
+// ArchivedLog is a single log in a log archive.
+type ArchivedLog struct {
+	TaskID    string `json:"TaskId"`
+	TurnIndex int    `json:"TurnIndex"`
+	Index     int    `json:"Index"`
+	TurnLog
+}
+
+// LogArchiveWriter writes logs to an archive.
+type LogArchiveWriter struct {
+	format LogArchiveFormat
+	bw     *bufio.Writer
+	gz     *gzip.Writer
+	enc    *json.Encoder
+}
+
+// NewLogArchiveWriter creates a LogArchiveWriter that writes to w. If compress is set the archive is gzipped.
+func NewLogArchiveWriter(w io.Writer, format LogArchiveFormat, compress bool) (*LogArchiveWriter, error) {
+	switch format {
+	case LogArchiveFormatJSONL, LogArchiveFormatText:
+	default:
+		return nil, fmt.Errorf("unsupported log archive format %q", format)
+	}
+
+	a := &LogArchiveWriter{format: format}
+	if compress {
+		a.gz = gzip.NewWriter(w)
+		w = a.gz
+	}
+	a.bw = bufio.NewWriter(w)
+	a.enc = json.NewEncoder(a.bw)
+	return a, nil
+}
+
+// Write appends log to the archive.
+func (a *LogArchiveWriter) Write(log *ArchivedLog) error {
+	if a.format == LogArchiveFormatJSONL {
+		return a.enc.Encode(log)
+	}
+	_, err := fmt.Fprintf(
+		a.bw,
+		"%s [%s/%d] %s\n",
+		log.Timestamp.Format(time.RFC3339Nano),
+		log.TaskID,
+		log.TurnIndex,
+		log.Message,
+	)
+	return err
+}
+
+// Close flushes the archive. It does not close the underlying writer.
+func (a *LogArchiveWriter) Close() error {
+	err := a.bw.Flush()
+	if a.gz != nil {
+		err = errors.Join(err, a.gz.Close())
+	}
+	return err
+}
+
+// LogArchiveReader reads logs from a JSONL archive, which may be gzipped.
+type LogArchiveReader struct {
+	gz  *gzip.Reader
+	dec *json.Decoder
+}
+
+// NewLogArchiveReader creates a LogArchiveReader that reads from r. Gzipped archives are detected automatically.
+func NewLogArchiveReader(r io.Reader) (*LogArchiveReader, error) {
+	br := bufio.NewReader(r)
+	a := &LogArchiveReader{}
+
+	magic, err := br.Peek(2)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		a.gz, err = gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		a.dec = json.NewDecoder(a.gz)
+	} else {
+		a.dec = json.NewDecoder(br)
+	}
+	return a, nil
+}
+
+// Read returns the next log in the archive. It returns io.EOF at the end of the archive.
+func (a *LogArchiveReader) Read() (*ArchivedLog, error) {
+	var log ArchivedLog
+	if err := a.dec.Decode(&log); err != nil {
+		return nil, err
+	}
+	return &log, nil
+}
+
+// Close releases the reader's resources. It does not close the underlying reader.
+func (a *LogArchiveReader) Close() error {
+	if a.gz != nil {
+		return a.gz.Close()
+	}
+	return nil
+}
+
+// ExportLogsRequest selects the logs to export with ExportLogs. Set WorkstreamID to export every task in a workstream,
+// TaskID to export every turn of a task, or TaskID and TurnIndex to export a single turn.
+type ExportLogsRequest struct {
+	FeatureFlags
+	DelegatedAuthInfo
+	TenantID       string
+	WorkstreamID   *string
+	TaskID         *string
+	TurnIndex      *int
+	IncludeDeleted *bool
+}
+
+// ExportLogs writes the logs selected by req to w. Turns are exported in order, each from its first log to its last.
+// Exporting a turn that is still running waits until the turn's logs end.
+func ExportLogs(ctx context.Context, client *Client, req *ExportLogsRequest, w *LogArchiveWriter) error {
+	if req == nil {
+		return fmt.Errorf("req is nil")
+	}
+	if req.TenantID == "" {
+		return fmt.Errorf("tenant id is required")
+	}
+
+	switch {
+	case req.WorkstreamID != nil && (req.TaskID != nil || req.TurnIndex != nil):
+		return fmt.Errorf("workstream id can't be combined with task id or turn index")
+	case req.WorkstreamID != nil:
+		return exportWorkstreamLogs(ctx, client, req, w)
+	case req.TaskID == nil:
+		return fmt.Errorf("workstream id or task id is required")
+	case req.TurnIndex != nil:
+		return exportTurnLogs(ctx, client, req, *req.TaskID, *req.TurnIndex, w)
+	default:
+		return exportTaskLogs(ctx, client, req, *req.TaskID, w)
+	}
+}
+
+func exportWorkstreamLogs(ctx context.Context, client *Client, req *ExportLogsRequest, w *LogArchiveWriter) error {
+	listReq := &ListWorkstreamTasksRequest{
+		FeatureFlags:      req.FeatureFlags,
+		DelegatedAuthInfo: req.DelegatedAuthInfo,
+		TenantID:          req.TenantID,
+		WorkstreamID:      *req.WorkstreamID,
+		IncludeDeleted:    req.IncludeDeleted,
+	}
+	for {
+		resp, err := client.ListWorkstreamTasks(ctx, listReq)
+		if err != nil {
+			return err
+		}
+		for _, task := range resp.Items {
+			if err := exportTaskLogs(ctx, client, req, task.TaskID, w); err != nil {
+				return err
+			}
+		}
+		if resp.NextToken == nil {
+			return nil
+		}
+		listReq.Token = resp.NextToken
+	}
+}
+
+func exportTaskLogs(ctx context.Context, client *Client, req *ExportLogsRequest, taskID string, w *LogArchiveWriter) error {
+	listReq := &ListTurnsRequest{
+		FeatureFlags:      req.FeatureFlags,
+		DelegatedAuthInfo: req.DelegatedAuthInfo,
+		TenantID:          req.TenantID,
+		TaskID:            taskID,
+		IncludeDeleted:    req.IncludeDeleted,
+	}
+	var turnIndexes []int
+	for {
+		resp, err := client.ListTurns(ctx, listReq)
+		if err != nil {
+			return err
+		}
+		for _, turn := range resp.Turns {
+			turnIndexes = append(turnIndexes, turn.TurnIndex)
+		}
+		if resp.NextToken == nil {
+			break
+		}
+		listReq.Token = resp.NextToken
+	}
+
+	sort.Ints(turnIndexes)
+	for _, turnIndex := range turnIndexes {
+		if err := exportTurnLogs(ctx, client, req, taskID, turnIndex, w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportTurnLogs drains the log stream of a turn. The stream is reopened after the last event received, until the
+// service reports there are no more logs.
+func exportTurnLogs(
+	ctx context.Context,
+	client *Client,
+	req *ExportLogsRequest,
+	taskID string,
+	turnIndex int,
+	w *LogArchiveWriter,
+) error {
+	streamReq := &StreamTurnLogsRequest{
+		FeatureFlags:      req.FeatureFlags,
+		DelegatedAuthInfo: req.DelegatedAuthInfo,
+		TenantID:          req.TenantID,
+		TaskID:            taskID,
+		TurnIndex:         turnIndex,
+		IncludeDeleted:    req.IncludeDeleted,
+	}
+	for {
+		body, err := client.StreamTurnLogs(ctx, streamReq)
+		if err != nil {
+			return err
+		}
+		if body == nil {
+			return nil
+		}
+
+		ended, err := exportStream(body, taskID, turnIndex, streamReq, w)
+		_ = body.Close()
+		if err != nil || ended {
+			return err
+		}
+	}
+}
+
+// exportStream writes the logs in a single response body. It returns true if the stream signaled its end.
+func exportStream(
+	body io.Reader,
+	taskID string,
+	turnIndex int,
+	streamReq *StreamTurnLogsRequest,
+	w *LogArchiveWriter,
+) (bool, error) {
+	dec := sse.NewDecoder(body)
+	for {
+		event, err := dec.Decode()
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+
+		if event.Type == EventTypeEnd {
+			return true, nil
+		}
+		if event.Type != EventTypeLog {
+			continue
+		}
+
+		index, err := strconv.Atoi(event.ID)
+		if err != nil {
+			return false, fmt.Errorf("log event has invalid id %q", event.ID)
+		}
+		log := &ArchivedLog{TaskID: taskID, TurnIndex: turnIndex, Index: index}
+		if err := json.Unmarshal([]byte(event.Data), &log.TurnLog); err != nil {
+			return false, err
+		}
+		if err := w.Write(log); err != nil {
+			return false, err
+		}
+		streamReq.LastEventID = util.Pointer(index)
+	}
+}
+
+// ImportLogsRequest identifies the turn that ImportLogs uploads logs to.
+type ImportLogsRequest struct {
+	FeatureFlags
+	DelegatedAuthInfo
+	TenantID  string
+	TaskID    string
+	TurnIndex int
+
+	// SourceTaskID and SourceTurnIndex select the turn to import from an archive that contains several turns.
+	SourceTaskID    *string
+	SourceTurnIndex *int
+}
+
+// ImportLogs uploads the logs in an archive to a turn, preserving their timestamps and indexes. Logs that the turn
+// already has (by index) are skipped, so an interrupted import can be run again. The archive must contain the logs of
+// a single turn, unless SourceTaskID or SourceTurnIndex selects one, and their indexes must follow on from the last
+// log the turn already has.
+func ImportLogs(ctx context.Context, client *Client, req *ImportLogsRequest, r *LogArchiveReader) error {
+	if req == nil {
+		return fmt.Errorf("req is nil")
+	}
+	if req.TenantID == "" {
+		return fmt.Errorf("tenant id is required")
+	}
+	if req.TaskID == "" {
+		return fmt.Errorf("task id is required")
+	}
+
+	logs, err := readArchivedTurn(req, r)
+	if err != nil {
+		return err
+	}
+
+	turn, err := client.GetTurn(
+		ctx, &GetTurnRequest{
+			FeatureFlags:      req.FeatureFlags,
+			DelegatedAuthInfo: req.DelegatedAuthInfo,
+			TenantID:          req.TenantID,
+			TaskID:            req.TaskID,
+			TurnIndex:         req.TurnIndex,
+		},
+	)
+	if err != nil {
+		return err
+	}
+	last, err := client.GetLastTurnLog(
+		ctx, &GetLastTurnLogRequest{
+			FeatureFlags:      req.FeatureFlags,
+			DelegatedAuthInfo: req.DelegatedAuthInfo,
+			TenantID:          req.TenantID,
+			TaskID:            req.TaskID,
+			TurnIndex:         req.TurnIndex,
+		},
+	)
+	switch {
+	case isNotFound(err):
+		last = nil
+	case err != nil:
+		return err
+	}
+	for last != nil && len(logs) > 0 && logs[0].Index <= last.Index {
+		logs = logs[1:]
+	}
+	if len(logs) == 0 {
+		return nil
+	}
+	if last != nil && logs[0].Index != last.Index+1 {
+		return fmt.Errorf("archive starts at index %d, but the turn's last log has index %d", logs[0].Index, last.Index)
+	}
+
+	ch := make(chan TurnLog)
+	lu := NewLogUploader(
+		&LogUploaderConfig{
+			Client:       client,
+			TenantID:     req.TenantID,
+			TaskID:       req.TaskID,
+			TurnIndex:    req.TurnIndex,
+			Version:      turn.Version,
+			StartIndex:   logs[0].Index,
+			Logs:         ch,
+			FeatureFlags: req.FeatureFlags.FeatureFlags,
+		},
+	)
+	for _, log := range logs {
+		select {
+		case ch <- log.TurnLog:
+		case <-lu.Done():
+			// The uploader stopped early, for example because an upload was rejected.
+			if err := lu.Err(); err != nil {
+				return err
+			}
+			return fmt.Errorf("log uploader stopped before every log was sent")
+		case <-ctx.Done():
+			_ = lu.Close()
+			return ctx.Err()
+		}
+	}
+	close(ch)
+
+	if err := lu.ShutdownContext(ctx); err != nil {
+		_ = lu.Close()
+		return err
+	}
+	return lu.Err()
+}
+
+// readArchivedTurn reads the logs of the selected turn from an archive, sorted by index. It fails if the archive
+// contains several turns and none is selected, or if the indexes have gaps.
+func readArchivedTurn(req *ImportLogsRequest, r *LogArchiveReader) ([]*ArchivedLog, error) {
+	var logs []*ArchivedLog
+	for {
+		log, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if req.SourceTaskID != nil && log.TaskID != *req.SourceTaskID {
+			continue
+		}
+		if req.SourceTurnIndex != nil && log.TurnIndex != *req.SourceTurnIndex {
+			continue
+		}
+		if len(logs) > 0 && (log.TaskID != logs[0].TaskID || log.TurnIndex != logs[0].TurnIndex) {
+			return nil, fmt.Errorf("archive contains logs for more than one turn; select the turn to import")
+		}
+		logs = append(logs, log)
+	}
+
+	sort.SliceStable(logs, func(i, j int) bool { return logs[i].Index < logs[j].Index })
+	for i := 1; i < len(logs); i++ {
+		if logs[i].Index != logs[i-1].Index+1 {
+			return nil, fmt.Errorf("archive is missing logs between index %d and %d", logs[i-1].Index, logs[i].Index)
+		}
+	}
+	return logs, nil
+}
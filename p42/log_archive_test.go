@@ -0,0 +1,351 @@
+package p42_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/plan42-ai/sdk-go/internal/util"
+	"github.com/plan42-ai/sdk-go/p42"
+	"github.com/stretchr/testify/require"
+)
+
+var archiveTime = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func readArchive(t *testing.T, data []byte) []p42.ArchivedLog {
+	t.Helper()
+	r, err := p42.NewLogArchiveReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	defer r.Close()
+
+	var logs []p42.ArchivedLog
+	for {
+		log, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return logs
+		}
+		require.NoError(t, err)
+		logs = append(logs, *log)
+	}
+}
+
+func TestLogArchiveRoundTrip(t *testing.T) {
+	t.Parallel()
+	logs := []p42.ArchivedLog{
+		{TaskID: "task", TurnIndex: 1, Index: 1, TurnLog: p42.TurnLog{Timestamp: archiveTime, Message: "a"}},
+		{
+			TaskID:    "task",
+			TurnIndex: 1,
+			Index:     2,
+			TurnLog:   p42.TurnLog{Timestamp: archiveTime.Add(time.Second), Message: "b", Level: p42.LogLevelWarn},
+		},
+	}
+
+	for _, compress := range []bool{false, true} {
+		t.Run(
+			fmt.Sprintf("compress=%v", compress), func(t *testing.T) {
+				t.Parallel()
+				var buf bytes.Buffer
+				w, err := p42.NewLogArchiveWriter(&buf, p42.LogArchiveFormatJSONL, compress)
+				require.NoError(t, err)
+				for i := range logs {
+					require.NoError(t, w.Write(&logs[i]))
+				}
+				require.NoError(t, w.Close())
+				require.Equal(t, compress, bytes.HasPrefix(buf.Bytes(), []byte{0x1f, 0x8b}))
+				require.Equal(t, logs, readArchive(t, buf.Bytes()))
+			},
+		)
+	}
+}
+
+func TestLogArchiveText(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	w, err := p42.NewLogArchiveWriter(&buf, p42.LogArchiveFormatText, false)
+	require.NoError(t, err)
+	require.NoError(
+		t,
+		w.Write(&p42.ArchivedLog{TaskID: "task", TurnIndex: 2, Index: 1, TurnLog: p42.TurnLog{Timestamp: archiveTime, Message: "hi"}}),
+	)
+	require.NoError(t, w.Close())
+	require.Equal(t, "2025-01-01T00:00:00Z [task/2] hi\n", buf.String())
+
+	_, err = p42.NewLogArchiveWriter(&buf, "xml", false)
+	require.Error(t, err)
+}
+
+// fakeArchiveServer serves the turns of a single task and their logs. The logs of each turn are split across two
+// streams, so that exporting must reconnect using Last-Event-ID. It also accepts log uploads.
+type fakeArchiveServer struct {
+	mu       sync.Mutex
+	turnLogs map[string][]string
+	uploaded []p42.UploadTurnLogsRequest
+	last     *p42.LastTurnLog
+
+	// If rejectAfter is set, uploads after the first rejectAfter are rejected as forbidden.
+	rejectAfter int
+}
+
+func (s *fakeArchiveServer) handler(t *testing.T) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(
+		"GET /v1/tenants/ten/tasks/task/turns", func(w http.ResponseWriter, r *http.Request) {
+			// Page through the turns one at a time, in reverse order.
+			resp := p42.ListTurnsResponse{Turns: []p42.Turn{{TaskID: "task", TurnIndex: 2}}, NextToken: util.Pointer("next")}
+			if r.URL.Query().Get("token") == "next" {
+				resp = p42.ListTurnsResponse{Turns: []p42.Turn{{TaskID: "task", TurnIndex: 1}}}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			require.NoError(t, json.NewEncoder(w).Encode(resp))
+		},
+	)
+	mux.HandleFunc(
+		"GET /v1/tenants/ten/tasks/task/turns/{turn}/logs", func(w http.ResponseWriter, r *http.Request) {
+			msgs := s.turnLogs[r.PathValue("turn")]
+			start, half := 0, (len(msgs)+1)/2
+			if id := r.Header.Get("Last-Event-ID"); id != "" {
+				_, _ = fmt.Sscan(id, &start)
+				half = len(msgs)
+			}
+			if start >= len(msgs) {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			w.Header().Set("Content-Type", "text/event-stream")
+			for i := start; i < half; i++ {
+				fmt.Fprintf(
+					w,
+					"id: %d\nevent: log\ndata: {\"Timestamp\":\"2025-01-01T00:00:00Z\",\"Message\":%q}\n\n",
+					i+1,
+					msgs[i],
+				)
+			}
+		},
+	)
+	mux.HandleFunc(
+		"GET /v1/tenants/ten/tasks/task/turns/1", func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			require.NoError(t, json.NewEncoder(w).Encode(p42.Turn{TaskID: "task", TurnIndex: 1, Version: 3}))
+		},
+	)
+	mux.HandleFunc(
+		"GET /v1/tenants/ten/tasks/task/turns/1/logs/last", func(w http.ResponseWriter, _ *http.Request) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if s.last == nil {
+				w.WriteHeader(http.StatusNotFound)
+				_ = json.NewEncoder(w).Encode(p42.Error{ResponseCode: http.StatusNotFound, Message: "not found"})
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			require.NoError(t, json.NewEncoder(w).Encode(s.last))
+		},
+	)
+	mux.HandleFunc(
+		"POST /v1/tenants/ten/tasks/task/turns/1/logs", func(w http.ResponseWriter, r *http.Request) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			var req p42.UploadTurnLogsRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			_, _ = fmt.Sscan(r.Header.Get("If-Match"), &req.Version)
+			if s.rejectAfter > 0 && len(s.uploaded) >= s.rejectAfter {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusForbidden)
+				_ = json.NewEncoder(w).Encode(p42.Error{ResponseCode: http.StatusForbidden, Message: "forbidden"})
+				return
+			}
+			s.uploaded = append(s.uploaded, req)
+			w.Header().Set("Content-Type", "application/json")
+			require.NoError(t, json.NewEncoder(w).Encode(p42.UploadTurnLogsResponse{Version: 4}))
+		},
+	)
+	return mux
+}
+
+func TestExportLogs(t *testing.T) {
+	t.Parallel()
+	fake := &fakeArchiveServer{turnLogs: map[string][]string{"1": {"a", "b", "c"}, "2": {"d"}}}
+	srv := httptest.NewServer(fake.handler(t))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	w, err := p42.NewLogArchiveWriter(&buf, p42.LogArchiveFormatJSONL, true)
+	require.NoError(t, err)
+	err = p42.ExportLogs(
+		context.Background(),
+		p42.NewClient(srv.URL),
+		&p42.ExportLogsRequest{TenantID: "ten", TaskID: util.Pointer("task")},
+		w,
+	)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	var got []string
+	for _, log := range readArchive(t, buf.Bytes()) {
+		require.Equal(t, "task", log.TaskID)
+		require.Equal(t, archiveTime, log.Timestamp.UTC())
+		got = append(got, fmt.Sprintf("%d/%d:%s", log.TurnIndex, log.Index, log.Message))
+	}
+	require.Equal(t, []string{"1/1:a", "1/2:b", "1/3:c", "2/1:d"}, got)
+}
+
+func TestExportLogsValidation(t *testing.T) {
+	t.Parallel()
+	w, err := p42.NewLogArchiveWriter(io.Discard, p42.LogArchiveFormatJSONL, false)
+	require.NoError(t, err)
+	client := p42.NewClient("http://localhost")
+
+	err = p42.ExportLogs(context.Background(), client, &p42.ExportLogsRequest{TenantID: "ten"}, w)
+	require.ErrorContains(t, err, "workstream id or task id is required")
+
+	err = p42.ExportLogs(
+		context.Background(),
+		client,
+		&p42.ExportLogsRequest{TenantID: "ten", WorkstreamID: util.Pointer("ws"), TaskID: util.Pointer("task")},
+		w,
+	)
+	require.ErrorContains(t, err, "can't be combined")
+}
+
+func archiveOf(t *testing.T, logs ...p42.ArchivedLog) *p42.LogArchiveReader {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := p42.NewLogArchiveWriter(&buf, p42.LogArchiveFormatJSONL, false)
+	require.NoError(t, err)
+	for i := range logs {
+		require.NoError(t, w.Write(&logs[i]))
+	}
+	require.NoError(t, w.Close())
+	r, err := p42.NewLogArchiveReader(&buf)
+	require.NoError(t, err)
+	return r
+}
+
+func archivedLog(taskID string, turnIndex, index int, msg string) p42.ArchivedLog {
+	return p42.ArchivedLog{
+		TaskID:    taskID,
+		TurnIndex: turnIndex,
+		Index:     index,
+		TurnLog:   p42.TurnLog{Timestamp: archiveTime.Add(time.Duration(index) * time.Second), Message: msg},
+	}
+}
+
+func TestImportLogs(t *testing.T) {
+	t.Parallel()
+	fake := &fakeArchiveServer{last: &p42.LastTurnLog{Index: 1}}
+	srv := httptest.NewServer(fake.handler(t))
+	defer srv.Close()
+
+	// The archive is out of order, and its first log was already imported.
+	r := archiveOf(
+		t,
+		archivedLog("old", 5, 3, "c"),
+		archivedLog("old", 5, 1, "a"),
+		archivedLog("old", 5, 2, "b"),
+		archivedLog("other", 1, 1, "x"),
+	)
+	err := p42.ImportLogs(
+		context.Background(),
+		p42.NewClient(srv.URL),
+		&p42.ImportLogsRequest{TenantID: "ten", TaskID: "task", TurnIndex: 1, SourceTaskID: util.Pointer("old")},
+		r,
+	)
+	require.NoError(t, err)
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	require.NotEmpty(t, fake.uploaded)
+	require.Equal(t, 3, fake.uploaded[0].Version)
+	require.Equal(t, 2, fake.uploaded[0].Index)
+	var got []string
+	for _, req := range fake.uploaded {
+		for _, log := range req.Logs {
+			got = append(got, log.Message)
+			require.Equal(t, archiveTime.Add(time.Duration(len(got)+1)*time.Second), log.Timestamp.UTC())
+		}
+	}
+	require.Equal(t, []string{"b", "c"}, got)
+}
+
+func TestImportLogsUploadRejected(t *testing.T) {
+	t.Parallel()
+	fake := &fakeArchiveServer{rejectAfter: 1}
+	srv := httptest.NewServer(fake.handler(t))
+	defer srv.Close()
+
+	// The archive needs several batches, and the uploader stops once the second one is rejected.
+	var logs []p42.ArchivedLog
+	for i := 1; i <= 5000; i++ {
+		logs = append(logs, archivedLog("task", 1, i, "log"))
+	}
+	err := p42.ImportLogs(
+		context.Background(),
+		p42.NewClient(srv.URL),
+		&p42.ImportLogsRequest{TenantID: "ten", TaskID: "task", TurnIndex: 1},
+		archiveOf(t, logs...),
+	)
+	require.ErrorContains(t, err, "forbidden")
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	require.Len(t, fake.uploaded, 1)
+}
+
+func TestImportLogsErrors(t *testing.T) {
+	t.Parallel()
+	fake := &fakeArchiveServer{last: &p42.LastTurnLog{Index: 5}}
+	srv := httptest.NewServer(fake.handler(t))
+	defer srv.Close()
+	client := p42.NewClient(srv.URL)
+	req := &p42.ImportLogsRequest{TenantID: "ten", TaskID: "task", TurnIndex: 1}
+
+	tests := []struct {
+		name     string
+		archive  []p42.ArchivedLog
+		expected string
+	}{
+		{
+			name:     "several turns",
+			archive:  []p42.ArchivedLog{archivedLog("task", 1, 1, "a"), archivedLog("task", 2, 1, "b")},
+			expected: "more than one turn",
+		},
+		{
+			name:     "gap in archive",
+			archive:  []p42.ArchivedLog{archivedLog("task", 1, 1, "a"), archivedLog("task", 1, 3, "c")},
+			expected: "missing logs between index 1 and 3",
+		},
+		{
+			name:     "gap after existing logs",
+			archive:  []p42.ArchivedLog{archivedLog("task", 1, 7, "a")},
+			expected: "archive starts at index 7",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(
+			tc.name, func(t *testing.T) {
+				err := p42.ImportLogs(context.Background(), client, req, archiveOf(t, tc.archive...))
+				require.ErrorContains(t, err, tc.expected)
+			},
+		)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	require.Empty(t, fake.uploaded)
+}
+
+func TestLogArchiveReaderEmpty(t *testing.T) {
+	t.Parallel()
+	require.Empty(t, readArchive(t, nil))
+	require.Empty(t, readArchive(t, []byte(strings.Repeat("\n", 3))))
+}
@@ -0,0 +1,208 @@
+package p42
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// TurnLogHandlerOptions holds configuration for a TurnLogHandler.
+type TurnLogHandlerOptions struct {
+	// Level is the minimum level that is logged. Defaults to slog.LevelInfo.
+	Level slog.Leveler
+
+	// Source and Stream, if set, are recorded on every log.
+	Source TurnLogSource
+	Stream TurnLogStream
+
+	// Done, if set, is closed when the consumer of the channel stops reading it, for example LogUploader.Done. Handle
+	// then fails with ErrTurnLogsDone, rather than blocking forever.
+	Done <-chan struct{}
+}
+
+// TurnLogHandler is a slog.Handler that converts records into TurnLog entries and sends them to a channel, typically
+// the Logs channel of a LogUploader. The record's message becomes the log message, its level becomes the log level,
+// and its attributes (including those added with WithAttrs and WithGroup) become the log attributes.
+//
+// Handle blocks until the entry is accepted by the channel, the context passed to it is done, or Done is closed.
+type TurnLogHandler struct {
+	logs   chan<- TurnLog
+	opts   TurnLogHandlerOptions
+	attrs  []groupedAttrs
+	groups []string
+}
+
+// groupedAttrs holds attributes added with WithAttrs, along with the groups that were open at the time.
+type groupedAttrs struct {
+	groups []string
+	attrs  []slog.Attr
+}
+
+// NewTurnLogHandler creates a TurnLogHandler that sends logs to the given channel.
+func NewTurnLogHandler(logs chan<- TurnLog, opts *TurnLogHandlerOptions) *TurnLogHandler {
+	h := &TurnLogHandler{logs: logs}
+	if opts != nil {
+		h.opts = *opts
+	}
+	if h.opts.Level == nil {
+		h.opts.Level = slog.LevelInfo
+	}
+	return h
+}
+
+// Enabled reports whether the handler handles records at the given level.
+func (h *TurnLogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.opts.Level.Level()
+}
+
+// Handle converts r into a TurnLog and sends it to the handler's channel.
+func (h *TurnLogHandler) Handle(ctx context.Context, r slog.Record) error {
+	entry := TurnLog{
+		Timestamp: r.Time,
+		Message:   r.Message,
+		Level:     logLevel(r.Level),
+		Stream:    h.opts.Stream,
+		Source:    h.opts.Source,
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+
+	attrs := make(map[string]any)
+	for _, ga := range h.attrs {
+		addAttrs(attrs, ga.groups, ga.attrs)
+	}
+	recordAttrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(
+		func(a slog.Attr) bool {
+			recordAttrs = append(recordAttrs, a)
+			return true
+		},
+	)
+	addAttrs(attrs, h.groups, recordAttrs)
+	if len(attrs) > 0 {
+		entry.Attributes = attrs
+	}
+
+	select {
+	case h.logs <- entry:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-h.opts.Done:
+		return ErrTurnLogsDone
+	}
+}
+
+// WithAttrs returns a handler that adds attrs to every log.
+func (h *TurnLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.attrs = append(slices.Clip(h.attrs), groupedAttrs{groups: h.groups, attrs: attrs})
+	return &h2
+}
+
+// WithGroup returns a handler that nests subsequent attributes under name.
+func (h *TurnLogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.groups = append(slices.Clip(h.groups), name)
+	return &h2
+}
+
+func logLevel(level slog.Level) LogLevel {
+	switch {
+	case level < slog.LevelInfo:
+		return LogLevelDebug
+	case level < slog.LevelWarn:
+		return LogLevelInfo
+	case level < slog.LevelError:
+		return LogLevelWarn
+	default:
+		return LogLevelError
+	}
+}
+
+// addAttrs adds attrs to dst, nested under groups. Following slog conventions, groups that end up empty are omitted,
+// as are attributes with empty keys, and the attributes of groups with empty keys are inlined.
+func addAttrs(dst map[string]any, groups []string, attrs []slog.Attr) {
+	values := make(map[string]any)
+	for _, a := range attrs {
+		addAttr(values, a)
+	}
+	if len(values) == 0 {
+		return
+	}
+	for _, g := range groups {
+		child, ok := dst[g].(map[string]any)
+		if !ok {
+			child = make(map[string]any)
+			dst[g] = child
+		}
+		dst = child
+	}
+	for k, v := range values {
+		dst[k] = v
+	}
+}
+
+func addAttr(dst map[string]any, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() == slog.KindGroup {
+		group := a.Value.Group()
+		if a.Key == "" {
+			for _, ga := range group {
+				addAttr(dst, ga)
+			}
+			return
+		}
+		child := make(map[string]any)
+		for _, ga := range group {
+			addAttr(child, ga)
+		}
+		if len(child) > 0 {
+			dst[a.Key] = child
+		}
+		return
+	}
+	if a.Key == "" {
+		return
+	}
+	dst[a.Key] = attrValue(a.Value)
+}
+
+// attrValue converts v into a value that can be encoded as JSON.
+func attrValue(v slog.Value) any {
+	switch v.Kind() {
+	case slog.KindString:
+		return v.String()
+	case slog.KindInt64:
+		return v.Int64()
+	case slog.KindUint64:
+		return v.Uint64()
+	case slog.KindFloat64:
+		return v.Float64()
+	case slog.KindBool:
+		return v.Bool()
+	case slog.KindDuration:
+		return v.Duration().String()
+	case slog.KindTime:
+		return v.Time().Format(time.RFC3339Nano)
+	default:
+		a := v.Any()
+		if err, ok := a.(error); ok {
+			return err.Error()
+		}
+		if _, err := json.Marshal(a); err != nil {
+			return fmt.Sprint(a)
+		}
+		return a
+	}
+}
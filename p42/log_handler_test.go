@@ -0,0 +1,176 @@
+package p42_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/plan42-ai/sdk-go/p42"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTurnLogJSONBackwardCompatible(t *testing.T) {
+	t.Parallel()
+	ts := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	b, err := json.Marshal(p42.TurnLog{Timestamp: ts, Message: "hello"})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"Timestamp":"2025-01-01T00:00:00Z","Message":"hello"}`, string(b))
+
+	var decoded p42.TurnLog
+	require.NoError(t, json.Unmarshal([]byte(`{"Timestamp":"2025-01-01T00:00:00Z","Message":"hello"}`), &decoded))
+	require.Equal(t, p42.TurnLog{Timestamp: ts, Message: "hello"}, decoded)
+}
+
+func TestTurnLogJSONStructured(t *testing.T) {
+	t.Parallel()
+	entry := p42.TurnLog{
+		Timestamp:  time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		Message:    "running tool",
+		Level:      p42.LogLevelWarn,
+		Stream:     p42.TurnLogStreamStderr,
+		Source:     p42.TurnLogSourceTool,
+		Attributes: map[string]any{"tool": "shell", "exitCode": float64(1)},
+	}
+	b, err := json.Marshal(entry)
+	require.NoError(t, err)
+
+	var decoded p42.TurnLog
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	require.Equal(t, entry, decoded)
+}
+
+func TestTurnLogHandler(t *testing.T) {
+	t.Parallel()
+	logs := make(chan p42.TurnLog, 10)
+	logger := slog.New(
+		p42.NewTurnLogHandler(
+			logs,
+			&p42.TurnLogHandlerOptions{Source: p42.TurnLogSourceAgent, Stream: p42.TurnLogStreamStdout},
+		),
+	)
+
+	logger.Debug("dropped")
+	logger.With("task", "t1").WithGroup("tool").Warn(
+		"tool failed",
+		"name", "shell",
+		"exitCode", 2,
+		"elapsed", 1500*time.Millisecond,
+		"error", errors.New("boom"),
+		slog.Group("", "inlined", true),
+		slog.Group("empty"),
+	)
+	logger.Info("plain")
+	close(logs)
+
+	var got []p42.TurnLog
+	for l := range logs {
+		got = append(got, l)
+	}
+	require.Len(t, got, 2)
+
+	require.Equal(t, "tool failed", got[0].Message)
+	require.Equal(t, p42.LogLevelWarn, got[0].Level)
+	require.Equal(t, p42.TurnLogSourceAgent, got[0].Source)
+	require.Equal(t, p42.TurnLogStreamStdout, got[0].Stream)
+	require.False(t, got[0].Timestamp.IsZero())
+	require.Equal(
+		t,
+		map[string]any{
+			"task": "t1",
+			"tool": map[string]any{
+				"name":     "shell",
+				"exitCode": int64(2),
+				"elapsed":  "1.5s",
+				"error":    "boom",
+				"inlined":  true,
+			},
+		},
+		got[0].Attributes,
+	)
+
+	require.Equal(t, "plain", got[1].Message)
+	require.Equal(t, p42.LogLevelInfo, got[1].Level)
+	require.Nil(t, got[1].Attributes)
+}
+
+func TestTurnLogHandlerLevels(t *testing.T) {
+	t.Parallel()
+	logs := make(chan p42.TurnLog, 10)
+	logger := slog.New(p42.NewTurnLogHandler(logs, &p42.TurnLogHandlerOptions{Level: slog.LevelDebug}))
+
+	logger.Debug("d")
+	logger.Info("i")
+	logger.Warn("w")
+	logger.Error("e")
+	close(logs)
+
+	var levels []p42.LogLevel
+	for l := range logs {
+		levels = append(levels, l.Level)
+	}
+	require.Equal(t, []p42.LogLevel{p42.LogLevelDebug, p42.LogLevelInfo, p42.LogLevelWarn, p42.LogLevelError}, levels)
+}
+
+func TestTurnLogHandlerContextDone(t *testing.T) {
+	t.Parallel()
+	h := p42.NewTurnLogHandler(make(chan p42.TurnLog), nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := h.Handle(ctx, slog.NewRecord(time.Now(), slog.LevelInfo, "blocked", 0))
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestTurnLogHandlerStopsWhenUploaderStops(t *testing.T) {
+	t.Parallel()
+	server := &fakeLogServer{
+		failures: []fakeUploadFailure{{err: &p42.Error{ResponseCode: http.StatusForbidden, Message: "forbidden"}}},
+	}
+	logs := make(chan p42.TurnLog)
+	cfg := newTestUploader(server, logs)
+	cfg.MaxBatchLen = 1
+	lu := p42.NewLogUploader(cfg)
+	logger := slog.New(p42.NewTurnLogHandler(logs, &p42.TurnLogHandlerOptions{Done: lu.Done()}))
+	h := logger.Handler()
+
+	// The first log fails to upload, which stops the uploader. Logging then fails instead of blocking.
+	logger.Info("a")
+	require.NoError(t, lu.ShutdownTimeout(2*time.Second))
+	err := h.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "b", 0))
+	require.ErrorIs(t, err, p42.ErrTurnLogsDone)
+	require.Error(t, lu.Err())
+}
+
+func TestTurnLogHandlerFeedsUploader(t *testing.T) {
+	server := &fakeLogServer{}
+	logs := make(chan p42.TurnLog)
+	lu := p42.NewLogUploader(newTestUploader(server, logs))
+
+	logger := slog.New(p42.NewTurnLogHandler(logs, &p42.TurnLogHandlerOptions{Source: p42.TurnLogSourceModel}))
+	logger.Info("thinking", "tokens", 42)
+	logger.Error("failed", "reason", "timeout")
+	close(logs)
+	require.NoError(t, lu.ShutdownTimeout(2*time.Second))
+
+	require.Len(t, server.stored, 2)
+	require.Equal(t, p42.TurnLogSourceModel, server.stored[0].Source)
+	require.Equal(t, map[string]any{"tokens": int64(42)}, server.stored[0].Attributes)
+	require.Equal(t, p42.LogLevelError, server.stored[1].Level)
+}
+
+func TestLogUploaderDropsUnencodableAttributes(t *testing.T) {
+	server := &fakeLogServer{}
+	logs := make(chan p42.TurnLog)
+	lu := p42.NewLogUploader(newTestUploader(server, logs))
+
+	logs <- p42.TurnLog{Message: "bad", Attributes: map[string]any{"fn": func() {}}}
+	close(logs)
+	require.NoError(t, lu.ShutdownTimeout(2*time.Second))
+	require.NoError(t, lu.Err())
+	require.Equal(t, []string{"bad"}, server.messages())
+	require.Nil(t, server.stored[0].Attributes)
+}
@@ -0,0 +1,211 @@
+package p42
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultRedactionMask is the text that replaces redacted values when RedactorConfig.Mask is empty.
+	DefaultRedactionMask = "[REDACTED]"
+
+	// RedactionRuleSecret is the rule name used when a known secret value is redacted.
+	RedactionRuleSecret = "secret"
+
+	RedactionRuleGithubToken        = "github-token" // #nosec: G101: This is a rule name, not a credential.
+	RedactionRuleJWT                = "jwt"
+	RedactionRuleAWSAccessKeyID     = "aws-access-key-id"
+	RedactionRuleAWSSecretAccessKey = "aws-secret-access-key" // #nosec: G101: This is a rule name, not a credential.
+
+	// minSecretLen is the length below which known secrets are ignored. Masking very short values (e.g. "1") would
+	// destroy the log without protecting anything.
+	minSecretLen = 4
+)
+
+type redactionPattern struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// defaultRedactionPatterns matches common token formats. If a pattern contains a group named "secret", only that
+// group is masked, so that surrounding context (e.g. the variable name) is preserved.
+var defaultRedactionPatterns = []redactionPattern{
+	{
+		name: RedactionRuleGithubToken,
+		re:   regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{22,255})\b`),
+	},
+	{
+		name: RedactionRuleJWT,
+		re:   regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{10,}`),
+	},
+	{
+		name: RedactionRuleAWSAccessKeyID,
+		re:   regexp.MustCompile(`\b(?:AKIA|ASIA)[A-Z0-9]{16}\b`),
+	},
+	{
+		name: RedactionRuleAWSSecretAccessKey,
+		re: regexp.MustCompile(
+			`(?i)aws_?secret_?access_?key["']?\s*[:=]\s*["']?(?P<secret>[A-Za-z0-9/+=]{40})\b`,
+		),
+	},
+}
+
+// RedactorConfig holds configuration for a Redactor.
+type RedactorConfig struct {
+	// Secrets are known secret values that should be masked wherever they appear.
+	Secrets []string
+
+	// Mask is the replacement text for redacted values. Defaults to DefaultRedactionMask.
+	Mask string
+
+	// DisableDefaultPatterns disables matching of common token formats (GitHub tokens, JWTs, AWS keys), so that only
+	// known secrets are masked.
+	DisableDefaultPatterns bool
+}
+
+// Redactor masks secret values in turn logs. It is safe for concurrent use.
+type Redactor struct {
+	mu       sync.Mutex
+	secrets  []string
+	patterns []redactionPattern
+	mask     string
+	counts   map[string]int64
+}
+
+// NewRedactor creates a Redactor.
+func NewRedactor(cfg *RedactorConfig) *Redactor {
+	if cfg == nil {
+		cfg = &RedactorConfig{}
+	}
+	r := &Redactor{
+		mask:   cfg.Mask,
+		counts: make(map[string]int64),
+	}
+	if r.mask == "" {
+		r.mask = DefaultRedactionMask
+	}
+	if !cfg.DisableDefaultPatterns {
+		r.patterns = defaultRedactionPatterns
+	}
+	r.AddSecrets(cfg.Secrets...)
+	return r
+}
+
+// AddSecrets registers additional known secret values.
+func (r *Redactor) AddSecrets(secrets ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, secret := range secrets {
+		if len(secret) < minSecretLen {
+			continue
+		}
+		r.secrets = append(r.secrets, secret)
+	}
+
+	// Replace longer secrets first, so that a secret that contains another secret is masked as a whole.
+	sort.SliceStable(r.secrets, func(i, j int) bool { return len(r.secrets[i]) > len(r.secrets[j]) })
+}
+
+// Redact returns s with all known secrets and token patterns masked.
+func (r *Redactor) Redact(s string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, secret := range r.secrets {
+		if n := strings.Count(s, secret); n > 0 {
+			s = strings.ReplaceAll(s, secret, r.mask)
+			r.counts[RedactionRuleSecret] += int64(n)
+		}
+	}
+
+	for _, p := range r.patterns {
+		s = r.redactPattern(s, p)
+	}
+	return s
+}
+
+func (r *Redactor) redactPattern(s string, p redactionPattern) string {
+	group := p.re.SubexpIndex("secret")
+	matches := p.re.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s
+	}
+
+	var sb strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if group > 0 && m[2*group] >= 0 {
+			start, end = m[2*group], m[2*group+1]
+		}
+		sb.WriteString(s[last:start])
+		sb.WriteString(r.mask)
+		last = end
+	}
+	sb.WriteString(s[last:])
+
+	r.counts[p.name] += int64(len(matches))
+	return sb.String()
+}
+
+// RedactTurnLog returns a copy of entry with its message and any string attributes redacted.
+func (r *Redactor) RedactTurnLog(entry TurnLog) TurnLog {
+	entry.Message = r.Redact(entry.Message)
+	if entry.Attributes != nil {
+		entry.Attributes = r.redactAttributes(entry.Attributes)
+	}
+	return entry
+}
+
+// redactAttributes returns a copy of attrs with string values redacted, descending into nested maps and slices.
+func (r *Redactor) redactAttributes(attrs map[string]any) map[string]any {
+	ret := make(map[string]any, len(attrs))
+	for k, v := range attrs {
+		ret[k] = r.redactValue(v)
+	}
+	return ret
+}
+
+func (r *Redactor) redactValue(v any) any {
+	switch v := v.(type) {
+	case string:
+		return r.Redact(v)
+	case map[string]any:
+		return r.redactAttributes(v)
+	case []any:
+		ret := make([]any, len(v))
+		for i, item := range v {
+			ret[i] = r.redactValue(item)
+		}
+		return ret
+	default:
+		return v
+	}
+}
+
+// Counts returns the number of redactions performed so far, keyed by rule name.
+func (r *Redactor) Counts() map[string]int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ret := make(map[string]int64, len(r.counts))
+	for k, v := range r.counts {
+		ret[k] = v
+	}
+	return ret
+}
+
+// Total returns the total number of redactions performed so far.
+func (r *Redactor) Total() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var total int64
+	for _, v := range r.counts {
+		total += v
+	}
+	return total
+}
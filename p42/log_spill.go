@@ -0,0 +1,163 @@
+package p42
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// spilledLog is the on-disk representation of a pending log entry.
+type spilledLog struct {
+	Index int     `json:"Index"`
+	Log   TurnLog `json:"Log"`
+}
+
+// logSpill is a write-ahead file of pending log entries, stored as JSON lines. Entries are appended at the end and
+// read back in order. Reads only advance an in-memory cursor: entries remain on disk until the file is reset, so
+// entries that were read back but not yet uploaded survive a crash. Because of this, the file may contain entries that
+// were already uploaded, or the same entry more than once. Readers of an existing file (see openLogSpill) must
+// de-duplicate by Index and skip entries the server already has.
+type logSpill struct {
+	path     string
+	f        *os.File
+	readOff  int64
+	writeOff int64
+	unread   int
+}
+
+// openLogSpill opens (or creates) the spill file at path. Any entries already in the file are returned sorted by
+// Index, with duplicates removed. A partially written trailing line (e.g. from a crash mid-write) is discarded.
+func openLogSpill(path string) (*logSpill, []spilledLog, error) {
+	// #nosec G304: The spill path is supplied by the caller.
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, nil, err
+	}
+	s := &logSpill{path: path, f: f}
+
+	existing, end, err := s.scan()
+	if err != nil {
+		_ = f.Close()
+		return nil, nil, err
+	}
+	if err := f.Truncate(end); err != nil {
+		_ = f.Close()
+		return nil, nil, err
+	}
+	s.readOff = end
+	s.writeOff = end
+
+	sort.SliceStable(existing, func(i, j int) bool { return existing[i].Index < existing[j].Index })
+	deduped := existing[:0]
+	for _, e := range existing {
+		if len(deduped) > 0 && deduped[len(deduped)-1].Index == e.Index {
+			continue
+		}
+		deduped = append(deduped, e)
+	}
+	return s, deduped, nil
+}
+
+// scan reads every complete entry in the file. It returns the entries and the offset just past the last complete
+// line.
+func (s *logSpill) scan() ([]spilledLog, int64, error) {
+	br := bufio.NewReader(io.NewSectionReader(s.f, 0, 1<<62))
+	var ret []spilledLog
+	var off int64
+	for {
+		line, err := br.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return ret, off, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		var e spilledLog
+		if err := json.Unmarshal(bytes.TrimSpace(line), &e); err != nil {
+			return nil, 0, fmt.Errorf("corrupt log spill file %s at offset %d: %w", s.path, off, err)
+		}
+		ret = append(ret, e)
+		off += int64(len(line))
+	}
+}
+
+// append writes entries to the end of the file, and syncs it, so that they survive a crash.
+func (s *logSpill) append(entries ...spilledLog) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	n, err := s.f.WriteAt(buf.Bytes(), s.writeOff)
+	s.writeOff += int64(n)
+	if err != nil {
+		return err
+	}
+	if err := s.f.Sync(); err != nil {
+		return err
+	}
+	s.unread += len(entries)
+	return nil
+}
+
+// len returns the number of entries that have been appended but not yet read.
+func (s *logSpill) len() int {
+	return s.unread
+}
+
+// read returns up to n unread entries, stopping early once their combined size would exceed maxBytes. At
+// least one entry is returned if any are unread.
+func (s *logSpill) read(n int, maxBytes int) ([]spilledLog, error) {
+	if s.unread == 0 || n <= 0 {
+		return nil, nil
+	}
+	br := bufio.NewReader(io.NewSectionReader(s.f, s.readOff, s.writeOff-s.readOff))
+	var ret []spilledLog
+	size := 0
+	for len(ret) < n && s.unread > 0 {
+		line, err := br.ReadBytes('\n')
+		if err != nil {
+			return ret, err
+		}
+		var e spilledLog
+		if err := json.Unmarshal(bytes.TrimSpace(line), &e); err != nil {
+			return ret, err
+		}
+		size += turnLogSize(e.Log)
+		if len(ret) > 0 && size > maxBytes {
+			break
+		}
+		ret = append(ret, e)
+		s.readOff += int64(len(line))
+		s.unread--
+	}
+	return ret, nil
+}
+
+// reset discards the contents of the file. It must only be called once every entry has been uploaded.
+func (s *logSpill) reset() error {
+	if err := s.f.Truncate(0); err != nil {
+		return err
+	}
+	s.readOff = 0
+	s.writeOff = 0
+	s.unread = 0
+	return nil
+}
+
+// close syncs and closes the file. If remove is true the file is deleted.
+func (s *logSpill) close(remove bool) error {
+	if remove {
+		_ = s.f.Close()
+		return os.Remove(s.path)
+	}
+	err := s.f.Sync()
+	return errors.Join(err, s.f.Close())
+}
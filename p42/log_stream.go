@@ -1,20 +1,42 @@
 package p42
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
 	"errors"
 	"io"
 	"log/slog"
 	"strconv"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/plan42-ai/concurrency"
 	"github.com/plan42-ai/sdk-go/internal/util"
+	"github.com/plan42-ai/sdk-go/p42/sse"
 )
 
+// Event types sent on a turn log stream.
+const (
+	// EventTypeLog events carry a TurnLog.
+	EventTypeLog = "log"
+
+	// EventTypeTurnStatus events carry a TurnStatusEvent. They are sent when the status of the turn changes.
+	EventTypeTurnStatus = "turn-status"
+
+	// EventTypeEnd events mark the end of the stream. No more logs will be sent for the turn.
+	EventTypeEnd = "end"
+)
+
+// TurnStatusEvent is the payload of an EventTypeTurnStatus event.
+type TurnStatusEvent struct {
+	Status      TurnStatus `json:"Status"`
+	CompletedAt *time.Time `json:"CompletedAt,omitempty"`
+	Version     int        `json:"Version"`
+}
+
+// EventHandler is called for each event of the type it is subscribed to.
+type EventHandler func(event *sse.Event)
+
 // LogStream streams logs for a turn using Server-Sent Events.
 type LogStream struct {
 	cg             *concurrency.ContextGroup
@@ -29,6 +51,11 @@ type LogStream struct {
 	lastID         int
 	retry          time.Duration
 	backoff        *util.Backoff
+	handlers       map[string][]EventHandler
+	stopAfterFunc  func() bool
+
+	errMu sync.Mutex
+	err   error
 }
 
 type LogStreamOption func(s *LogStream)
@@ -57,6 +84,31 @@ func WithDelegatedAuth(delegatedAuth DelegatedAuthInfo) LogStreamOption {
 	}
 }
 
+// WithEventHandler subscribes handler to events of the given type. Handlers are called from the stream's goroutine,
+// in the order the events are received, so they should not block. Log events are still sent to the Logs channel.
+func WithEventHandler(eventType string, handler EventHandler) LogStreamOption {
+	return func(s *LogStream) {
+		if s.handlers == nil {
+			s.handlers = make(map[string][]EventHandler)
+		}
+		s.handlers[eventType] = append(s.handlers[eventType], handler)
+	}
+}
+
+// WithTurnStatusHandler subscribes handler to turn status events.
+func WithTurnStatusHandler(handler func(status TurnStatusEvent)) LogStreamOption {
+	return WithEventHandler(
+		EventTypeTurnStatus, func(event *sse.Event) {
+			var status TurnStatusEvent
+			if err := json.Unmarshal([]byte(event.Data), &status); err != nil {
+				slog.Error("LogStream: failed to decode turn status", "error", err)
+				return
+			}
+			handler(status)
+		},
+	)
+}
+
 // NewLogStream creates and starts a LogStream.
 func NewLogStream(
 	client *Client,
@@ -64,6 +116,19 @@ func NewLogStream(
 	turnIndex int,
 	buffer int,
 	options ...LogStreamOption,
+) *LogStream {
+	return NewLogStreamContext(context.Background(), client, tenantID, taskID, turnIndex, buffer, options...)
+}
+
+// NewLogStreamContext creates and starts a LogStream that stops when ctx is done. In that case Err returns the
+// context's error.
+func NewLogStreamContext(
+	ctx context.Context,
+	client *Client,
+	tenantID, taskID string,
+	turnIndex int,
+	buffer int,
+	options ...LogStreamOption,
 ) *LogStream {
 	ls := &LogStream{
 		cg:        concurrency.NewContextGroup(),
@@ -79,6 +144,13 @@ func NewLogStream(
 		opt(ls)
 	}
 
+	ls.stopAfterFunc = context.AfterFunc(
+		ctx, func() {
+			ls.setErr(ctx.Err())
+			ls.cg.Cancel()
+		},
+	)
+
 	ls.cg.Add(1)
 	go ls.run()
 	return ls
@@ -96,10 +168,27 @@ func (l *LogStream) ShutdownContext(ctx context.Context) error { return l.cg.Wai
 // ShutdownTimeout waits for the stream to finish with a timeout.
 func (l *LogStream) ShutdownTimeout(d time.Duration) error { return l.cg.WaitTimeout(d) }
 
+// Err returns the error that stopped the stream. It returns nil if the stream ended normally, because the turn has no
+// more logs, or was stopped with Close. Err should be called once the Logs channel is closed.
+func (l *LogStream) Err() error {
+	l.errMu.Lock()
+	defer l.errMu.Unlock()
+	return l.err
+}
+
+func (l *LogStream) setErr(err error) {
+	l.errMu.Lock()
+	defer l.errMu.Unlock()
+	if l.err == nil {
+		l.err = err
+	}
+}
+
 func (l *LogStream) run() {
 	defer l.cg.Done()
 	defer l.cg.Cancel()
 	defer close(l.logs)
+	defer l.stopAfterFunc()
 
 	for {
 		if err := l.backoff.WaitAtLeast(l.cg.Context(), l.retry); err != nil {
@@ -118,6 +207,11 @@ func (l *LogStream) run() {
 		if l.cg.Context().Err() != nil {
 			return
 		}
+		if !isRetryableError(err) {
+			slog.ErrorContext(l.cg.Context(), "LogStream: permanent stream error", "error", err)
+			l.setErr(err)
+			return
+		}
 		slog.ErrorContext(l.cg.Context(), "LogStream: stream error", "error", err)
 		l.backoff.Backoff()
 	}
@@ -152,113 +246,54 @@ func (l *LogStream) connectAndStream(ctx context.Context) error {
 	return l.consume(ctx, body)
 }
 
-// sseEvent represents a Server-Sent Event being parsed.
-type sseEvent struct {
-	eventType string
-	dataBuf   strings.Builder
-	id        *int
-	retry     *int
-}
-
-// reset clears the event state for reuse.
-func (e *sseEvent) reset() {
-	e.eventType = ""
-	e.dataBuf.Reset()
-	e.id = nil
-	e.retry = nil
-}
-
 func (l *LogStream) consume(ctx context.Context, r io.Reader) error {
-	br := bufio.NewReader(r)
-	event := &sseEvent{}
-
+	dec := sse.NewDecoder(r)
 	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
+		if err := ctx.Err(); err != nil {
+			return err
 		}
 
-		line, err := br.ReadString('\n')
+		event, err := dec.Decode()
+		if dec.Retry() != 0 {
+			l.retry = dec.Retry()
+		}
 		if err != nil {
 			if errors.Is(err, io.EOF) {
 				return nil
 			}
 			return err
 		}
-		line = strings.TrimRight(line, "\r\n")
-
-		// Empty line indicates end of an event
-		if line == "" {
-			if err := l.processCompleteEvent(ctx, event); err != nil {
-				return err
-			}
-			event.reset()
-			continue
-		}
-
-		l.parseSSELine(event, line)
-	}
-}
 
-// parseSSELine processes a single line of an SSE stream.
-func (l *LogStream) parseSSELine(event *sseEvent, line string) {
-	// Skip comments
-	if strings.HasPrefix(line, ":") {
-		return
-	}
-
-	idx := strings.Index(line, ":")
-	if idx == -1 {
-		return
-	}
-
-	field := strings.TrimSpace(line[:idx])
-	value := strings.TrimSpace(line[idx+1:])
-
-	switch field {
-	case "event":
-		event.eventType = value
-	case "data":
-		if event.dataBuf.Len() > 0 {
-			event.dataBuf.WriteByte('\n')
+		if err := l.processEvent(ctx, event); err != nil {
+			return err
 		}
-		event.dataBuf.WriteString(value)
-	case "id":
-		if v, err := strconv.Atoi(value); err == nil {
-			event.id = util.Pointer(v)
+		if id, err := strconv.Atoi(event.ID); err == nil {
+			l.lastID = id
 		}
-	case "retry":
-		if v, err := strconv.Atoi(value); err == nil {
-			event.retry = util.Pointer(v)
+		if event.Type == EventTypeEnd {
+			return io.EOF
 		}
 	}
 }
 
-// processCompleteEvent handles a completed SSE event.
-func (l *LogStream) processCompleteEvent(ctx context.Context, event *sseEvent) error {
-	if event.eventType != "log" || event.dataBuf.Len() == 0 {
-		return nil
-	}
-
-	var logEntry TurnLog
-	if err := json.Unmarshal([]byte(event.dataBuf.String()), &logEntry); err != nil {
-		slog.ErrorContext(ctx, "LogStream: failed to decode log", "error", err)
-		return nil
-	}
+// processEvent delivers a log event to the Logs channel, and passes every event to its subscribed handlers.
+func (l *LogStream) processEvent(ctx context.Context, event *sse.Event) error {
+	if event.Type == EventTypeLog {
+		var logEntry TurnLog
+		if err := json.Unmarshal([]byte(event.Data), &logEntry); err != nil {
+			slog.ErrorContext(ctx, "LogStream: failed to decode log", "error", err)
+			return nil
+		}
 
-	select {
-	case l.logs <- logEntry:
-	case <-ctx.Done():
-		return ctx.Err()
+		select {
+		case l.logs <- logEntry:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
 	}
 
-	if event.id != nil {
-		l.lastID = *event.id
+	for _, handler := range l.handlers[event.Type] {
+		handler(event)
 	}
-	if event.retry != nil {
-		l.retry = time.Duration(*event.retry) * time.Millisecond
-	}
-
 	return nil
 }
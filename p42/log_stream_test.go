@@ -1,6 +1,8 @@
 package p42_test
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -10,6 +12,8 @@ import (
 	"time"
 
 	"github.com/plan42-ai/sdk-go/p42"
+	"github.com/plan42-ai/sdk-go/p42/sse"
+	"github.com/stretchr/testify/require"
 )
 
 func TestLogStream(t *testing.T) {
@@ -207,3 +211,196 @@ func getLogStreamLastID(ls interface{}) int {
 	}
 	return -1
 }
+
+func TestLogStreamStructuredLogs(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				fmt.Fprintf(
+					w,
+					"id: 1\nevent: log\ndata: {\"Timestamp\":\"2025-01-01T00:00:00Z\",\"Message\":\"one\","+
+						"\"Level\":\"Error\",\"Stream\":\"Stderr\",\"Source\":\"Tool\",\"Attributes\":{\"tool\":\"shell\"}}\n\n",
+				)
+				fmt.Fprintf(w, "id: 2\nevent: log\ndata: {\"Timestamp\":\"2025-01-01T00:00:01Z\",\"Message\":\"two\"}\n\n")
+			},
+		),
+	)
+	defer srv.Close()
+
+	ls := p42.NewLogStream(p42.NewClient(srv.URL), "ten", "task", 0, 10)
+	defer ls.Close()
+
+	var logs []p42.TurnLog
+	for log := range ls.Logs() {
+		logs = append(logs, log)
+		if len(logs) == 2 {
+			break
+		}
+	}
+
+	expected := []p42.TurnLog{
+		{
+			Timestamp:  time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			Message:    "one",
+			Level:      p42.LogLevelError,
+			Stream:     p42.TurnLogStreamStderr,
+			Source:     p42.TurnLogSourceTool,
+			Attributes: map[string]any{"tool": "shell"},
+		},
+		{
+			Timestamp: time.Date(2025, 1, 1, 0, 0, 1, 0, time.UTC),
+			Message:   "two",
+		},
+	}
+	if !reflect.DeepEqual(expected, logs) {
+		t.Fatalf("unexpected logs: %#v", logs)
+	}
+}
+
+func TestLogStreamEvents(t *testing.T) {
+	t.Parallel()
+
+	var calls int
+	srv := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, _ *http.Request) {
+				calls++
+				w.Header().Set("Content-Type", "text/event-stream")
+				fmt.Fprintf(w, "id: 1\nevent: log\ndata: {\"Timestamp\":\"2025-01-01T00:00:00Z\",\"Message\":\"one\"}\n\n")
+				fmt.Fprintf(w, "event: turn-status\ndata: {\"Status\":\"Succeeded\",\"Version\":3}\n\n")
+				fmt.Fprintf(w, "id: 2\nevent: custom\ndata: first line\ndata: second line\n\n")
+				fmt.Fprintf(w, "event: end\ndata: {}\n\n")
+			},
+		),
+	)
+	defer srv.Close()
+
+	var statuses []p42.TurnStatusEvent
+	var custom []sse.Event
+	ls := p42.NewLogStream(
+		p42.NewClient(srv.URL),
+		"ten",
+		"task",
+		0,
+		10,
+		p42.WithTurnStatusHandler(func(status p42.TurnStatusEvent) { statuses = append(statuses, status) }),
+		p42.WithEventHandler("custom", func(event *sse.Event) { custom = append(custom, *event) }),
+	)
+
+	var logs []p42.TurnLog
+	for log := range ls.Logs() {
+		logs = append(logs, log)
+	}
+	require.NoError(t, ls.ShutdownTimeout(2*time.Second))
+
+	// The end event stops the stream without reconnecting.
+	require.Equal(t, 1, calls)
+	require.Len(t, logs, 1)
+	require.Equal(t, []p42.TurnStatusEvent{{Status: "Succeeded", Version: 3}}, statuses)
+	require.Equal(t, []sse.Event{{Type: "custom", Data: "first line\nsecond line", ID: "2"}}, custom)
+	require.Equal(t, 2, getLogStreamLastID(ls))
+}
+
+func TestLogStreamPermanentError(t *testing.T) {
+	t.Parallel()
+
+	var calls int
+	srv := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, _ *http.Request) {
+				calls++
+				w.WriteHeader(http.StatusForbidden)
+				_ = json.NewEncoder(w).Encode(
+					p42.Error{ResponseCode: http.StatusForbidden, Message: "forbidden", ErrorType: "Forbidden"},
+				)
+			},
+		),
+	)
+	defer srv.Close()
+
+	ls := p42.NewLogStream(p42.NewClient(srv.URL), "ten", "task", 0, 10)
+	for range ls.Logs() {
+	}
+	require.NoError(t, ls.ShutdownTimeout(2*time.Second))
+
+	require.Equal(t, 1, calls)
+	var apiErr *p42.Error
+	require.ErrorAs(t, ls.Err(), &apiErr)
+	require.Equal(t, http.StatusForbidden, apiErr.ResponseCode)
+}
+
+func TestLogStreamInvalidRequest(t *testing.T) {
+	t.Parallel()
+
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { calls++ }))
+	defer srv.Close()
+
+	// Invalid requests fail before reaching the server, and are reported rather than retried.
+	ls := p42.NewLogStream(p42.NewClient(srv.URL), "", "task", 0, 10)
+	for range ls.Logs() {
+	}
+	require.NoError(t, ls.ShutdownTimeout(2*time.Second))
+	require.Zero(t, calls)
+	require.EqualError(t, ls.Err(), "tenant id is required")
+}
+
+func TestLogStreamRetriesTransientErrors(t *testing.T) {
+	t.Parallel()
+
+	var calls int
+	srv := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, _ *http.Request) {
+				calls++
+				if calls == 1 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					_ = json.NewEncoder(w).Encode(
+						p42.Error{ResponseCode: http.StatusServiceUnavailable, Message: "unavailable"},
+					)
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+			},
+		),
+	)
+	defer srv.Close()
+
+	ls := p42.NewLogStream(p42.NewClient(srv.URL), "ten", "task", 0, 10)
+	for range ls.Logs() {
+	}
+	require.NoError(t, ls.ShutdownTimeout(2*time.Second))
+
+	require.Equal(t, 2, calls)
+	require.NoError(t, ls.Err())
+}
+
+func TestLogStreamContextCanceled(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				if f, ok := w.(http.Flusher); ok {
+					f.Flush()
+				}
+				<-r.Context().Done()
+			},
+		),
+	)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ls := p42.NewLogStreamContext(ctx, p42.NewClient(srv.URL), "ten", "task", 0, 10)
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	for range ls.Logs() {
+	}
+	require.NoError(t, ls.ShutdownTimeout(2*time.Second))
+	require.ErrorIs(t, ls.Err(), context.Canceled)
+}
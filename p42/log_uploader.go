@@ -4,10 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/plan42-ai/concurrency"
+	"github.com/plan42-ai/sdk-go/internal/util"
 )
 
 // LogUploaderConfig holds configuration for LogUploader.
@@ -25,41 +32,106 @@ type LogUploaderConfig struct {
 	MaxBatchLen   int
 	MaxBatchAge   time.Duration
 	MaxBatchBytes int
+
+	// Redactor, if set, masks secrets in log messages before they are batched.
+	Redactor *Redactor
+
+	// MaxPendingBytes bounds the size of the logs held in memory while they wait to be uploaded. Once it is reached,
+	// new logs are written to SpillPath. If SpillPath is empty, the uploader stops reading from Logs until uploads
+	// catch up.
+	MaxPendingBytes int
+
+	// SpillPath, if set, is the path of a write-ahead file that holds pending logs that don't fit in memory, and logs
+	// that could not be uploaded before shutdown. Logs left in the file are uploaded by the next LogUploader that is
+	// started with the same path.
+	SpillPath string
+
+	// Resume starts uploading after the last log the server already has, as reported by GetLastTurnLog, rather than
+	// at StartIndex. StartIndex is still used if the turn has no logs. It requires a Client that implements
+	// LogUploaderResumeClient.
+	Resume bool
+
+	// MinRetryBackoff and MaxRetryBackoff bound the backoff between retries of failed uploads.
+	MinRetryBackoff time.Duration
+	MaxRetryBackoff time.Duration
+
+	// OnError, if set, is called with every error the uploader encounters, including errors that are retried. It is
+	// called from the uploader's goroutine, so it must not block.
+	OnError func(err error)
 }
 
+// ErrTurnCompleted is returned by LogUploader.Err when the uploader stopped because the turn completed before all
+// logs were uploaded.
+var ErrTurnCompleted = errors.New("turn is completed")
+
 // LogUploaderClient abstracts the Client method used by LogUploader.
 type LogUploaderClient interface {
 	UploadTurnLogs(ctx context.Context, req *UploadTurnLogsRequest) (*UploadTurnLogsResponse, error)
 }
 
+// LogUploaderResumeClient is implemented by LogUploaderClients that can report the last log the server has for a
+// turn, as *Client does. LogUploader uses it, when the client implements it, to resume uploads and to avoid uploading
+// a batch again after a failed request that was stored anyway. Without it, Resume is ignored.
+type LogUploaderResumeClient interface {
+	GetLastTurnLog(ctx context.Context, req *GetLastTurnLogRequest) (*LastTurnLog, error)
+}
+
 // LogUploader batches logs from a channel and uploads them using the API.
+//
+// Each log is assigned an Index when it is read from the channel, and is uploaded with that index exactly once.
+// Failed uploads are retried with backoff. Before a retry, the uploader asks the server for the last log it has, if the
+// client implements LogUploaderResumeClient, so that a batch that was stored despite the error is not uploaded again.
 type LogUploader struct {
 	cg *concurrency.ContextGroup
 
-	client    LogUploaderClient
-	tenantID  string
-	taskID    string
-	turnIndex int
-	version   int
-	index     int
+	client       LogUploaderClient
+	resumeClient LogUploaderResumeClient
+	tenantID     string
+	taskID       string
+	turnIndex    int
+	version      int
+	nextIndex    int
+	resume       bool
 
 	logs <-chan TurnLog
 
 	featureFlags map[string]bool
+	redactor     *Redactor
+
+	maxBatchLen     int
+	maxBatchAge     time.Duration
+	maxBatchBytes   int
+	maxPendingBytes int
 
-	maxBatchLen   int
-	maxBatchAge   time.Duration
-	maxBatchBytes int
+	pending      []pendingLog
+	pendingBytes int
+	spillPath    string
+	spill        *logSpill
+	drained      bool
 
-	batch      []TurnLog
-	batchBytes int
+	backoff    *util.Backoff
+	retrying   bool
 	timer      *time.Timer
+	retryTimer *time.Timer
+
+	onError func(err error)
+	errMu   sync.Mutex
+	err     error
+}
+
+type pendingLog struct {
+	index int
+	log   TurnLog
+	size  int
 }
 
 const (
-	defaultMaxBatchLen   = 500
-	defaultMaxBatchAge   = time.Second
-	defaultMaxBatchBytes = 1_048_576
+	defaultMaxBatchLen     = 500
+	defaultMaxBatchAge     = time.Second
+	defaultMaxBatchBytes   = 1_048_576
+	defaultMaxPendingBytes = 16 * defaultMaxBatchBytes
+	defaultMinRetryBackoff = 100 * time.Millisecond
+	defaultMaxRetryBackoff = 10 * time.Second
 )
 
 var perLogOverhead = func() int {
@@ -72,6 +144,32 @@ var perLogOverhead = func() int {
 	return len(b)
 }()
 
+var emptyTurnLogSize = func() int {
+	b, _ := json.Marshal(TurnLog{})
+	return len(b)
+}()
+
+// turnLogExtrasSize returns the encoded size of the optional fields of a log entry (level, stream, source and
+// attributes). It fails if the attributes can't be encoded.
+func turnLogExtrasSize(entry TurnLog) (int, error) {
+	if entry.Level == "" && entry.Stream == "" && entry.Source == "" && len(entry.Attributes) == 0 {
+		return 0, nil
+	}
+	b, err := json.Marshal(
+		TurnLog{Level: entry.Level, Stream: entry.Stream, Source: entry.Source, Attributes: entry.Attributes},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return len(b) - emptyTurnLogSize, nil
+}
+
+// turnLogSize estimates the encoded size of a log entry in an upload request.
+func turnLogSize(entry TurnLog) int {
+	extras, _ := turnLogExtrasSize(entry)
+	return len(entry.Message) + perLogOverhead + extras
+}
+
 // NewLogUploader creates and starts a LogUploader.
 func NewLogUploader(cfg *LogUploaderConfig) *LogUploader {
 	if cfg == nil {
@@ -86,30 +184,49 @@ func NewLogUploader(cfg *LogUploaderConfig) *LogUploader {
 	if cfg.MaxBatchBytes == 0 {
 		cfg.MaxBatchBytes = defaultMaxBatchBytes
 	}
+	if cfg.MaxPendingBytes == 0 {
+		cfg.MaxPendingBytes = defaultMaxPendingBytes
+	}
+	if cfg.MinRetryBackoff == 0 {
+		cfg.MinRetryBackoff = defaultMinRetryBackoff
+	}
+	if cfg.MaxRetryBackoff == 0 {
+		cfg.MaxRetryBackoff = defaultMaxRetryBackoff
+	}
 
 	lu := &LogUploader{
-		cg:            concurrency.NewContextGroup(),
-		client:        cfg.Client,
-		tenantID:      cfg.TenantID,
-		taskID:        cfg.TaskID,
-		turnIndex:     cfg.TurnIndex,
-		version:       cfg.Version,
-		index:         cfg.StartIndex,
-		logs:          cfg.Logs,
-		featureFlags:  cfg.FeatureFlags,
-		maxBatchLen:   cfg.MaxBatchLen,
-		maxBatchAge:   cfg.MaxBatchAge,
-		maxBatchBytes: cfg.MaxBatchBytes,
+		cg:              concurrency.NewContextGroup(),
+		client:          cfg.Client,
+		tenantID:        cfg.TenantID,
+		taskID:          cfg.TaskID,
+		turnIndex:       cfg.TurnIndex,
+		version:         cfg.Version,
+		nextIndex:       cfg.StartIndex,
+		resume:          cfg.Resume,
+		logs:            cfg.Logs,
+		featureFlags:    cfg.FeatureFlags,
+		redactor:        cfg.Redactor,
+		maxBatchLen:     cfg.MaxBatchLen,
+		maxBatchAge:     cfg.MaxBatchAge,
+		maxBatchBytes:   cfg.MaxBatchBytes,
+		maxPendingBytes: cfg.MaxPendingBytes,
+		spillPath:       cfg.SpillPath,
+		backoff:         util.NewBackoff(cfg.MinRetryBackoff, cfg.MaxRetryBackoff),
+		onError:         cfg.OnError,
 	}
+	lu.resumeClient, _ = cfg.Client.(LogUploaderResumeClient)
 	lu.timer = time.NewTimer(cfg.MaxBatchAge)
 	lu.timer.Stop()
+	lu.retryTimer = time.NewTimer(cfg.MinRetryBackoff)
+	lu.retryTimer.Stop()
 
 	lu.cg.Add(1)
 	go lu.run()
 	return lu
 }
 
-// Close cancels the uploader and waits for shutdown.
+// Close cancels the uploader and waits for shutdown. Logs that have not been uploaded are written to the spill file,
+// if one is configured.
 func (l *LogUploader) Close() error { return l.cg.Close() }
 
 // ShutdownContext waits for shutdown with a context.
@@ -118,78 +235,458 @@ func (l *LogUploader) ShutdownContext(ctx context.Context) error { return l.cg.W
 // ShutdownTimeout waits for shutdown with a timeout.
 func (l *LogUploader) ShutdownTimeout(d time.Duration) error { return l.cg.WaitTimeout(d) }
 
+// Done returns a channel that is closed when the uploader stops, whether because it finished, was closed, or failed.
+// Nothing reads Logs after that, so senders should stop sending once it is closed.
+func (l *LogUploader) Done() <-chan struct{} { return l.cg.Context().Done() }
+
+// Err returns the error that stopped the uploader, or nil if it has not stopped because of an error.
+func (l *LogUploader) Err() error {
+	l.errMu.Lock()
+	defer l.errMu.Unlock()
+	return l.err
+}
+
 func (l *LogUploader) run() {
 	defer l.cg.Done()
 	defer l.cg.Cancel()
+	defer l.shutdown()
 
+	if !l.start() {
+		return
+	}
+
+	logs := l.logs
 	for {
+		if logs == nil && len(l.pending) == 0 && l.spillLen() == 0 {
+			l.drained = true
+			return
+		}
+
+		in := logs
+		if l.spill == nil && l.pendingBytes >= l.maxPendingBytes {
+			// Apply backpressure until uploads catch up.
+			in = nil
+		}
+
 		select {
 		case <-l.cg.Context().Done():
-			l.flush()
 			return
 		case <-l.timer.C:
-			if len(l.batch) > 0 {
-				l.flush()
+			l.flush(true)
+		case <-l.retryTimer.C:
+			l.retrying = false
+			if err := l.reconcile(); err != nil {
+				l.handleError(err)
+				continue
 			}
-		case logEntry, ok := <-l.logs:
+			l.flush(true)
+		case logEntry, ok := <-in:
 			if !ok {
-				l.flush()
-				return
-			}
-			msgLen := len(logEntry.Message)
-			if msgLen > l.maxBatchBytes-perLogOverhead {
-				logEntry.Message = logEntry.Message[:l.maxBatchBytes-perLogOverhead]
-				msgLen = len(logEntry.Message)
+				logs = nil
+				l.flush(true)
+				continue
 			}
-			entrySize := msgLen + perLogOverhead
-			if len(l.batch)+1 > l.maxBatchLen || l.batchBytes+entrySize > l.maxBatchBytes {
-				l.flush()
+			l.enqueue(logEntry)
+			l.flush(false)
+		}
+	}
+}
+
+// start prepares the uploader before any logs are read: it loads logs left in the spill file by a previous uploader,
+// and, if resuming, finds the index to start from. It returns false if the uploader should stop.
+func (l *LogUploader) start() bool {
+	var existing []spilledLog
+	if l.spillPath != "" {
+		var err error
+		l.spill, existing, err = openLogSpill(l.spillPath)
+		if err != nil {
+			slog.ErrorContext(l.cg.Context(), "LogUploader: unable to open spill file", "path", l.spillPath, "error", err)
+			l.report(err)
+		}
+	}
+
+	if l.resume && l.resumeClient == nil {
+		slog.WarnContext(l.cg.Context(), "LogUploader: client can't get the last turn log, not resuming")
+	}
+	if (l.resume || len(existing) > 0) && l.resumeClient != nil {
+		for {
+			last, found, err := l.lastUploadedIndex()
+			if err == nil {
+				if found && last+1 > l.nextIndex {
+					l.nextIndex = last + 1
+				}
+				break
 			}
-			if len(l.batch) == 0 {
-				l.setTimer()
+			if !isRetryableError(err) {
+				slog.ErrorContext(l.cg.Context(), "LogUploader: unable to get last turn log", "error", err)
+				l.fail(err)
+				return false
 			}
-			l.batch = append(l.batch, logEntry)
-			l.batchBytes += entrySize
-			if len(l.batch) >= l.maxBatchLen || l.batchBytes >= l.maxBatchBytes {
-				l.flush()
+			slog.WarnContext(l.cg.Context(), "LogUploader: unable to get last turn log, retrying", "error", err)
+			l.report(err)
+			l.backoff.Backoff()
+			if l.backoff.Wait(l.cg.Context()) != nil {
+				return false
 			}
 		}
 	}
+
+	if len(existing) == 0 {
+		return true
+	}
+
+	var keep []spilledLog
+	for _, e := range existing {
+		if e.Index < l.nextIndex {
+			continue
+		}
+		if len(keep) == 0 && e.Index != l.nextIndex {
+			slog.WarnContext(
+				l.cg.Context(),
+				"LogUploader: gap between uploaded and spilled logs",
+				"expected", l.nextIndex,
+				"found", e.Index,
+			)
+		}
+		e.Index = l.nextIndex
+		l.nextIndex++
+		keep = append(keep, e)
+	}
+
+	err := l.spill.reset()
+	if err == nil {
+		err = l.spill.append(keep...)
+	}
+	if err != nil {
+		slog.ErrorContext(l.cg.Context(), "LogUploader: unable to rewrite spill file", "error", err)
+		l.fail(err)
+		return false
+	}
+	l.refill()
+	if len(l.pending) > 0 {
+		l.setTimer()
+	}
+	return true
+}
+
+// enqueue assigns the next index to a log and adds it to the pending queue, spilling it to disk if the in-memory
+// queue is full.
+func (l *LogUploader) enqueue(logEntry TurnLog) {
+	if l.redactor != nil {
+		logEntry = l.redactor.RedactTurnLog(logEntry)
+	}
+	// Attributes that can't be encoded would fail every upload of the batch, and attributes that take up most of a
+	// batch leave no room for the message, so both are dropped.
+	extras, err := turnLogExtrasSize(logEntry)
+	if err != nil || extras > l.maxBatchBytes/2 {
+		slog.WarnContext(l.cg.Context(), "LogUploader: dropping log attributes", "size", extras, "error", err)
+		logEntry.Attributes = nil
+		extras, _ = turnLogExtrasSize(logEntry)
+	}
+	if maxMsgLen := l.maxBatchBytes - perLogOverhead - extras; len(logEntry.Message) > maxMsgLen {
+		logEntry.Message = logEntry.Message[:maxMsgLen]
+	}
+	entry := pendingLog{index: l.nextIndex, log: logEntry, size: turnLogSize(logEntry)}
+	l.nextIndex++
+
+	// Once anything has been spilled, later logs must be spilled too, so that they are uploaded in index order.
+	if l.spill != nil && (l.spill.len() > 0 || l.pendingBytes+entry.size > l.maxPendingBytes) {
+		err := l.spill.append(spilledLog{Index: entry.index, Log: entry.log})
+		if err == nil {
+			return
+		}
+		slog.ErrorContext(l.cg.Context(), "LogUploader: unable to spill log", "error", err)
+		l.report(err)
+	}
+
+	if len(l.pending) == 0 && !l.retrying {
+		l.setTimer()
+	}
+	l.pending = append(l.pending, entry)
+	l.pendingBytes += entry.size
 }
 
-func (l *LogUploader) flush() {
-	if len(l.batch) == 0 {
-		return
+// refill moves spilled logs back into memory while there is room.
+func (l *LogUploader) refill() {
+	for l.spillLen() > 0 && l.pendingBytes < l.maxPendingBytes {
+		entries, err := l.spill.read(l.maxBatchLen, l.maxPendingBytes-l.pendingBytes)
+		for _, e := range entries {
+			size := turnLogSize(e.Log)
+			l.pending = append(l.pending, pendingLog{index: e.Index, log: e.Log, size: size})
+			l.pendingBytes += size
+		}
+		if err != nil {
+			slog.ErrorContext(l.cg.Context(), "LogUploader: unable to read spill file", "error", err)
+			l.fail(err)
+			return
+		}
 	}
+}
+
+func (l *LogUploader) spillLen() int {
+	if l.spill == nil {
+		return 0
+	}
+	return l.spill.len()
+}
+
+// flush uploads full batches, or any batch if the in-memory queue is full. If force is set, it uploads every pending
+// log, including a final partial batch.
+func (l *LogUploader) flush(force bool) {
+	uploaded := false
+	for len(l.pending) > 0 && !l.retrying && l.cg.Context().Err() == nil {
+		full := len(l.pending) >= l.maxBatchLen || l.pendingBytes >= l.maxBatchBytes ||
+			l.pendingBytes >= l.maxPendingBytes
+		if !force && !full {
+			break
+		}
+		if !l.upload() {
+			return
+		}
+		uploaded = true
+	}
+	switch {
+	case len(l.pending) == 0:
+		l.timer.Stop()
+	case uploaded:
+		l.setTimer()
+	}
+}
+
+// upload uploads the batch at the head of the pending queue. It returns false if the upload failed.
+func (l *LogUploader) upload() bool {
+	n, batchBytes := 0, 0
+	for n < len(l.pending) && n < l.maxBatchLen {
+		if n > 0 && batchBytes+l.pending[n].size > l.maxBatchBytes {
+			break
+		}
+		batchBytes += l.pending[n].size
+		n++
+	}
+	batch := make([]TurnLog, n)
+	for i := range batch {
+		batch[i] = l.pending[i].log
+	}
+
 	resp, err := l.client.UploadTurnLogs(
 		l.cg.Context(), &UploadTurnLogsRequest{
 			TenantID:     l.tenantID,
 			TaskID:       l.taskID,
 			TurnIndex:    l.turnIndex,
 			Version:      l.version,
-			Index:        l.index,
-			Logs:         l.batch,
+			Index:        l.pending[0].index,
+			Logs:         batch,
+			FeatureFlags: FeatureFlags{FeatureFlags: l.featureFlags},
+		},
+	)
+	if err != nil {
+		l.handleError(err)
+		return false
+	}
+
+	l.backoff.Recover()
+	l.version = resp.Version
+	l.pending = append(l.pending[:0], l.pending[n:]...)
+	l.pendingBytes -= batchBytes
+	l.refill()
+	l.resetSpillIfDone()
+	return true
+}
+
+// handleError schedules a retry for transient errors and conflicts, and stops the uploader otherwise.
+func (l *LogUploader) handleError(err error) {
+	if l.cg.Context().Err() != nil {
+		return
+	}
+	var conflictErr *ConflictError
+	switch {
+	case errors.As(err, &conflictErr):
+		l.handleConflict(conflictErr)
+	case isRetryableError(err):
+		delay := l.scheduleRetry()
+		slog.WarnContext(l.cg.Context(), "LogUploader: upload error, retrying", "error", err, "backoff", delay)
+		l.report(err)
+	default:
+		slog.ErrorContext(l.cg.Context(), "LogUploader: upload error", "error", err)
+		l.fail(err)
+	}
+}
+
+// handleConflict recovers from a version conflict. Conflicts usually mean the turn was updated concurrently (e.g. by
+// UpdateTurn), so the uploader adopts the current version and retries. The retry reconciles with GetLastTurnLog
+// first, in case the conflicting update was an upload of our own logs. The uploader only gives up once the turn has
+// completed.
+func (l *LogUploader) handleConflict(conflictErr *ConflictError) {
+	turn, ok := conflictErr.Current.(*Turn)
+	switch {
+	case !ok || turn == nil:
+		slog.ErrorContext(l.cg.Context(), "LogUploader: conflict", "error", conflictErr)
+		l.fail(conflictErr)
+	case turn.CompletedAt != nil || TurnLifecycle.IsTerminal(turn.Status):
+		slog.ErrorContext(l.cg.Context(), "LogUploader: turn completed", "error", conflictErr)
+		l.fail(fmt.Errorf("%w: %w", ErrTurnCompleted, conflictErr))
+	default:
+		slog.WarnContext(
+			l.cg.Context(),
+			"LogUploader: conflict, retrying",
+			"error", conflictErr,
+			"version", l.version,
+			"currentVersion", turn.Version,
+		)
+		l.report(conflictErr)
+		l.version = turn.Version
+		l.scheduleRetry()
+	}
+}
+
+// scheduleRetry backs off, and returns the delay before the retry.
+func (l *LogUploader) scheduleRetry() time.Duration {
+	l.backoff.Backoff()
+	l.retrying = true
+	l.timer.Stop()
+	delay := l.backoff.Jitter()
+	l.retryTimer.Reset(delay)
+	return delay
+}
+
+// report passes err to the OnError callback.
+func (l *LogUploader) report(err error) {
+	if l.onError != nil {
+		l.onError(err)
+	}
+}
+
+// fail reports err, records it as the error that stopped the uploader, and stops the uploader.
+func (l *LogUploader) fail(err error) {
+	l.report(err)
+	l.errMu.Lock()
+	if l.err == nil {
+		l.err = err
+	}
+	l.errMu.Unlock()
+	l.cg.Cancel()
+}
+
+// reconcile drops pending logs that the server already has. A request that failed on the client side (e.g. with a
+// timeout) may still have been stored, and uploading it again would duplicate its indexes.
+func (l *LogUploader) reconcile() error {
+	last, found, err := l.lastUploadedIndex()
+	if err != nil || !found {
+		return err
+	}
+	for {
+		dropped := 0
+		for dropped < len(l.pending) && l.pending[dropped].index <= last {
+			l.pendingBytes -= l.pending[dropped].size
+			dropped++
+		}
+		l.pending = append(l.pending[:0], l.pending[dropped:]...)
+		if len(l.pending) > 0 || l.spillLen() == 0 {
+			break
+		}
+		l.refill()
+	}
+	l.resetSpillIfDone()
+	return nil
+}
+
+// lastUploadedIndex returns the index of the last log the server has for the turn. found is false if the turn has no
+// logs, or if the client can't tell.
+func (l *LogUploader) lastUploadedIndex() (index int, found bool, err error) {
+	if l.resumeClient == nil {
+		return 0, false, nil
+	}
+	last, err := l.resumeClient.GetLastTurnLog(
+		l.cg.Context(), &GetLastTurnLogRequest{
+			TenantID:     l.tenantID,
+			TaskID:       l.taskID,
+			TurnIndex:    l.turnIndex,
 			FeatureFlags: FeatureFlags{FeatureFlags: l.featureFlags},
 		},
 	)
+	if isNotFound(err) {
+		return 0, false, nil
+	}
 	if err != nil {
-		var conflictErr *ConflictError
-		if errors.As(err, &conflictErr) {
-			slog.ErrorContext(l.cg.Context(), "LogUploader: conflict", "error", err)
-			l.cg.Cancel()
-		} else {
-			slog.ErrorContext(l.cg.Context(), "LogUploader: upload error", "error", err)
-		}
-	} else {
-		l.index += len(l.batch)
-		l.version = resp.Version
-	}
-	l.batch = nil
-	l.batchBytes = 0
+		return 0, false, err
+	}
+	return last.Index, true, nil
+}
+
+// resetSpillIfDone truncates the spill file once every log in it has been uploaded.
+func (l *LogUploader) resetSpillIfDone() {
+	if l.spill == nil || len(l.pending) > 0 || l.spill.len() > 0 {
+		return
+	}
+	if err := l.spill.reset(); err != nil {
+		slog.ErrorContext(l.cg.Context(), "LogUploader: unable to reset spill file", "error", err)
+	}
+}
+
+// shutdown persists logs that were not uploaded, so that a later uploader can finish the job.
+func (l *LogUploader) shutdown() {
 	l.timer.Stop()
+	l.retryTimer.Stop()
+	ctx := context.WithoutCancel(l.cg.Context())
+
+	if l.spill == nil {
+		if len(l.pending) > 0 {
+			slog.ErrorContext(ctx, "LogUploader: dropping logs that were not uploaded", "count", len(l.pending))
+		}
+		return
+	}
+
+	if l.drained {
+		if err := l.spill.close(true); err != nil {
+			slog.ErrorContext(ctx, "LogUploader: unable to remove spill file", "error", err)
+		}
+		return
+	}
+
+	// Logs that were read back from the spill file are still in it. Writing them again is harmless, since
+	// openLogSpill drops duplicates.
+	entries := make([]spilledLog, len(l.pending))
+	for i, p := range l.pending {
+		entries[i] = spilledLog{Index: p.index, Log: p.log}
+	}
+	err := l.spill.append(entries...)
+	err = errors.Join(err, l.spill.close(false))
+	if err != nil {
+		slog.ErrorContext(ctx, "LogUploader: unable to persist pending logs", "error", err)
+	}
 }
 
 func (l *LogUploader) setTimer() {
 	l.timer.Stop()
 	l.timer.Reset(l.maxBatchAge)
 }
+
+// isRetryableError reports whether err is transient: a network error, a timeout, throttling, or a server error.
+// Other API errors (e.g. 401, 403 or 404) are permanent, as are errors that don't come from the network, such as
+// invalid requests or responses that can't be decoded.
+func isRetryableError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var conflictErr *ConflictError
+	if errors.As(err, &conflictErr) {
+		return false
+	}
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.ResponseCode == http.StatusRequestTimeout ||
+			apiErr.ResponseCode == http.StatusTooManyRequests ||
+			apiErr.ResponseCode >= http.StatusInternalServerError
+	}
+	// Errors returned by http.Client are *url.Error, which is a net.Error.
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+func isNotFound(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.ResponseCode == http.StatusNotFound
+}
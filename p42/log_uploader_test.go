@@ -2,8 +2,14 @@ package p42_test
 
 import (
 	"context"
+	"errors"
+	"net"
+	"net/http"
+	"path/filepath"
 	"reflect"
+	"strconv"
 	"sync"
+	"syscall"
 	"testing"
 	"time"
 
@@ -146,3 +152,298 @@ func TestLogUploaderBatchSize(t *testing.T) {
 		t.Fatalf("expected 3 reqs, got %d", len(fake.reqs))
 	}
 }
+
+type fakeUploadFailure struct {
+	err error
+	// stored indicates the server stored the logs before failing the request.
+	stored bool
+}
+
+// fakeLogServer is an upload client that stores logs the way the service does: uploads must start at the index after
+// the last stored log.
+type fakeLogServer struct {
+	mu       sync.Mutex
+	stored   []p42.TurnLog
+	failures []fakeUploadFailure
+	calls    int
+
+	// If version is set, uploads must use the current turn version.
+	version     int
+	completedAt *time.Time
+}
+
+func (f *fakeLogServer) UploadTurnLogs(
+	_ context.Context,
+	req *p42.UploadTurnLogsRequest,
+) (*p42.UploadTurnLogsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+
+	var failure *fakeUploadFailure
+	if len(f.failures) > 0 {
+		failure = &f.failures[0]
+		f.failures = f.failures[1:]
+		if !failure.stored {
+			return nil, failure.err
+		}
+	}
+	if f.version != 0 && (req.Version != f.version || f.completedAt != nil) {
+		return nil, &p42.ConflictError{
+			ResponseCode: http.StatusConflict,
+			Message:      "version mismatch",
+			Current:      &p42.Turn{Version: f.version, CompletedAt: f.completedAt},
+		}
+	}
+	if req.Index != len(f.stored) {
+		return nil, &p42.Error{ResponseCode: http.StatusBadRequest, Message: "unexpected index"}
+	}
+	f.stored = append(f.stored, req.Logs...)
+	if f.version != 0 {
+		f.version++
+	}
+	if failure != nil {
+		return nil, failure.err
+	}
+	return &p42.UploadTurnLogsResponse{Version: req.Version + 1}, nil
+}
+
+func (f *fakeLogServer) GetLastTurnLog(_ context.Context, _ *p42.GetLastTurnLogRequest) (*p42.LastTurnLog, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.stored) == 0 {
+		return nil, &p42.Error{ResponseCode: http.StatusNotFound, Message: "not found"}
+	}
+	last := f.stored[len(f.stored)-1]
+	return &p42.LastTurnLog{Index: len(f.stored) - 1, Timestamp: last.Timestamp, Message: last.Message}, nil
+}
+
+func (f *fakeLogServer) messages() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var ret []string
+	for _, l := range f.stored {
+		ret = append(ret, l.Message)
+	}
+	return ret
+}
+
+func newTestUploader(client p42.LogUploaderClient, logs <-chan p42.TurnLog) *p42.LogUploaderConfig {
+	return &p42.LogUploaderConfig{
+		Client:          client,
+		TenantID:        "t",
+		TaskID:          "task",
+		Version:         1,
+		Logs:            logs,
+		MaxBatchLen:     2,
+		MinRetryBackoff: time.Millisecond,
+		MaxRetryBackoff: 5 * time.Millisecond,
+	}
+}
+
+func sendLogs(logs chan<- p42.TurnLog, messages ...string) {
+	for _, m := range messages {
+		logs <- p42.TurnLog{Message: m}
+	}
+}
+
+func TestLogUploaderRetriesTransientErrors(t *testing.T) {
+	unavailable := &p42.Error{ResponseCode: http.StatusServiceUnavailable, Message: "unavailable"}
+	server := &fakeLogServer{
+		failures: []fakeUploadFailure{
+			{err: unavailable},
+			{err: unavailable},
+			{err: &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}},
+		},
+	}
+	logs := make(chan p42.TurnLog)
+	lu := p42.NewLogUploader(newTestUploader(server, logs))
+
+	sendLogs(logs, "a", "b", "c", "d", "e")
+	close(logs)
+	require.NoError(t, lu.ShutdownTimeout(2*time.Second))
+	require.Equal(t, []string{"a", "b", "c", "d", "e"}, server.messages())
+}
+
+func TestLogUploaderReconcilesStoredBatch(t *testing.T) {
+	server := &fakeLogServer{
+		failures: []fakeUploadFailure{
+			{err: &p42.Error{ResponseCode: http.StatusGatewayTimeout, Message: "timeout"}, stored: true},
+		},
+	}
+	logs := make(chan p42.TurnLog)
+	lu := p42.NewLogUploader(newTestUploader(server, logs))
+
+	sendLogs(logs, "a", "b", "c")
+	close(logs)
+	require.NoError(t, lu.ShutdownTimeout(2*time.Second))
+	require.Equal(t, []string{"a", "b", "c"}, server.messages())
+}
+
+func TestLogUploaderStopsOnPermanentError(t *testing.T) {
+	server := &fakeLogServer{
+		failures: []fakeUploadFailure{
+			{err: &p42.Error{ResponseCode: http.StatusForbidden, Message: "forbidden"}},
+		},
+	}
+	logs := make(chan p42.TurnLog, 10)
+	lu := p42.NewLogUploader(newTestUploader(server, logs))
+
+	sendLogs(logs, "a", "b")
+	require.NoError(t, lu.ShutdownTimeout(2*time.Second))
+	require.Empty(t, server.messages())
+	require.Equal(t, 1, server.calls)
+	require.Error(t, lu.Err())
+}
+
+func TestLogUploaderStopsOnLocalError(t *testing.T) {
+	// Errors that don't come from the network, such as invalid requests, are not retried.
+	server := &fakeLogServer{failures: []fakeUploadFailure{{err: errors.New("tenant id is required")}}}
+	logs := make(chan p42.TurnLog, 10)
+	lu := p42.NewLogUploader(newTestUploader(server, logs))
+
+	sendLogs(logs, "a", "b")
+	require.NoError(t, lu.ShutdownTimeout(2*time.Second))
+	require.Equal(t, 1, server.calls)
+	require.EqualError(t, lu.Err(), "tenant id is required")
+}
+
+func TestLogUploaderResume(t *testing.T) {
+	server := &fakeLogServer{stored: []p42.TurnLog{{Message: "0"}, {Message: "1"}}}
+	logs := make(chan p42.TurnLog)
+	cfg := newTestUploader(server, logs)
+	cfg.Resume = true
+	lu := p42.NewLogUploader(cfg)
+
+	sendLogs(logs, "2", "3", "4")
+	close(logs)
+	require.NoError(t, lu.ShutdownTimeout(2*time.Second))
+	require.Equal(t, []string{"0", "1", "2", "3", "4"}, server.messages())
+}
+
+func TestLogUploaderResumeWithoutResumeClient(t *testing.T) {
+	// fakeUploadClient can't report the last turn log, so uploads start at StartIndex.
+	fake := &fakeUploadClient{}
+	logs := make(chan p42.TurnLog)
+	cfg := newTestUploader(fake, logs)
+	cfg.Resume = true
+	cfg.StartIndex = 7
+	lu := p42.NewLogUploader(cfg)
+
+	sendLogs(logs, "a", "b")
+	close(logs)
+	require.NoError(t, lu.ShutdownTimeout(2*time.Second))
+	require.NoError(t, lu.Err())
+	require.Len(t, fake.reqs, 1)
+	require.Equal(t, 7, fake.reqs[0].Index)
+}
+
+func TestLogUploaderSpillAndResume(t *testing.T) {
+	spillPath := filepath.Join(t.TempDir(), "logs.spill")
+	unavailable := &p42.Error{ResponseCode: http.StatusServiceUnavailable, Message: "unavailable"}
+
+	// The first uploader stores one batch, then the service goes down. Logs beyond MaxPendingBytes are spilled, and
+	// whatever is left in memory is persisted on Close.
+	down := &fakeLogServer{}
+	for range 1000 {
+		down.failures = append(down.failures, fakeUploadFailure{err: unavailable})
+	}
+	down.failures[0] = fakeUploadFailure{}
+	logs := make(chan p42.TurnLog)
+	cfg := newTestUploader(down, logs)
+	cfg.SpillPath = spillPath
+	cfg.MaxPendingBytes = 1
+	lu := p42.NewLogUploader(cfg)
+
+	var expected []string
+	for i := range 10 {
+		expected = append(expected, strconv.Itoa(i))
+	}
+	sendLogs(logs, expected...)
+	require.NoError(t, lu.Close())
+	require.FileExists(t, spillPath)
+
+	// A second uploader picks up where the first left off, uploading each index exactly once.
+	up := &fakeLogServer{stored: down.stored}
+	logs = make(chan p42.TurnLog)
+	cfg = newTestUploader(up, logs)
+	cfg.SpillPath = spillPath
+	cfg.Resume = true
+	lu = p42.NewLogUploader(cfg)
+
+	sendLogs(logs, "10", "11")
+	close(logs)
+	require.NoError(t, lu.ShutdownTimeout(2*time.Second))
+	require.Equal(t, append(expected, "10", "11"), up.messages())
+	require.NoFileExists(t, spillPath)
+}
+
+func TestLogUploaderBackpressureWithoutSpill(t *testing.T) {
+	server := &fakeLogServer{}
+	logs := make(chan p42.TurnLog)
+	cfg := newTestUploader(server, logs)
+	cfg.MaxPendingBytes = 1
+	lu := p42.NewLogUploader(cfg)
+
+	sendLogs(logs, "a", "b", "c", "d", "e")
+	close(logs)
+	require.NoError(t, lu.ShutdownTimeout(2*time.Second))
+	require.Equal(t, []string{"a", "b", "c", "d", "e"}, server.messages())
+}
+
+func TestLogUploaderRecoversFromConflict(t *testing.T) {
+	// The turn was updated concurrently, so its version is ahead of the uploader's.
+	server := &fakeLogServer{version: 5}
+	var conflicts int
+	logs := make(chan p42.TurnLog)
+	cfg := newTestUploader(server, logs)
+	cfg.OnError = func(err error) {
+		var conflictErr *p42.ConflictError
+		if errors.As(err, &conflictErr) {
+			conflicts++
+		}
+	}
+	lu := p42.NewLogUploader(cfg)
+
+	sendLogs(logs, "a", "b", "c")
+	close(logs)
+	require.NoError(t, lu.ShutdownTimeout(2*time.Second))
+	require.NoError(t, lu.Err())
+	require.Equal(t, []string{"a", "b", "c"}, server.messages())
+	require.Equal(t, 1, conflicts)
+}
+
+func TestLogUploaderConflictAfterStoredBatch(t *testing.T) {
+	// The first upload is stored but the response is lost, so the uploader's version is stale and the batch must not
+	// be uploaded again.
+	server := &fakeLogServer{
+		version: 1,
+		failures: []fakeUploadFailure{
+			{err: &p42.Error{ResponseCode: http.StatusGatewayTimeout, Message: "timeout"}, stored: true},
+		},
+	}
+	logs := make(chan p42.TurnLog)
+	lu := p42.NewLogUploader(newTestUploader(server, logs))
+
+	sendLogs(logs, "a", "b", "c", "d")
+	close(logs)
+	require.NoError(t, lu.ShutdownTimeout(2*time.Second))
+	require.NoError(t, lu.Err())
+	require.Equal(t, []string{"a", "b", "c", "d"}, server.messages())
+}
+
+func TestLogUploaderStopsWhenTurnCompleted(t *testing.T) {
+	completedAt := time.Now()
+	server := &fakeLogServer{version: 5, completedAt: &completedAt}
+	var reported []error
+	logs := make(chan p42.TurnLog, 10)
+	cfg := newTestUploader(server, logs)
+	cfg.OnError = func(err error) { reported = append(reported, err) }
+	lu := p42.NewLogUploader(cfg)
+
+	sendLogs(logs, "a", "b")
+	require.NoError(t, lu.ShutdownTimeout(2*time.Second))
+	require.ErrorIs(t, lu.Err(), p42.ErrTurnCompleted)
+	require.Len(t, reported, 1)
+	require.Empty(t, server.messages())
+}
@@ -0,0 +1,180 @@
+package p42
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/plan42-ai/clock"
+)
+
+const defaultMaxLineLen = 64 * 1024
+
+// ErrTurnLogsDone is returned by TurnLogWriter and TurnLogHandler once the consumer of their logs channel has stopped.
+var ErrTurnLogsDone = errors.New("turn log consumer has stopped")
+
+// TurnLogWriterConfig holds configuration for a TurnLogWriter.
+type TurnLogWriterConfig struct {
+	// Logs receives one entry per line written. It is typically the Logs channel of a LogUploader.
+	Logs chan<- TurnLog
+
+	// Done, if set, is closed when the consumer of Logs stops reading it, for example LogUploader.Done. Writes then
+	// fail with ErrTurnLogsDone, rather than blocking forever.
+	Done <-chan struct{}
+
+	// Clock is used to timestamp entries. Defaults to the real clock.
+	Clock clock.Clock
+
+	// Stream and Source, if set, are recorded on every entry.
+	Stream TurnLogStream
+	Source TurnLogSource
+
+	// MaxLineLen is the maximum length of a message, in bytes. Longer lines are split into several entries, without
+	// splitting UTF-8 encoded characters. Defaults to 64 KiB.
+	MaxLineLen int
+}
+
+// TurnLogWriter is an io.Writer that converts written output into TurnLog entries, one per line. It can be attached
+// directly to the Stdout or Stderr of an exec.Cmd.
+//
+// Each entry is timestamped with the time the first byte of its line was written. Trailing "\r" characters are
+// removed. A final line without a newline is held until more output arrives, or until Flush or Close is called.
+//
+// Write blocks until every complete line has been accepted by the Logs channel, or until Done is closed. TurnLogWriter
+// is safe for concurrent use.
+type TurnLogWriter struct {
+	mu         sync.Mutex
+	logs       chan<- TurnLog
+	done       <-chan struct{}
+	clk        clock.Clock
+	stream     TurnLogStream
+	source     TurnLogSource
+	maxLineLen int
+
+	buf       []byte
+	lineStart time.Time
+	closed    bool
+}
+
+// NewTurnLogWriter creates a TurnLogWriter.
+func NewTurnLogWriter(cfg *TurnLogWriterConfig) *TurnLogWriter {
+	if cfg == nil {
+		cfg = &TurnLogWriterConfig{}
+	}
+	w := &TurnLogWriter{
+		logs:       cfg.Logs,
+		done:       cfg.Done,
+		clk:        cfg.Clock,
+		stream:     cfg.Stream,
+		source:     cfg.Source,
+		maxLineLen: cfg.MaxLineLen,
+	}
+	if w.clk == nil {
+		w.clk = clock.NewRealClock()
+	}
+	if w.maxLineLen <= 0 {
+		w.maxLineLen = defaultMaxLineLen
+	}
+	return w
+}
+
+// Write splits p into lines and sends an entry for each complete line. It returns os.ErrClosed if the writer has been
+// closed, and ErrTurnLogsDone if the consumer of Logs has stopped.
+func (w *TurnLogWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+
+	now := w.clk.Now()
+	n := len(p)
+	for len(p) > 0 {
+		written := n - len(p)
+		if len(w.buf) == 0 {
+			w.lineStart = now
+		}
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			w.buf = append(w.buf, p...)
+			if err := w.emitLong(); err != nil {
+				return written, err
+			}
+			break
+		}
+		w.buf = append(w.buf, p[:i]...)
+		p = p[i+1:]
+		if err := w.emitLong(); err != nil {
+			return written, err
+		}
+		if err := w.emit(bytes.TrimRight(w.buf, "\r")); err != nil {
+			return written, err
+		}
+	}
+	return n, nil
+}
+
+// Flush sends any partial line that has been written. It returns ErrTurnLogsDone if the consumer of Logs has stopped.
+func (w *TurnLogWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) > 0 {
+		return w.emit(w.buf)
+	}
+	return nil
+}
+
+// Close flushes any partial line. Subsequent writes fail. Close does not close the Logs channel. It returns
+// ErrTurnLogsDone if the partial line could not be sent because the consumer of Logs has stopped.
+func (w *TurnLogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if len(w.buf) > 0 {
+		return w.emit(w.buf)
+	}
+	return nil
+}
+
+// emitLong sends entries for the buffered line while it is longer than maxLineLen.
+func (w *TurnLogWriter) emitLong() error {
+	for len(w.buf) > w.maxLineLen {
+		cut := w.maxLineLen
+		for cut > 0 && !utf8.RuneStart(w.buf[cut]) {
+			cut--
+		}
+		if cut == 0 {
+			// Not valid UTF-8; split at the limit.
+			cut = w.maxLineLen
+		}
+		if err := w.send(string(w.buf[:cut])); err != nil {
+			return err
+		}
+		w.buf = append(w.buf[:0], w.buf[cut:]...)
+	}
+	return nil
+}
+
+// emit sends line as an entry and resets the buffer. line may alias the buffer.
+func (w *TurnLogWriter) emit(line []byte) error {
+	err := w.send(string(line))
+	w.buf = w.buf[:0]
+	return err
+}
+
+// send sends an entry, unless the consumer of Logs stops first.
+func (w *TurnLogWriter) send(msg string) error {
+	select {
+	case w.logs <- TurnLog{Timestamp: w.lineStart, Message: msg, Stream: w.stream, Source: w.source}:
+		return nil
+	case <-w.done:
+		return ErrTurnLogsDone
+	}
+}
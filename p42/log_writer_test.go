@@ -0,0 +1,149 @@
+package p42_test
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/plan42-ai/clock"
+	"github.com/plan42-ai/sdk-go/p42"
+	"github.com/stretchr/testify/require"
+)
+
+func drainLogs(logs chan p42.TurnLog) []p42.TurnLog {
+	var ret []p42.TurnLog
+	for {
+		select {
+		case l := <-logs:
+			ret = append(ret, l)
+		default:
+			return ret
+		}
+	}
+}
+
+func messagesOf(logs []p42.TurnLog) []string {
+	ret := make([]string, 0, len(logs))
+	for _, l := range logs {
+		ret = append(ret, l.Message)
+	}
+	return ret
+}
+
+func TestTurnLogWriterSplitsLines(t *testing.T) {
+	t.Parallel()
+	logs := make(chan p42.TurnLog, 10)
+	clk := clock.NewFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	w := p42.NewTurnLogWriter(
+		&p42.TurnLogWriterConfig{Logs: logs, Clock: clk, Stream: p42.TurnLogStreamStderr},
+	)
+
+	_, err := fmt.Fprint(w, "one\r\ntwo\n\nthr")
+	require.NoError(t, err)
+	clk.Advance(time.Second)
+	_, err = fmt.Fprint(w, "ee\nfour")
+	require.NoError(t, err)
+
+	got := drainLogs(logs)
+	require.Equal(t, []string{"one", "two", "", "three"}, messagesOf(got))
+	// "three" is timestamped when its first byte was written.
+	require.Equal(t, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), got[3].Timestamp)
+	require.Equal(t, p42.TurnLogStreamStderr, got[3].Stream)
+
+	require.NoError(t, w.Close())
+	got = drainLogs(logs)
+	require.Equal(t, []string{"four"}, messagesOf(got))
+	require.Equal(t, time.Date(2025, 1, 1, 0, 0, 1, 0, time.UTC), got[0].Timestamp)
+
+	_, err = w.Write([]byte("late\n"))
+	require.ErrorIs(t, err, os.ErrClosed)
+}
+
+func TestTurnLogWriterFlush(t *testing.T) {
+	t.Parallel()
+	logs := make(chan p42.TurnLog, 10)
+	w := p42.NewTurnLogWriter(&p42.TurnLogWriterConfig{Logs: logs})
+
+	_, err := w.Write([]byte("prompt> "))
+	require.NoError(t, err)
+	require.Empty(t, drainLogs(logs))
+
+	w.Flush()
+	require.Equal(t, []string{"prompt> "}, messagesOf(drainLogs(logs)))
+
+	w.Flush()
+	require.Empty(t, drainLogs(logs))
+}
+
+func TestTurnLogWriterLongLinesKeepUTF8(t *testing.T) {
+	t.Parallel()
+	logs := make(chan p42.TurnLog, 100)
+	w := p42.NewTurnLogWriter(&p42.TurnLogWriterConfig{Logs: logs, MaxLineLen: 10})
+
+	// Each "é" is two bytes, so a 10 byte limit would split the sixth character if the split were not UTF-8 aware.
+	line := "aé" + strings.Repeat("é", 20)
+	// Write one byte at a time, so that characters also arrive split across writes.
+	for i := range len(line) {
+		_, err := w.Write([]byte{line[i]})
+		require.NoError(t, err)
+	}
+	_, err := w.Write([]byte("\n"))
+	require.NoError(t, err)
+
+	got := messagesOf(drainLogs(logs))
+	require.Equal(t, line, strings.Join(got, ""))
+	for _, msg := range got {
+		require.LessOrEqual(t, len(msg), 10)
+		require.True(t, utf8.ValidString(msg), "split inside a character: %q", msg)
+	}
+}
+
+func TestTurnLogWriterExecCmd(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	server := &fakeLogServer{}
+	logs := make(chan p42.TurnLog)
+	lu := p42.NewLogUploader(newTestUploader(server, logs))
+
+	stdout := p42.NewTurnLogWriter(&p42.TurnLogWriterConfig{Logs: logs, Stream: p42.TurnLogStreamStdout})
+	cmd := exec.Command("sh", "-c", "printf 'hello\\nworld'")
+	cmd.Stdout = stdout
+	require.NoError(t, cmd.Run())
+	require.NoError(t, stdout.Close())
+	close(logs)
+
+	require.NoError(t, lu.ShutdownTimeout(2*time.Second))
+	require.Equal(t, []string{"hello", "world"}, server.messages())
+}
+
+func TestTurnLogWriterStopsWhenUploaderStops(t *testing.T) {
+	t.Parallel()
+	server := &fakeLogServer{
+		failures: []fakeUploadFailure{{err: &p42.Error{ResponseCode: http.StatusForbidden, Message: "forbidden"}}},
+	}
+	logs := make(chan p42.TurnLog)
+	cfg := newTestUploader(server, logs)
+	cfg.MaxBatchLen = 1
+	lu := p42.NewLogUploader(cfg)
+	w := p42.NewTurnLogWriter(&p42.TurnLogWriterConfig{Logs: logs, Done: lu.Done()})
+
+	// The first line fails to upload, which stops the uploader. Writes then fail instead of blocking, and report
+	// only the bytes of the lines that were sent.
+	_, err := w.Write([]byte("a\n"))
+	require.NoError(t, err)
+	require.NoError(t, lu.ShutdownTimeout(2*time.Second))
+	n, err := w.Write([]byte("b\nc\n"))
+	require.ErrorIs(t, err, p42.ErrTurnLogsDone)
+	require.Equal(t, 0, n)
+	require.Error(t, lu.Err())
+
+	_, err = w.Write([]byte("partial"))
+	require.NoError(t, err)
+	require.ErrorIs(t, w.Close(), p42.ErrTurnLogsDone)
+}
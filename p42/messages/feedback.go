@@ -0,0 +1,379 @@
+package messages
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/plan42-ai/sdk-go/p42"
+)
+
+// DefaultGithubGraphQLURL is the URL of the GitHub GraphQL API.
+const DefaultGithubGraphQLURL = "https://api.github.com/graphql"
+
+// GithubTokenProvider supplies the token used to call GitHub.
+type GithubTokenProvider = p42.GithubTokenProvider
+
+// FeedbackCollectorConfig holds configuration for FeedbackCollector.
+type FeedbackCollectorConfig struct {
+	// HTTPClient calls the GitHub GraphQL API. Defaults to http.DefaultClient. To authenticate as a GitHub App
+	// installation, use a client whose transport is p42.GithubApp.Transport and leave Tokens nil.
+	HTTPClient *http.Client
+
+	// Tokens, if set, supplies a bearer token for each request.
+	Tokens GithubTokenProvider
+
+	// GraphQLURL defaults to DefaultGithubGraphQLURL. Set it for GitHub Enterprise Server.
+	GraphQLURL string
+
+	// IncludeResolved includes threads that were marked as resolved.
+	IncludeResolved bool
+
+	// IncludeMinimized includes threads whose first comment was minimized (hidden), and minimized replies in other
+	// threads.
+	IncludeMinimized bool
+
+	// PageSize is the number of review threads requested per page. Defaults to 50, the maximum is 100.
+	PageSize int
+}
+
+// FeedbackCollector builds the FeedBack of an InvokeAgentRequest from the review threads of a task's pull requests.
+type FeedbackCollector struct {
+	cfg FeedbackCollectorConfig
+}
+
+const (
+	defaultFeedbackPageSize = 50
+	maxFeedbackPageSize     = 100
+)
+
+// NewFeedbackCollector creates a FeedbackCollector.
+func NewFeedbackCollector(cfg *FeedbackCollectorConfig) *FeedbackCollector {
+	if cfg == nil {
+		cfg = &FeedbackCollectorConfig{}
+	}
+	c := &FeedbackCollector{cfg: *cfg}
+	if c.cfg.HTTPClient == nil {
+		c.cfg.HTTPClient = http.DefaultClient
+	}
+	if c.cfg.GraphQLURL == "" {
+		c.cfg.GraphQLURL = DefaultGithubGraphQLURL
+	}
+	if c.cfg.PageSize <= 0 {
+		c.cfg.PageSize = defaultFeedbackPageSize
+	}
+	c.cfg.PageSize = min(c.cfg.PageSize, maxFeedbackPageSize)
+	return c
+}
+
+// Collect returns the review feedback for each repo in task.RepoInfo that has a pull request, keyed like RepoInfo.
+// Repos without a PRNumber are skipped. Threads are in the order GitHub returns them.
+func (c *FeedbackCollector) Collect(ctx context.Context, task *p42.Task) (map[string][]PRFeedback, error) {
+	if task == nil {
+		return nil, fmt.Errorf("task is nil")
+	}
+	repos := make([]string, 0, len(task.RepoInfo))
+	for repo, info := range task.RepoInfo {
+		if info != nil && info.PRNumber != nil {
+			repos = append(repos, repo)
+		}
+	}
+	sort.Strings(repos)
+
+	ret := make(map[string][]PRFeedback, len(repos))
+	for _, repo := range repos {
+		feedback, err := c.CollectPR(ctx, repo, *task.RepoInfo[repo].PRNumber)
+		if err != nil {
+			return nil, fmt.Errorf("unable to collect feedback for %s: %w", repo, err)
+		}
+		ret[repo] = feedback
+	}
+	return ret, nil
+}
+
+// CollectPR returns the review feedback for a single pull request. repo is of the form owner/name.
+func (c *FeedbackCollector) CollectPR(ctx context.Context, repo string, prNumber int) ([]PRFeedback, error) {
+	owner, name, ok := strings.Cut(repo, "/")
+	if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("repository must be of the form owner/name")
+	}
+
+	var ret []PRFeedback
+	var after *string
+	for {
+		var data reviewThreadsData
+		err := c.query(
+			ctx, reviewThreadsQuery, map[string]any{
+				"owner":  owner,
+				"name":   name,
+				"number": prNumber,
+				"first":  c.cfg.PageSize,
+				"after":  after,
+			}, &data,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if data.Repository == nil || data.Repository.PullRequest == nil {
+			return nil, fmt.Errorf("pull request %s#%d not found", repo, prNumber)
+		}
+
+		threads := data.Repository.PullRequest.ReviewThreads
+		for _, thread := range threads.Nodes {
+			if thread.Comments.PageInfo.HasNextPage {
+				if err := c.fetchRemainingComments(ctx, &thread); err != nil {
+					return nil, err
+				}
+			}
+			if feedback, ok := c.convert(&thread); ok {
+				ret = append(ret, feedback)
+			}
+		}
+
+		if !threads.PageInfo.HasNextPage {
+			return ret, nil
+		}
+		after = &threads.PageInfo.EndCursor
+	}
+}
+
+func (c *FeedbackCollector) fetchRemainingComments(ctx context.Context, thread *reviewThread) error {
+	after := thread.Comments.PageInfo.EndCursor
+	for {
+		var data threadCommentsData
+		err := c.query(
+			ctx, threadCommentsQuery, map[string]any{
+				"id":    thread.ID,
+				"first": maxFeedbackPageSize,
+				"after": after,
+			}, &data,
+		)
+		if err != nil {
+			return err
+		}
+		if data.Node == nil {
+			return fmt.Errorf("review thread %s not found", thread.ID)
+		}
+		thread.Comments.Nodes = append(thread.Comments.Nodes, data.Node.Comments.Nodes...)
+		if !data.Node.Comments.PageInfo.HasNextPage {
+			return nil
+		}
+		after = data.Node.Comments.PageInfo.EndCursor
+	}
+}
+
+func (c *FeedbackCollector) convert(thread *reviewThread) (PRFeedback, bool) {
+	if len(thread.Comments.Nodes) == 0 {
+		return PRFeedback{}, false
+	}
+	if thread.IsResolved && !c.cfg.IncludeResolved {
+		return PRFeedback{}, false
+	}
+	top := thread.Comments.Nodes[0]
+	if top.IsMinimized && !c.cfg.IncludeMinimized {
+		return PRFeedback{}, false
+	}
+
+	feedback := PRFeedback{
+		ID:         strconv.FormatInt(top.DatabaseID, 10),
+		IsResolved: thread.IsResolved,
+	}
+	for _, comment := range thread.Comments.Nodes {
+		if comment.IsMinimized && !c.cfg.IncludeMinimized {
+			continue
+		}
+		feedback.Comments = append(feedback.Comments, comment.toComment())
+	}
+	return feedback, true
+}
+
+// query runs a GraphQL query and decodes its data into out.
+func (c *FeedbackCollector) query(ctx context.Context, query string, variables map[string]any, out any) error {
+	body, err := json.Marshal(map[string]any{"query": query, "variables": variables})
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.GraphQLURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	httpReq.Header.Set("Content-Type", "application/json")
+	if c.cfg.Tokens != nil {
+		token, err := c.cfg.Tokens.Token(ctx)
+		if err != nil {
+			return err
+		}
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.cfg.HTTPClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("github graphql request failed: unexpected status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("unable to decode github graphql response: %w", err)
+	}
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("github graphql request failed: %s", result.Errors[0].Message)
+	}
+	return json.Unmarshal(result.Data, out)
+}
+
+const reviewCommentFields = `
+	databaseId
+	author { login }
+	body
+	createdAt
+	diffHunk
+	path
+	commit { oid }
+	isMinimized
+	minimizedReason`
+
+const reviewThreadsQuery = `
+query($owner: String!, $name: String!, $number: Int!, $first: Int!, $after: String) {
+	repository(owner: $owner, name: $name) {
+		pullRequest(number: $number) {
+			reviewThreads(first: $first, after: $after) {
+				pageInfo { hasNextPage endCursor }
+				nodes {
+					id
+					isResolved
+					comments(first: 100) {
+						pageInfo { hasNextPage endCursor }
+						nodes {` + reviewCommentFields + `
+						}
+					}
+				}
+			}
+		}
+	}
+}`
+
+const threadCommentsQuery = `
+query($id: ID!, $first: Int!, $after: String) {
+	node(id: $id) {
+		... on PullRequestReviewThread {
+			comments(first: $first, after: $after) {
+				pageInfo { hasNextPage endCursor }
+				nodes {` + reviewCommentFields + `
+				}
+			}
+		}
+	}
+}`
+
+type graphQLPageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+type reviewCommentConnection struct {
+	PageInfo graphQLPageInfo `json:"pageInfo"`
+	Nodes    []reviewComment `json:"nodes"`
+}
+
+type reviewThread struct {
+	ID         string                  `json:"id"`
+	IsResolved bool                    `json:"isResolved"`
+	Comments   reviewCommentConnection `json:"comments"`
+}
+
+type reviewThreadsData struct {
+	Repository *struct {
+		PullRequest *struct {
+			ReviewThreads struct {
+				PageInfo graphQLPageInfo `json:"pageInfo"`
+				Nodes    []reviewThread  `json:"nodes"`
+			} `json:"reviewThreads"`
+		} `json:"pullRequest"`
+	} `json:"repository"`
+}
+
+type threadCommentsData struct {
+	Node *struct {
+		Comments reviewCommentConnection `json:"comments"`
+	} `json:"node"`
+}
+
+type reviewComment struct {
+	DatabaseID int64 `json:"databaseId"`
+	Author     *struct {
+		Login string `json:"login"`
+	} `json:"author"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"createdAt"`
+	DiffHunk  string    `json:"diffHunk"`
+	Path      string    `json:"path"`
+	Commit    *struct {
+		OID string `json:"oid"`
+	} `json:"commit"`
+	IsMinimized     bool    `json:"isMinimized"`
+	MinimizedReason *string `json:"minimizedReason"`
+}
+
+func (r *reviewComment) toComment() Comment {
+	origStart, start := hunkStart(r.DiffHunk)
+	ret := Comment{
+		Body:          r.Body,
+		Date:          r.CreatedAt,
+		DiffHunk:      r.DiffHunk,
+		Path:          r.Path,
+		StartLine:     start,
+		OrigStartLine: origStart,
+		IsMinimized:   r.IsMinimized,
+	}
+	// The author is null for deleted accounts.
+	if r.Author != nil {
+		ret.User = r.Author.Login
+	}
+	if r.Commit != nil {
+		ret.CommitHash = r.Commit.OID
+	}
+	if r.MinimizedReason != nil {
+		ret.MinimizedReason = *r.MinimizedReason
+	}
+	return ret
+}
+
+// hunkStart returns the old and new start lines from the "@@ -a,b +c,d @@" header of diffHunk. The counts are
+// optional. Zeros are returned if the header can't be parsed.
+func hunkStart(diffHunk string) (origStart int, start int) {
+	header, _, _ := strings.Cut(diffHunk, "\n")
+	fields := strings.Fields(header)
+	if len(fields) < 4 || fields[0] != "@@" || fields[3] != "@@" {
+		return 0, 0
+	}
+	return hunkRangeStart(fields[1], "-"), hunkRangeStart(fields[2], "+")
+}
+
+func hunkRangeStart(hunkRange string, prefix string) int {
+	hunkRange, ok := strings.CutPrefix(hunkRange, prefix)
+	if !ok {
+		return 0
+	}
+	startLine, _, _ := strings.Cut(hunkRange, ",")
+	n, err := strconv.Atoi(startLine)
+	if err != nil {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,223 @@
+package messages_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/plan42-ai/sdk-go/internal/util"
+	"github.com/plan42-ai/sdk-go/p42"
+	"github.com/plan42-ai/sdk-go/p42/messages"
+	"github.com/stretchr/testify/require"
+)
+
+var commentDate = time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+const diffHunk = `@@ -10,4 +12,5 @@ func main() {
+ 	ctx := context.Background()
+-	run(ctx)
++	if err := run(ctx); err != nil {
++		log.Fatal(err)
++	}`
+
+func comment(id int, body string, minimized bool) map[string]any {
+	ret := map[string]any{
+		"databaseId":  id,
+		"author":      map[string]any{"login": "reviewer"},
+		"body":        body,
+		"createdAt":   commentDate,
+		"diffHunk":    diffHunk,
+		"path":        "main.go",
+		"commit":      map[string]any{"oid": "abc123"},
+		"isMinimized": minimized,
+	}
+	if minimized {
+		ret["minimizedReason"] = "OUTDATED"
+	}
+	return ret
+}
+
+func thread(id string, resolved bool, more bool, comments ...map[string]any) map[string]any {
+	return map[string]any{
+		"id":         id,
+		"isResolved": resolved,
+		"comments": map[string]any{
+			"pageInfo": map[string]any{"hasNextPage": more, "endCursor": id + "-c1"},
+			"nodes":    comments,
+		},
+	}
+}
+
+// serveReviewThreads serves two pages of review threads for org/repo#7, plus the second page of comments of thread
+// t1.
+func serveReviewThreads(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, "Bearer gh-token", r.Header.Get("Authorization"))
+				var req struct {
+					Query     string         `json:"query"`
+					Variables map[string]any `json:"variables"`
+				}
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+				var data any
+				switch {
+				case strings.Contains(req.Query, "reviewThreads") && req.Variables["number"] != float64(7):
+					data = map[string]any{"repository": map[string]any{"pullRequest": nil}}
+				case strings.Contains(req.Query, "reviewThreads") && req.Variables["after"] == nil:
+					require.Equal(t, "org", req.Variables["owner"])
+					require.Equal(t, "repo", req.Variables["name"])
+					data = map[string]any{
+						"repository": map[string]any{
+							"pullRequest": map[string]any{
+								"reviewThreads": map[string]any{
+									"pageInfo": map[string]any{"hasNextPage": true, "endCursor": "p1"},
+									"nodes": []any{
+										thread("t1", false, true, comment(1, "please fix", false)),
+										thread("t2", true, false, comment(2, "done", false)),
+									},
+								},
+							},
+						},
+					}
+				case strings.Contains(req.Query, "reviewThreads"):
+					require.Equal(t, "p1", req.Variables["after"])
+					data = map[string]any{
+						"repository": map[string]any{
+							"pullRequest": map[string]any{
+								"reviewThreads": map[string]any{
+									"pageInfo": map[string]any{"hasNextPage": false},
+									"nodes": []any{
+										thread("t3", false, false, comment(3, "nit", true)),
+									},
+								},
+							},
+						},
+					}
+				default:
+					require.Equal(t, "t1", req.Variables["id"])
+					require.Equal(t, "t1-c1", req.Variables["after"])
+					data = map[string]any{
+						"node": map[string]any{
+							"comments": map[string]any{
+								"pageInfo": map[string]any{"hasNextPage": false},
+								"nodes":    []any{comment(4, "outdated", true), comment(5, "fixed", false)},
+							},
+						},
+					}
+				}
+				_ = json.NewEncoder(w).Encode(map[string]any{"data": data})
+			},
+		),
+	)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+type staticToken string
+
+func (s staticToken) Token(context.Context) (string, error) { return string(s), nil }
+
+func TestFeedbackCollectorCollect(t *testing.T) {
+	t.Parallel()
+	srv := serveReviewThreads(t)
+	collector := messages.NewFeedbackCollector(
+		&messages.FeedbackCollectorConfig{GraphQLURL: srv.URL, Tokens: staticToken("gh-token"), PageSize: 2},
+	)
+	task := &p42.Task{
+		RepoInfo: map[string]*p42.RepoInfo{
+			"org/repo":  {PRNumber: util.Pointer(7)},
+			"org/no-pr": {FeatureBranch: "feature"},
+		},
+	}
+
+	feedback, err := collector.Collect(context.Background(), task)
+	require.NoError(t, err)
+	require.Equal(
+		t, map[string][]messages.PRFeedback{
+			"org/repo": {
+				{
+					ID: "1",
+					Comments: []messages.Comment{
+						{
+							User:          "reviewer",
+							Body:          "please fix",
+							Date:          commentDate,
+							DiffHunk:      diffHunk,
+							Path:          "main.go",
+							StartLine:     12,
+							OrigStartLine: 10,
+							CommitHash:    "abc123",
+						},
+						{
+							User:          "reviewer",
+							Body:          "fixed",
+							Date:          commentDate,
+							DiffHunk:      diffHunk,
+							Path:          "main.go",
+							StartLine:     12,
+							OrigStartLine: 10,
+							CommitHash:    "abc123",
+						},
+					},
+				},
+			},
+		}, feedback,
+	)
+}
+
+func TestFeedbackCollectorIncludeResolvedAndMinimized(t *testing.T) {
+	t.Parallel()
+	srv := serveReviewThreads(t)
+	collector := messages.NewFeedbackCollector(
+		&messages.FeedbackCollectorConfig{
+			GraphQLURL:       srv.URL,
+			Tokens:           staticToken("gh-token"),
+			IncludeResolved:  true,
+			IncludeMinimized: true,
+		},
+	)
+
+	feedback, err := collector.CollectPR(context.Background(), "org/repo", 7)
+	require.NoError(t, err)
+	require.Len(t, feedback, 3)
+	require.Len(t, feedback[0].Comments, 3)
+	require.True(t, feedback[0].Comments[1].IsMinimized)
+	require.Equal(t, "OUTDATED", feedback[0].Comments[1].MinimizedReason)
+	require.True(t, feedback[1].IsResolved)
+	require.Equal(t, "3", feedback[2].ID)
+}
+
+func TestFeedbackCollectorErrors(t *testing.T) {
+	t.Parallel()
+	srv := serveReviewThreads(t)
+	collector := messages.NewFeedbackCollector(
+		&messages.FeedbackCollectorConfig{GraphQLURL: srv.URL, Tokens: staticToken("gh-token")},
+	)
+
+	_, err := collector.CollectPR(context.Background(), "repo", 7)
+	require.EqualError(t, err, "repository must be of the form owner/name")
+
+	_, err = collector.Collect(
+		context.Background(), &p42.Task{RepoInfo: map[string]*p42.RepoInfo{"org/repo": {PRNumber: util.Pointer(8)}}},
+	)
+	require.EqualError(t, err, "unable to collect feedback for org/repo: pull request org/repo#8 not found")
+
+	graphQLErr := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte(`{"data":null,"errors":[{"message":"Bad credentials"}]}`))
+			},
+		),
+	)
+	defer graphQLErr.Close()
+	_, err = messages.NewFeedbackCollector(&messages.FeedbackCollectorConfig{GraphQLURL: graphQLErr.URL}).
+		CollectPR(context.Background(), "org/repo", 7)
+	require.EqualError(t, err, "github graphql request failed: Bad credentials")
+}
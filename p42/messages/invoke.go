@@ -1,6 +1,7 @@
 package messages
 
 import (
+	"context"
 	"encoding/json"
 	"time"
 
@@ -35,6 +36,40 @@ func (r *InvokeAgentRequest) GetModel() p42.ModelType {
 	return *r.Task.Model
 }
 
+// Secrets returns the secret values carried by the request: the GitHub and agent tokens, and the values of any
+// environment variables marked as secret. It is intended to seed a p42.Redactor.
+func (r *InvokeAgentRequest) Secrets() []string {
+	var ret []string
+	if r.GithubToken != "" {
+		ret = append(ret, r.GithubToken)
+	}
+	if r.AgentToken != "" {
+		ret = append(ret, r.AgentToken)
+	}
+	if r.Environment != nil {
+		for _, envVar := range r.Environment.EnvVars {
+			if envVar.IsSecret && envVar.Value != "" {
+				ret = append(ret, envVar.Value)
+			}
+		}
+	}
+	return ret
+}
+
+// ResolveSecrets replaces the secret references in the values of the environment's secret env vars with the secrets
+// they refer to. Runners call it before starting the environment, and before seeding a p42.Redactor with Secrets.
+func (r *InvokeAgentRequest) ResolveSecrets(ctx context.Context) error {
+	if r.Environment == nil {
+		return nil
+	}
+	envVars, err := p42.ResolveEnvVars(ctx, r.Environment.EnvVars)
+	if err != nil {
+		return err
+	}
+	r.Environment.EnvVars = envVars
+	return nil
+}
+
 func (r InvokeAgentRequest) MarshalJSON() ([]byte, error) {
 	var tmp struct {
 		Type        MessageType
@@ -161,6 +161,14 @@ const (
 	ActionPingRunnerQueue             Action = "PingRunnerQueue"
 	ActionUpdateRunnerQueue           Action = "UpdateRunnerQueue"
 	ActionListOrgsForGithubConnection Action = "ListOrgsForGithubConnection"
+	ActionSearchRepos                 Action = "SearchRepos"
+
+	// The auth provider actions are proposed, along with their APIs. See AuthenticationProvider.
+	ActionCreateAuthProvider Action = "CreateAuthProvider"
+	ActionGetAuthProvider    Action = "GetAuthProvider"
+	ActionListAuthProviders  Action = "ListAuthProviders"
+	ActionUpdateAuthProvider Action = "UpdateAuthProvider"
+	ActionDeleteAuthProvider Action = "DeleteAuthProvider"
 )
 
 // TokenType defines the type of token a principal used to authenticate.
@@ -480,6 +488,12 @@ func init() {
 			ActionUpdateRunnerQueue,           // (0x0000_0000_0004_0000, 0)
 			ActionGetTaskGithubCreds,          // (0x0000_0000_0008_0000, 0)
 			ActionListOrgsForGithubConnection, // (0x0000_0000_0010_0000, 0)
+			ActionSearchRepos,                 // (0x0000_0000_0020_0000, 0)
+			ActionCreateAuthProvider,          // (0x0000_0000_0040_0000, 0)
+			ActionGetAuthProvider,             // (0x0000_0000_0080_0000, 0)
+			ActionListAuthProviders,           // (0x0000_0000_0100_0000, 0)
+			ActionUpdateAuthProvider,          // (0x0000_0000_0200_0000, 0)
+			ActionDeleteAuthProvider,          // (0x0000_0000_0400_0000, 0)
 
 		},
 		ActionBitVector{
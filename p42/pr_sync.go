@@ -0,0 +1,496 @@
+package p42
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/plan42-ai/clock"
+	"github.com/plan42-ai/sdk-go/internal/util"
+)
+
+// Pull request statuses stored in RepoInfo.PRStatus.
+const (
+	PRStatusOpen   = "open"
+	PRStatusDraft  = "draft"
+	PRStatusMerged = "merged"
+	PRStatusClosed = "closed"
+)
+
+// Aggregate check statuses reported in PRState.Checks.
+const (
+	PRChecksPending = "pending"
+	PRChecksSuccess = "success"
+	PRChecksFailure = "failure"
+)
+
+// PRState is the state of a pull request on GitHub.
+type PRState struct {
+	// Status is one of PRStatusOpen, PRStatusDraft, PRStatusMerged or PRStatusClosed.
+	Status string `json:"Status"`
+
+	// ID is the pull request's ID in decimal, as stored in RepoInfo.PRID, and Link its web URL.
+	ID   string `json:"ID,omitempty"`
+	Link string `json:"Link,omitempty"`
+
+	// Checks aggregates the check runs of the pull request's head commit. It is one of PRChecksPending,
+	// PRChecksSuccess or PRChecksFailure, or empty if the commit has no check runs or they couldn't be fetched.
+	Checks string `json:"Checks,omitempty"`
+}
+
+// PRStatusFetcher looks up the state of a pull request. repo is of the form owner/name.
+type PRStatusFetcher interface {
+	FetchPRState(ctx context.Context, repo string, number int) (*PRState, error)
+}
+
+// GithubPRStatusFetcherConfig holds configuration for GithubPRStatusFetcher.
+type GithubPRStatusFetcherConfig struct {
+	// HTTPClient calls the GitHub API. Defaults to http.DefaultClient. To authenticate as a GitHub App
+	// installation, use a client whose transport is GithubApp.Transport and leave Tokens nil.
+	HTTPClient *http.Client
+
+	// Tokens, if set, supplies a bearer token for each request.
+	Tokens GithubTokenProvider
+
+	// BaseURL is the GitHub API URL. Defaults to DefaultGithubAPIURL.
+	BaseURL string
+}
+
+// GithubPRStatusFetcher is a PRStatusFetcher that uses the GitHub REST API.
+type GithubPRStatusFetcher struct {
+	cfg     GithubPRStatusFetcherConfig
+	baseURL *url.URL
+}
+
+// NewGithubPRStatusFetcher creates a GithubPRStatusFetcher.
+func NewGithubPRStatusFetcher(cfg *GithubPRStatusFetcherConfig) (*GithubPRStatusFetcher, error) {
+	if cfg == nil {
+		cfg = &GithubPRStatusFetcherConfig{}
+	}
+	f := &GithubPRStatusFetcher{cfg: *cfg}
+	if f.cfg.HTTPClient == nil {
+		f.cfg.HTTPClient = http.DefaultClient
+	}
+	if f.cfg.BaseURL == "" {
+		f.cfg.BaseURL = DefaultGithubAPIURL
+	}
+	var err error
+	f.baseURL, err = url.Parse(f.cfg.BaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base url: %w", err)
+	}
+	return f, nil
+}
+
+// FetchPRState implements PRStatusFetcher.
+func (f *GithubPRStatusFetcher) FetchPRState(ctx context.Context, repo string, number int) (*PRState, error) {
+	owner, name, ok := strings.Cut(repo, "/")
+	if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("repository must be of the form owner/name")
+	}
+
+	var pr struct {
+		ID      int64  `json:"id"`
+		HTMLURL string `json:"html_url"`
+		State   string `json:"state"`
+		Draft   bool   `json:"draft"`
+		Merged  bool   `json:"merged"`
+		Head    struct {
+			SHA string `json:"sha"`
+		} `json:"head"`
+	}
+	u := f.baseURL.JoinPath("repos", url.PathEscape(owner), url.PathEscape(name), "pulls", strconv.Itoa(number))
+	if err := f.get(ctx, u, &pr); err != nil {
+		return nil, err
+	}
+
+	state := &PRState{ID: strconv.FormatInt(pr.ID, 10), Link: pr.HTMLURL}
+	switch {
+	case pr.Merged:
+		state.Status = PRStatusMerged
+	case pr.State == "closed":
+		state.Status = PRStatusClosed
+	case pr.Draft:
+		state.Status = PRStatusDraft
+	default:
+		state.Status = PRStatusOpen
+	}
+
+	var checks struct {
+		CheckRuns []struct {
+			Status     string `json:"status"`
+			Conclusion string `json:"conclusion"`
+		} `json:"check_runs"`
+	}
+	u = f.baseURL.JoinPath("repos", url.PathEscape(owner), url.PathEscape(name), "commits", pr.Head.SHA, "check-runs")
+	u.RawQuery = url.Values{"per_page": {"100"}}.Encode()
+	// Checks are best-effort: the token may not be allowed to read them, in which case their status is unknown.
+	if err := f.get(ctx, u, &checks); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		slog.WarnContext(
+			ctx, "GithubPRStatusFetcher: unable to fetch check runs", "repo", repo, "number", number, "error", err,
+		)
+		return state, nil
+	}
+	for _, run := range checks.CheckRuns {
+		switch {
+		case run.Status != "completed":
+			if state.Checks != PRChecksFailure {
+				state.Checks = PRChecksPending
+			}
+		case run.Conclusion == "success" || run.Conclusion == "neutral" || run.Conclusion == "skipped":
+			if state.Checks == "" {
+				state.Checks = PRChecksSuccess
+			}
+		default:
+			state.Checks = PRChecksFailure
+		}
+	}
+	return state, nil
+}
+
+func (f *GithubPRStatusFetcher) get(ctx context.Context, u *url.URL, out any) error {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Accept", "application/vnd.github+json")
+	if f.cfg.Tokens != nil {
+		token, err := f.cfg.Tokens.Token(ctx)
+		if err != nil {
+			return err
+		}
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := f.cfg.HTTPClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var body struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		return fmt.Errorf("github request failed: status %d: %s", resp.StatusCode, body.Message)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// PRSyncConfig holds configuration for PRSyncer.
+type PRSyncConfig struct {
+	Client   *Client
+	TenantID string
+
+	// WorkstreamID limits the sync to the tasks of a workstream. If nil, every task of the tenant is synced.
+	WorkstreamID  *string
+	FeatureFlags  FeatureFlags
+	DelegatedAuth DelegatedAuthInfo
+
+	// Fetcher looks up pull request states.
+	Fetcher PRStatusFetcher
+
+	// CompleteOnMerge moves a task to TaskStateCompleted once all of its pull requests are merged. Only workstream
+	// tasks can change state, so other tasks only have their RepoInfo updated.
+	CompleteOnMerge bool
+
+	// MaxConflictRetries is how many times an update that fails with a version conflict is retried, against a
+	// freshly fetched task. Defaults to 5.
+	MaxConflictRetries int
+
+	// Interval is how often Run syncs. Defaults to 1 minute.
+	Interval time.Duration
+
+	// Clock is used for PRStatusUpdatedAt and by Run. Defaults to the real clock.
+	Clock clock.Clock
+}
+
+// PRSyncStatus is the outcome of syncing a single task.
+type PRSyncStatus string
+
+const (
+	PRSyncUpdated   PRSyncStatus = "Updated"
+	PRSyncUnchanged PRSyncStatus = "Unchanged"
+	PRSyncFailed    PRSyncStatus = "Failed"
+)
+
+// PRSyncResult is the outcome of syncing a single task.
+type PRSyncResult struct {
+	TaskID string       `json:"TaskId"`
+	Status PRSyncStatus `json:"Status"`
+
+	// Task is the task after the sync.
+	Task *Task `json:"Task,omitempty"`
+
+	// PRs is the state of each of the task's pull requests, keyed like RepoInfo.
+	PRs map[string]*PRState `json:"PRs,omitempty"`
+
+	// Completed is set if the task was moved to TaskStateCompleted.
+	Completed bool `json:"Completed,omitempty"`
+
+	// Attempts is the number of times the update was sent.
+	Attempts int `json:"Attempts"`
+
+	Err          error  `json:"-"`
+	ErrorMessage string `json:"Error,omitempty"`
+}
+
+// PRSyncReport is the result of a sync.
+type PRSyncReport struct {
+	Results   []PRSyncResult `json:"Results"`
+	Updated   int            `json:"Updated"`
+	Unchanged int            `json:"Unchanged"`
+	Failed    int            `json:"Failed"`
+
+	// Err is set by Run when listing tasks fails. The sync is skipped, and retried after Interval.
+	Err          error  `json:"-"`
+	ErrorMessage string `json:"Error,omitempty"`
+}
+
+// PRSyncer keeps the pull request fields of RepoInfo current for tasks awaiting code review.
+type PRSyncer struct {
+	cfg PRSyncConfig
+}
+
+const (
+	defaultPRSyncInterval           = time.Minute
+	defaultPRSyncMaxConflictRetries = 5
+)
+
+// NewPRSyncer creates a PRSyncer.
+func NewPRSyncer(cfg *PRSyncConfig) (*PRSyncer, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("cfg is nil")
+	}
+	if cfg.Client == nil {
+		return nil, fmt.Errorf("client is required")
+	}
+	if cfg.TenantID == "" {
+		return nil, fmt.Errorf("tenant id is required")
+	}
+	if cfg.Fetcher == nil {
+		return nil, fmt.Errorf("fetcher is required")
+	}
+	s := &PRSyncer{cfg: *cfg}
+	if s.cfg.MaxConflictRetries <= 0 {
+		s.cfg.MaxConflictRetries = defaultPRSyncMaxConflictRetries
+	}
+	if s.cfg.Interval <= 0 {
+		s.cfg.Interval = defaultPRSyncInterval
+	}
+	if s.cfg.Clock == nil {
+		s.cfg.Clock = clock.NewRealClock()
+	}
+	return s, nil
+}
+
+// SyncOnce syncs every task in TaskStateAwaitingCodeReview. A failure on one task doesn't stop the others. The
+// returned error is only set if listing tasks fails; per-task failures are reported in the PRSyncReport.
+func (s *PRSyncer) SyncOnce(ctx context.Context) (*PRSyncReport, error) {
+	query := NewTaskQuery().WithStates(TaskStateAwaitingCodeReview)
+	if s.cfg.WorkstreamID != nil {
+		query = query.WithWorkstreamID(*s.cfg.WorkstreamID)
+	}
+
+	report := &PRSyncReport{}
+	tasks := QueryTasks(
+		ctx, s.cfg.Client, &QueryTasksRequest{
+			FeatureFlags:      s.cfg.FeatureFlags,
+			DelegatedAuthInfo: s.cfg.DelegatedAuth,
+			TenantID:          s.cfg.TenantID,
+			Query:             query,
+		},
+	)
+	for task, err := range tasks {
+		if err != nil {
+			return nil, err
+		}
+		result := s.SyncTask(ctx, task)
+		switch result.Status {
+		case PRSyncUpdated:
+			report.Updated++
+		case PRSyncUnchanged:
+			report.Unchanged++
+		case PRSyncFailed:
+			report.Failed++
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report, nil
+}
+
+// Run syncs every Interval until ctx is done, passing each report to onReport (which may be nil). A sync that fails
+// to list tasks doesn't stop Run; its report only has Err set. Run returns ctx's error.
+func (s *PRSyncer) Run(ctx context.Context, onReport func(*PRSyncReport)) error {
+	timer := s.cfg.Clock.NewTimer(s.cfg.Interval)
+	defer timer.Stop()
+	for {
+		report, err := s.SyncOnce(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			report = &PRSyncReport{Err: err, ErrorMessage: err.Error()}
+		}
+		if onReport != nil {
+			onReport(report)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C():
+			timer.Reset(s.cfg.Interval)
+		}
+	}
+}
+
+// SyncTask syncs a single task, refetching it and retrying on version conflicts.
+func (s *PRSyncer) SyncTask(ctx context.Context, task *Task) PRSyncResult {
+	result := PRSyncResult{TaskID: task.TaskID, Task: task, PRs: make(map[string]*PRState)}
+	failed := func(err error) PRSyncResult {
+		result.Status = PRSyncFailed
+		result.Err = err
+		result.ErrorMessage = err.Error()
+		return result
+	}
+
+	// PR states don't depend on the task's version, so they are fetched once, outside the retry loop.
+	for repo, info := range task.RepoInfo {
+		if info == nil || info.PRNumber == nil {
+			continue
+		}
+		state, err := s.cfg.Fetcher.FetchPRState(ctx, repo, *info.PRNumber)
+		if err != nil {
+			return failed(fmt.Errorf("unable to fetch pull request for %s: %w", repo, err))
+		}
+		result.PRs[repo] = state
+	}
+
+	backoff := util.NewBackoff(50*time.Millisecond, 2*time.Second)
+	for {
+		repoInfo, changed := s.applyStates(task, result.PRs)
+		complete := s.shouldComplete(task, result.PRs)
+		if !changed && !complete {
+			result.Status = PRSyncUnchanged
+			return result
+		}
+
+		result.Attempts++
+		updated, err := s.update(ctx, task, repoInfo, complete)
+		var conflictErr *ConflictError
+		switch {
+		case err == nil:
+			result.Status = PRSyncUpdated
+			result.Task = updated
+			result.Completed = complete
+			return result
+		case errors.As(err, &conflictErr) && result.Attempts <= s.cfg.MaxConflictRetries:
+			backoff.Backoff()
+			if err := backoff.Wait(ctx); err != nil {
+				return failed(err)
+			}
+			task, err = s.cfg.Client.GetTask(
+				ctx, &GetTaskRequest{
+					FeatureFlags:      s.cfg.FeatureFlags,
+					DelegatedAuthInfo: s.cfg.DelegatedAuth,
+					TenantID:          s.cfg.TenantID,
+					TaskID:            task.TaskID,
+				},
+			)
+			if err != nil {
+				return failed(err)
+			}
+			result.Task = task
+		default:
+			return failed(err)
+		}
+	}
+}
+
+// applyStates returns a copy of the task's RepoInfo with the fetched states applied, and whether anything changed.
+func (s *PRSyncer) applyStates(task *Task, states map[string]*PRState) (map[string]*RepoInfo, bool) {
+	repoInfo := maps.Clone(task.RepoInfo)
+	changed := false
+	now := s.cfg.Clock.Now().UTC()
+	for repo, state := range states {
+		info, ok := repoInfo[repo]
+		if !ok || info == nil {
+			continue
+		}
+		updated := *info
+		if updated.PRStatus == nil || *updated.PRStatus != state.Status {
+			updated.PRStatus = util.Pointer(state.Status)
+			updated.PRStatusUpdatedAt = util.Pointer(now)
+		}
+		if state.ID != "" && (updated.PRID == nil || *updated.PRID != state.ID) {
+			updated.PRID = util.Pointer(state.ID)
+		}
+		if updated.PRLink == nil && state.Link != "" {
+			updated.PRLink = util.Pointer(state.Link)
+		}
+		if updated != *info {
+			repoInfo[repo] = &updated
+			changed = true
+		}
+	}
+	return repoInfo, changed
+}
+
+// shouldComplete returns true if the task should move to TaskStateCompleted: it is a workstream task awaiting code
+// review, and every pull request it has is merged.
+func (s *PRSyncer) shouldComplete(task *Task, states map[string]*PRState) bool {
+	if !s.cfg.CompleteOnMerge || task.WorkstreamID == nil || task.State != TaskStateAwaitingCodeReview {
+		return false
+	}
+	if len(states) == 0 {
+		return false
+	}
+	for _, state := range states {
+		if state.Status != PRStatusMerged {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *PRSyncer) update(ctx context.Context, task *Task, repoInfo map[string]*RepoInfo, complete bool) (*Task, error) {
+	if task.WorkstreamID == nil {
+		return s.cfg.Client.UpdateTask(
+			ctx, &UpdateTaskRequest{
+				FeatureFlags:      s.cfg.FeatureFlags,
+				DelegatedAuthInfo: s.cfg.DelegatedAuth,
+				TenantID:          s.cfg.TenantID,
+				TaskID:            task.TaskID,
+				Version:           task.Version,
+				RepoInfo:          &repoInfo,
+			},
+		)
+	}
+
+	req := &UpdateWorkstreamTaskRequest{
+		FeatureFlags:      s.cfg.FeatureFlags,
+		DelegatedAuthInfo: s.cfg.DelegatedAuth,
+		TenantID:          s.cfg.TenantID,
+		WorkstreamID:      *task.WorkstreamID,
+		TaskID:            task.TaskID,
+		Version:           task.Version,
+		RepoInfo:          &repoInfo,
+	}
+	if complete {
+		req.State = util.Pointer(TaskStateCompleted)
+		req.CurrentState = util.Pointer(task.State)
+	}
+	return s.cfg.Client.UpdateWorkstreamTask(ctx, req)
+}
@@ -0,0 +1,389 @@
+package p42_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/plan42-ai/clock"
+	"github.com/plan42-ai/sdk-go/internal/util"
+	"github.com/plan42-ai/sdk-go/p42"
+	"github.com/stretchr/testify/require"
+)
+
+var prSyncNow = time.Date(2025, time.March, 1, 9, 0, 0, 0, time.UTC)
+
+// fakePRSyncServer keeps tasks in memory and applies RepoInfo and State updates, enforcing If-Match versions.
+type fakePRSyncServer struct {
+	mu    sync.Mutex
+	tasks []*p42.Task
+
+	// bumps is the number of upcoming updates that find the task concurrently modified, and fail with a conflict.
+	bumps   int
+	updates int
+
+	// listErrors is the number of upcoming task listings that fail.
+	listErrors int
+}
+
+func (s *fakePRSyncServer) find(id string) *p42.Task {
+	for _, task := range s.tasks {
+		if task.TaskID == id {
+			return task
+		}
+	}
+	return nil
+}
+
+func (s *fakePRSyncServer) handler(t *testing.T) http.Handler {
+	writeJSON := func(w http.ResponseWriter, status int, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		require.NoError(t, json.NewEncoder(w).Encode(v))
+	}
+	update := func(w http.ResponseWriter, r *http.Request, repoInfo *map[string]*p42.RepoInfo, state *p42.TaskState) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.updates++
+		task := s.find(r.PathValue("task"))
+		if s.bumps > 0 {
+			s.bumps--
+			task.Version++
+		}
+		if r.Header.Get("If-Match") != strconv.Itoa(task.Version) {
+			writeJSON(w, http.StatusConflict, map[string]any{"ResponseCode": http.StatusConflict, "Message": "conflict"})
+			return
+		}
+		if repoInfo != nil {
+			task.RepoInfo = *repoInfo
+		}
+		if state != nil {
+			task.State = *state
+		}
+		task.Version++
+		writeJSON(w, http.StatusOK, task)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(
+		"GET /v1/tenants/ten/tasks", func(w http.ResponseWriter, _ *http.Request) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if s.listErrors > 0 {
+				s.listErrors--
+				writeJSON(w, http.StatusForbidden, map[string]any{"ResponseCode": http.StatusForbidden, "Message": "denied"})
+				return
+			}
+			var resp p42.ListTasksResponse
+			for _, task := range s.tasks {
+				resp.Tasks = append(resp.Tasks, *task)
+			}
+			writeJSON(w, http.StatusOK, resp)
+		},
+	)
+	mux.HandleFunc(
+		"GET /v1/tenants/ten/tasks/{task}", func(w http.ResponseWriter, r *http.Request) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			writeJSON(w, http.StatusOK, s.find(r.PathValue("task")))
+		},
+	)
+	mux.HandleFunc(
+		"PATCH /v1/tenants/ten/tasks/{task}", func(w http.ResponseWriter, r *http.Request) {
+			var req p42.UpdateTaskRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			update(w, r, req.RepoInfo, nil)
+		},
+	)
+	mux.HandleFunc(
+		"PATCH /v1/tenants/ten/workstreams/{ws}/tasks/{task}", func(w http.ResponseWriter, r *http.Request) {
+			var req p42.UpdateWorkstreamTaskRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			update(w, r, req.RepoInfo, req.State)
+		},
+	)
+	return mux
+}
+
+// newFakeGithubPulls serves pull requests 1 (open, failing checks), 2 (merged), 3 (draft, running checks) and 4 (open,
+// checks can't be read) of org/repo.
+func newFakeGithubPulls(t *testing.T) *httptest.Server {
+	t.Helper()
+	pulls := map[string]map[string]any{
+		"1": {"id": 1001, "node_id": "PR_1", "html_url": "https://github.com/org/repo/pull/1", "state": "open", "head": map[string]any{"sha": "s1"}},
+		"2": {"id": 1002, "node_id": "PR_2", "html_url": "https://github.com/org/repo/pull/2", "state": "closed", "merged": true, "head": map[string]any{"sha": "s2"}},
+		"3": {"id": 1003, "node_id": "PR_3", "html_url": "https://github.com/org/repo/pull/3", "state": "open", "draft": true, "head": map[string]any{"sha": "s3"}},
+		"4": {"id": 1004, "node_id": "PR_4", "html_url": "https://github.com/org/repo/pull/4", "state": "open", "head": map[string]any{"sha": "s4"}},
+	}
+	checks := map[string][]map[string]any{
+		"s1": {{"status": "completed", "conclusion": "success"}, {"status": "completed", "conclusion": "failure"}},
+		"s2": {{"status": "completed", "conclusion": "success"}},
+		"s3": {{"status": "completed", "conclusion": "success"}, {"status": "in_progress"}},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(
+		"GET /repos/org/repo/pulls/{n}", func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "Bearer gh-token", r.Header.Get("Authorization"))
+			pr, ok := pulls[r.PathValue("n")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"message":"Not Found"}`))
+				return
+			}
+			_ = json.NewEncoder(w).Encode(pr)
+		},
+	)
+	mux.HandleFunc(
+		"GET /repos/org/repo/commits/{sha}/check-runs", func(w http.ResponseWriter, r *http.Request) {
+			if r.PathValue("sha") == "s4" {
+				w.WriteHeader(http.StatusForbidden)
+				_, _ = w.Write([]byte(`{"message":"Resource not accessible by integration"}`))
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"check_runs": checks[r.PathValue("sha")]})
+		},
+	)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func reviewTask(id string, workstreamID *string, prNumbers ...int) *p42.Task {
+	task := &p42.Task{
+		TaskID:       id,
+		WorkstreamID: workstreamID,
+		State:        p42.TaskStateAwaitingCodeReview,
+		Version:      1,
+		RepoInfo:     map[string]*p42.RepoInfo{},
+	}
+	for i, n := range prNumbers {
+		task.RepoInfo["org/repo"+strconv.Itoa(i)] = &p42.RepoInfo{PRNumber: util.Pointer(n), PRStatus: util.Pointer("open")}
+	}
+	return task
+}
+
+func newTestPRSyncer(t *testing.T, fake *fakePRSyncServer, cfg p42.PRSyncConfig) *p42.PRSyncer {
+	t.Helper()
+	srv := httptest.NewServer(fake.handler(t))
+	t.Cleanup(srv.Close)
+	fetcher, err := p42.NewGithubPRStatusFetcher(
+		&p42.GithubPRStatusFetcherConfig{BaseURL: newFakeGithubPulls(t).URL, Tokens: staticGithubToken("gh-token")},
+	)
+	require.NoError(t, err)
+
+	cfg.Client = p42.NewClient(srv.URL)
+	cfg.TenantID = "ten"
+	cfg.Fetcher = &repoAliasFetcher{fetcher}
+	if cfg.Clock == nil {
+		cfg.Clock = clock.NewFakeClock(prSyncNow)
+	}
+	syncer, err := p42.NewPRSyncer(&cfg)
+	require.NoError(t, err)
+	return syncer
+}
+
+type staticGithubToken string
+
+func (s staticGithubToken) Token(context.Context) (string, error) { return string(s), nil }
+
+// repoAliasFetcher maps the org/repoN keys used by reviewTask onto the single org/repo served by newFakeGithubPulls.
+type repoAliasFetcher struct {
+	p42.PRStatusFetcher
+}
+
+func (f *repoAliasFetcher) FetchPRState(ctx context.Context, _ string, number int) (*p42.PRState, error) {
+	return f.PRStatusFetcher.FetchPRState(ctx, "org/repo", number)
+}
+
+func TestGithubPRStatusFetcher(t *testing.T) {
+	t.Parallel()
+	fetcher, err := p42.NewGithubPRStatusFetcher(
+		&p42.GithubPRStatusFetcherConfig{BaseURL: newFakeGithubPulls(t).URL, Tokens: staticGithubToken("gh-token")},
+	)
+	require.NoError(t, err)
+
+	tests := []struct {
+		number int
+		state  p42.PRState
+	}{
+		{1, p42.PRState{Status: p42.PRStatusOpen, ID: "1001", Link: "https://github.com/org/repo/pull/1", Checks: p42.PRChecksFailure}},
+		{2, p42.PRState{Status: p42.PRStatusMerged, ID: "1002", Link: "https://github.com/org/repo/pull/2", Checks: p42.PRChecksSuccess}},
+		{3, p42.PRState{Status: p42.PRStatusDraft, ID: "1003", Link: "https://github.com/org/repo/pull/3", Checks: p42.PRChecksPending}},
+		// The status of checks that can't be read is unknown.
+		{4, p42.PRState{Status: p42.PRStatusOpen, ID: "1004", Link: "https://github.com/org/repo/pull/4"}},
+	}
+	for _, tc := range tests {
+		state, err := fetcher.FetchPRState(context.Background(), "org/repo", tc.number)
+		require.NoError(t, err)
+		require.Equal(t, tc.state, *state)
+	}
+
+	_, err = fetcher.FetchPRState(context.Background(), "org/repo", 9)
+	require.EqualError(t, err, "github request failed: status 404: Not Found")
+	_, err = fetcher.FetchPRState(context.Background(), "repo", 1)
+	require.EqualError(t, err, "repository must be of the form owner/name")
+}
+
+func TestPRSyncerSyncOnce(t *testing.T) {
+	t.Parallel()
+	fake := &fakePRSyncServer{
+		tasks: []*p42.Task{
+			reviewTask("changed", nil, 3),
+			reviewTask("unchanged", nil, 1),
+			reviewTask("no-pr", nil),
+			{TaskID: "executing", State: p42.TaskStateExecuting, RepoInfo: reviewTask("", nil, 2).RepoInfo},
+		},
+	}
+	fake.tasks[1].RepoInfo["org/repo0"].PRID = util.Pointer("1001")
+	fake.tasks[1].RepoInfo["org/repo0"].PRLink = util.Pointer("https://github.com/org/repo/pull/1")
+	syncer := newTestPRSyncer(t, fake, p42.PRSyncConfig{})
+
+	report, err := syncer.SyncOnce(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, report.Updated)
+	require.Equal(t, 2, report.Unchanged)
+	require.Equal(t, 0, report.Failed)
+	require.Equal(t, p42.PRChecksFailure, report.Results[1].PRs["org/repo0"].Checks)
+	require.Equal(t, 1, fake.updates)
+
+	info := fake.tasks[0].RepoInfo["org/repo0"]
+	require.Equal(t, p42.PRStatusDraft, *info.PRStatus)
+	require.Equal(t, prSyncNow, *info.PRStatusUpdatedAt)
+	require.Equal(t, "1003", *info.PRID)
+	require.Equal(t, "https://github.com/org/repo/pull/3", *info.PRLink)
+	require.Equal(t, 2, fake.tasks[0].Version)
+
+	// A second sync finds nothing to change.
+	report, err = syncer.SyncOnce(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 0, report.Updated)
+	require.Equal(t, 1, fake.updates)
+}
+
+func TestPRSyncerCompleteOnMerge(t *testing.T) {
+	t.Parallel()
+	fake := &fakePRSyncServer{
+		tasks: []*p42.Task{
+			reviewTask("merged", util.Pointer("ws"), 2),
+			reviewTask("partly-merged", util.Pointer("ws"), 2, 1),
+			reviewTask("standalone", nil, 2),
+		},
+	}
+	syncer := newTestPRSyncer(t, fake, p42.PRSyncConfig{CompleteOnMerge: true})
+
+	report, err := syncer.SyncOnce(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 3, report.Updated)
+	require.True(t, report.Results[0].Completed)
+	require.Equal(t, p42.TaskStateCompleted, fake.tasks[0].State)
+	require.False(t, report.Results[1].Completed)
+	require.Equal(t, p42.TaskStateAwaitingCodeReview, fake.tasks[1].State)
+
+	// Tasks outside a workstream can't change state, so only their RepoInfo is updated.
+	require.False(t, report.Results[2].Completed)
+	require.Equal(t, p42.TaskStateAwaitingCodeReview, fake.tasks[2].State)
+	require.Equal(t, p42.PRStatusMerged, *fake.tasks[2].RepoInfo["org/repo0"].PRStatus)
+}
+
+func TestPRSyncerConflictRetry(t *testing.T) {
+	t.Parallel()
+	fake := &fakePRSyncServer{tasks: []*p42.Task{reviewTask("a", util.Pointer("ws"), 2)}, bumps: 2}
+	syncer := newTestPRSyncer(t, fake, p42.PRSyncConfig{CompleteOnMerge: true})
+
+	report, err := syncer.SyncOnce(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, p42.PRSyncUpdated, report.Results[0].Status)
+	require.Equal(t, 3, report.Results[0].Attempts)
+	require.Equal(t, p42.TaskStateCompleted, fake.tasks[0].State)
+
+	// Without enough retries, the task is reported as failed.
+	fake.tasks[0] = reviewTask("a", util.Pointer("ws"), 2)
+	fake.bumps = 5
+	syncer = newTestPRSyncer(t, fake, p42.PRSyncConfig{MaxConflictRetries: 1})
+	report, err = syncer.SyncOnce(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, report.Failed)
+	var conflictErr *p42.ConflictError
+	require.ErrorAs(t, report.Results[0].Err, &conflictErr)
+}
+
+func TestPRSyncerRun(t *testing.T) {
+	t.Parallel()
+	fake := &fakePRSyncServer{tasks: []*p42.Task{reviewTask("a", nil, 3)}}
+	clk := clock.NewFakeClock(prSyncNow)
+	syncer := newTestPRSyncer(t, fake, p42.PRSyncConfig{Clock: clk, Interval: time.Minute})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	reports := make(chan *p42.PRSyncReport)
+	done := make(chan error)
+	go func() {
+		done <- syncer.Run(
+			ctx, func(report *p42.PRSyncReport) {
+				reports <- report
+			},
+		)
+	}()
+
+	require.Equal(t, 1, (<-reports).Updated)
+	clk.Advance(time.Minute)
+	require.Equal(t, 1, (<-reports).Unchanged)
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestPRSyncerRunListError(t *testing.T) {
+	t.Parallel()
+	fake := &fakePRSyncServer{tasks: []*p42.Task{reviewTask("a", nil, 3)}, listErrors: 1}
+	clk := clock.NewFakeClock(prSyncNow)
+	syncer := newTestPRSyncer(t, fake, p42.PRSyncConfig{Clock: clk, Interval: time.Minute})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	reports := make(chan *p42.PRSyncReport)
+	done := make(chan error)
+	go func() {
+		done <- syncer.Run(
+			ctx, func(report *p42.PRSyncReport) {
+				reports <- report
+			},
+		)
+	}()
+
+	// A failed listing is reported, and the next sync runs as usual.
+	report := <-reports
+	require.ErrorContains(t, report.Err, "denied")
+	require.Equal(t, report.Err.Error(), report.ErrorMessage)
+	require.Empty(t, report.Results)
+	clk.Advance(time.Minute)
+	require.Equal(t, 1, (<-reports).Updated)
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestNewPRSyncerValidation(t *testing.T) {
+	t.Parallel()
+	client := p42.NewClient("http://localhost")
+	fetcher, err := p42.NewGithubPRStatusFetcher(nil)
+	require.NoError(t, err)
+
+	tests := []struct {
+		cfg *p42.PRSyncConfig
+		msg string
+	}{
+		{nil, "cfg is nil"},
+		{&p42.PRSyncConfig{}, "client is required"},
+		{&p42.PRSyncConfig{Client: client}, "tenant id is required"},
+		{&p42.PRSyncConfig{Client: client, TenantID: "ten"}, "fetcher is required"},
+	}
+	for _, tc := range tests {
+		_, err := p42.NewPRSyncer(tc.cfg)
+		require.EqualError(t, err, tc.msg)
+	}
+	_, err = p42.NewPRSyncer(&p42.PRSyncConfig{Client: client, TenantID: "ten", Fetcher: fetcher})
+	require.NoError(t, err)
+}
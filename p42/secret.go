@@ -0,0 +1,153 @@
+package p42
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// SecretMask replaces the values of secret env vars in output.
+const SecretMask = "********"
+
+// SecretProvider resolves secret references. A reference has the form scheme://ref, and the provider registered for
+// scheme is passed ref.
+type SecretProvider interface {
+	ResolveSecret(ctx context.Context, ref string) (string, error)
+}
+
+var secretProviders = struct {
+	sync.RWMutex
+	m map[string]SecretProvider
+}{
+	m: map[string]SecretProvider{
+		"env":  EnvSecretProvider{},
+		"file": FileSecretProvider{},
+	},
+}
+
+// RegisterSecretProvider registers the provider for a secret reference scheme, replacing any existing provider.
+// The env and file schemes are registered by default.
+func RegisterSecretProvider(scheme string, provider SecretProvider) {
+	secretProviders.Lock()
+	defer secretProviders.Unlock()
+	secretProviders.m[scheme] = provider
+}
+
+// UnregisterSecretProvider removes the provider for a secret reference scheme, so that values with that scheme are
+// literal values again.
+func UnregisterSecretProvider(scheme string) {
+	secretProviders.Lock()
+	defer secretProviders.Unlock()
+	delete(secretProviders.m, scheme)
+}
+
+func secretProvider(value string) (SecretProvider, string, bool) {
+	scheme, ref, ok := strings.Cut(value, "://")
+	if !ok {
+		return nil, "", false
+	}
+	secretProviders.RLock()
+	defer secretProviders.RUnlock()
+	provider, ok := secretProviders.m[scheme]
+	return provider, ref, ok
+}
+
+// IsSecretRef returns true if value is a reference to a secret with a registered scheme, such as env://API_KEY or
+// file:///run/secrets/api-key. Values with other schemes are literal values.
+func IsSecretRef(value string) bool {
+	_, _, ok := secretProvider(value)
+	return ok
+}
+
+// ResolveSecret returns the secret value refers to, or value itself if it isn't a secret reference.
+func ResolveSecret(ctx context.Context, value string) (string, error) {
+	provider, ref, ok := secretProvider(value)
+	if !ok {
+		return value, nil
+	}
+	return provider.ResolveSecret(ctx, ref)
+}
+
+// ResolveEnvVars returns a copy of envVars in which the values of secret env vars that are secret references are
+// replaced by the secrets they refer to. The values of env vars that are not secret are never resolved.
+func ResolveEnvVars(ctx context.Context, envVars []EnvVar) ([]EnvVar, error) {
+	if envVars == nil {
+		return nil, nil
+	}
+	ret := make([]EnvVar, len(envVars))
+	for i, envVar := range envVars {
+		if envVar.IsSecret {
+			value, err := ResolveSecret(ctx, envVar.Value)
+			if err != nil {
+				return nil, fmt.Errorf("unable to resolve secret %s: %w", envVar.Name, err)
+			}
+			envVar.Value = value
+		}
+		ret[i] = envVar
+	}
+	return ret, nil
+}
+
+// MaskSecretValue returns the value of a secret env var as it should be shown: secret references are shown as is,
+// and anything else is replaced by SecretMask.
+func MaskSecretValue(value string) string {
+	if IsSecretRef(value) {
+		return value
+	}
+	return SecretMask
+}
+
+// MaskEnvVars returns a copy of envVars in which the values of secret env vars are masked with MaskSecretValue.
+func MaskEnvVars(envVars []EnvVar) []EnvVar {
+	if envVars == nil {
+		return nil
+	}
+	ret := make([]EnvVar, len(envVars))
+	for i, envVar := range envVars {
+		if envVar.IsSecret {
+			envVar.Value = MaskSecretValue(envVar.Value)
+		}
+		ret[i] = envVar
+	}
+	return ret
+}
+
+// MaskSecrets masks the values of the environment's secret env vars, for display.
+func (e *Environment) MaskSecrets() {
+	e.EnvVars = MaskEnvVars(e.EnvVars)
+}
+
+// EnvSecretProvider resolves env://NAME references to the value of the environment variable NAME.
+type EnvSecretProvider struct{}
+
+// ResolveSecret implements SecretProvider.
+func (EnvSecretProvider) ResolveSecret(_ context.Context, ref string) (string, error) {
+	value, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", ref)
+	}
+	return value, nil
+}
+
+// FileSecretProvider resolves file://PATH references to the contents of the file at PATH, without trailing newlines.
+// file:///run/secrets/key refers to the absolute path /run/secrets/key, and file://key to a path relative to Dir.
+type FileSecretProvider struct {
+	// Dir is the directory relative paths are resolved against. Defaults to the working directory.
+	Dir string
+}
+
+// ResolveSecret implements SecretProvider.
+func (p FileSecretProvider) ResolveSecret(_ context.Context, ref string) (string, error) {
+	path := ref
+	if !filepath.IsAbs(path) && p.Dir != "" {
+		path = filepath.Join(p.Dir, path)
+	}
+	data, err := os.ReadFile(path) // #nosec: G304: reading the file a secret reference names is the point.
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
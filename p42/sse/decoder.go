@@ -0,0 +1,186 @@
+// Package sse decodes Server-Sent Event streams, as described by the HTML living standard
+// (https://html.spec.whatwg.org/multipage/server-sent-events.html#event-stream-interpretation).
+package sse
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultEventType is the type of events that don't have an "event" field.
+const DefaultEventType = "message"
+
+// Event is a single dispatched Server-Sent Event.
+type Event struct {
+	// Type is the value of the event's "event" field, or DefaultEventType if it had none.
+	Type string
+
+	// Data is the event's data. The values of multiple "data" fields are joined with "\n".
+	Data string
+
+	// ID is the last event ID in effect when the event was dispatched. Per the spec, an ID set by an earlier event
+	// applies to later events that don't set their own.
+	ID string
+}
+
+// Decoder reads events from a Server-Sent Event stream.
+type Decoder struct {
+	r       *bufio.Reader
+	started bool
+	// skipLF is set after a line ending in "\r", so that a following "\n" is treated as part of the same line ending.
+	// The "\n" isn't read eagerly, since that would block until the server sends more data.
+	skipLF bool
+
+	lastEventID string
+	retry       time.Duration
+
+	eventType string
+	data      strings.Builder
+	hasData   bool
+}
+
+// NewDecoder creates a Decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Decode returns the next event in the stream. It returns io.EOF at the end of the stream. An event that is not
+// terminated by a blank line before the end of the stream is discarded.
+func (d *Decoder) Decode() (*Event, error) {
+	for {
+		line, err := d.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if line == "" {
+			if event := d.dispatch(); event != nil {
+				return event, nil
+			}
+			continue
+		}
+		d.processLine(line)
+	}
+}
+
+// LastEventID returns the last event ID set by the stream. It should be sent in the Last-Event-ID header when
+// reconnecting.
+func (d *Decoder) LastEventID() string {
+	return d.lastEventID
+}
+
+// Retry returns the reconnection time most recently set by the stream, or 0 if the stream has not set one.
+func (d *Decoder) Retry() time.Duration {
+	return d.retry
+}
+
+// readLine reads a line terminated by "\r\n", "\n" or "\r", and returns it without the terminator. A byte order mark
+// at the start of the stream is skipped.
+func (d *Decoder) readLine() (string, error) {
+	if !d.started {
+		d.started = true
+		if r, _, err := d.r.ReadRune(); err == nil && r != '\uFEFF' {
+			_ = d.r.UnreadRune()
+		}
+	}
+
+	var buf bytes.Buffer
+	for {
+		b, err := d.r.ReadByte()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				// An unterminated final line is incomplete, and so is ignored.
+				return "", io.EOF
+			}
+			return "", err
+		}
+		skipLF := d.skipLF
+		d.skipLF = false
+		switch b {
+		case '\n':
+			if skipLF {
+				continue
+			}
+			return buf.String(), nil
+		case '\r':
+			d.skipLF = true
+			return buf.String(), nil
+		default:
+			buf.WriteByte(b)
+		}
+	}
+}
+
+func (d *Decoder) processLine(line string) {
+	if strings.HasPrefix(line, ":") {
+		// Comment.
+		return
+	}
+
+	field, value, found := strings.Cut(line, ":")
+	if found {
+		value = strings.TrimPrefix(value, " ")
+	}
+	// The service is documented as sending "data : ...", with a space before the colon, which the spec would treat as
+	// an unknown field.
+	field = strings.TrimRight(field, " ")
+
+	switch field {
+	case "event":
+		d.eventType = value
+	case "data":
+		if d.hasData {
+			d.data.WriteByte('\n')
+		}
+		d.data.WriteString(value)
+		d.hasData = true
+	case "id":
+		if !strings.ContainsRune(value, 0) {
+			d.lastEventID = value
+		}
+	case "retry":
+		if isDigits(value) {
+			if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+				d.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
+
+// dispatch returns the buffered event and resets the buffers. It returns nil if no data was buffered.
+func (d *Decoder) dispatch() *Event {
+	defer func() {
+		d.eventType = ""
+		d.data.Reset()
+		d.hasData = false
+	}()
+
+	if !d.hasData {
+		return nil
+	}
+	event := &Event{
+		Type: d.eventType,
+		Data: d.data.String(),
+		ID:   d.lastEventID,
+	}
+	if event.Type == "" {
+		event.Type = DefaultEventType
+	}
+	return event
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,133 @@
+package sse_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/plan42-ai/sdk-go/p42/sse"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeAll(t *testing.T, stream string) ([]sse.Event, *sse.Decoder) {
+	t.Helper()
+	d := sse.NewDecoder(strings.NewReader(stream))
+	var events []sse.Event
+	for {
+		event, err := d.Decode()
+		if errors.Is(err, io.EOF) {
+			return events, d
+		}
+		require.NoError(t, err)
+		events = append(events, *event)
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		stream   string
+		expected []sse.Event
+	}{
+		{
+			name:     "simple",
+			stream:   "event: log\ndata: hello\nid: 1\n\n",
+			expected: []sse.Event{{Type: "log", Data: "hello", ID: "1"}},
+		},
+		{
+			name:     "default type",
+			stream:   "data: hello\n\n",
+			expected: []sse.Event{{Type: sse.DefaultEventType, Data: "hello"}},
+		},
+		{
+			name:     "multi-line data",
+			stream:   "data: one\ndata: two\ndata:\n\n",
+			expected: []sse.Event{{Type: "message", Data: "one\ntwo\n"}},
+		},
+		{
+			name:     "only one leading space is removed",
+			stream:   "data:  two spaces \ndata:none\n\n",
+			expected: []sse.Event{{Type: "message", Data: " two spaces \nnone"}},
+		},
+		{
+			name:     "space before the colon",
+			stream:   "event : log\ndata : {}\nid : 1\n\n",
+			expected: []sse.Event{{Type: "log", Data: "{}", ID: "1"}},
+		},
+		{
+			name:     "field with no colon",
+			stream:   "data\ndata\n\n",
+			expected: []sse.Event{{Type: "message", Data: "\n"}},
+		},
+		{
+			name:     "comments are ignored",
+			stream:   ": keep-alive\ndata: x\n: another\n\n",
+			expected: []sse.Event{{Type: "message", Data: "x"}},
+		},
+		{
+			name:     "unknown fields are ignored",
+			stream:   "foo: bar\ndata: x\n\n",
+			expected: []sse.Event{{Type: "message", Data: "x"}},
+		},
+		{
+			name:     "event without data is not dispatched",
+			stream:   "event: ping\n\ndata: x\n\n",
+			expected: []sse.Event{{Type: "message", Data: "x"}},
+		},
+		{
+			name:     "byte order mark",
+			stream:   "\ufeffdata: x\n\n",
+			expected: []sse.Event{{Type: "message", Data: "x"}},
+		},
+		{
+			name:     "CRLF and CR line endings",
+			stream:   "data: a\r\ndata: b\rdata: c\r\n\r\rdata: d\n\n",
+			expected: []sse.Event{{Type: "message", Data: "a\nb\nc"}, {Type: "message", Data: "d"}},
+		},
+		{
+			name:   "id persists across events",
+			stream: "id: 7\ndata: a\n\ndata: b\n\nid\ndata: c\n\n",
+			expected: []sse.Event{
+				{Type: "message", Data: "a", ID: "7"},
+				{Type: "message", Data: "b", ID: "7"},
+				{Type: "message", Data: "c", ID: ""},
+			},
+		},
+		{
+			name:     "id containing NULL is ignored",
+			stream:   "id: 1\ndata: a\n\nid: 2\x003\ndata: b\n\n",
+			expected: []sse.Event{{Type: "message", Data: "a", ID: "1"}, {Type: "message", Data: "b", ID: "1"}},
+		},
+		{
+			name:     "unterminated event is discarded",
+			stream:   "data: a\n\ndata: b\n",
+			expected: []sse.Event{{Type: "message", Data: "a"}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(
+			tc.name, func(t *testing.T) {
+				t.Parallel()
+				events, _ := decodeAll(t, tc.stream)
+				require.Equal(t, tc.expected, events)
+			},
+		)
+	}
+}
+
+func TestDecoderRetry(t *testing.T) {
+	t.Parallel()
+	_, d := decodeAll(t, "retry: 1500\n\nretry: soon\n\nretry: -1\n\nretry\n\n")
+	require.Equal(t, 1500*time.Millisecond, d.Retry())
+}
+
+func TestDecoderLastEventID(t *testing.T) {
+	t.Parallel()
+	_, d := decodeAll(t, "id: 42\n\n")
+	// The id is recorded even though no event was dispatched.
+	require.Equal(t, "42", d.LastEventID())
+}
@@ -5,12 +5,16 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/plan42-ai/sdk-go/internal/util"
 )
 
 // ModelType represents the model to use for executing a task.
@@ -63,7 +67,7 @@ type Task struct {
 	AssignedToAI       bool                 `json:"AssignedToAI"`
 	TaskNumber         *int                 `json:"TaskNumber,omitempty"`
 	RepoInfo           map[string]*RepoInfo `json:"RepoInfo"`
-	LastTurnStatus     *string              `json:"LastTurnStatus,omitempty"`
+	LastTurnStatus     *TurnStatus          `json:"LastTurnStatus,omitempty"`
 	LastTurnIndex      *int                 `json:"LastTurnIndex,omitempty"`
 	State              TaskState            `json:"State"`
 	CreatedAt          time.Time            `json:"CreatedAt"`
@@ -108,6 +112,103 @@ func (r *GetTaskRequest) GetField(name string) (any, bool) {
 	}
 }
 
+// SearchTasksCriteria narrows the results of a task search to the pull requests of a repository. The service only
+// searches by pull request ID and task ID, so the SDK applies these criteria to the tasks the service returns. To
+// search for a pull request by its URL or number, look up its ID with ResolvePullRequestID.
+type SearchTasksCriteria struct {
+	// Repository is the repository the pull request belongs to, in "owner/name" form.
+	Repository *string `json:"Repository,omitempty"`
+
+	// Branch is the task's feature branch in Repository.
+	Branch *string `json:"Branch,omitempty"`
+
+	// PullRequestNumber is the number of the pull request in Repository.
+	PullRequestNumber *int `json:"PullRequestNumber,omitempty"`
+}
+
+// ParsePullRequestURL parses a GitHub pull request URL, such as https://github.com/owner/repo/pull/123, into search
+// criteria for its repository and pull request number.
+func ParsePullRequestURL(link string) (*SearchTasksCriteria, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pull request url: %w", err)
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if u.Host == "" || len(parts) != 4 || parts[0] == "" || parts[1] == "" || parts[2] != "pull" {
+		return nil, fmt.Errorf("invalid pull request url %q: expected https://<host>/<owner>/<repo>/pull/<number>", link)
+	}
+	number, err := strconv.Atoi(parts[3])
+	if err != nil || number <= 0 {
+		return nil, fmt.Errorf("invalid pull request url %q: pull request number must be a positive integer", link)
+	}
+	return &SearchTasksCriteria{
+		Repository:        util.Pointer(parts[0] + "/" + parts[1]),
+		PullRequestNumber: util.Pointer(number),
+	}, nil
+}
+
+// IsEmpty reports whether no criteria are set.
+func (c *SearchTasksCriteria) IsEmpty() bool {
+	return c == nil || (c.Repository == nil && c.Branch == nil && c.PullRequestNumber == nil)
+}
+
+// Validate checks that the criteria are well-formed.
+func (c *SearchTasksCriteria) Validate() error {
+	if c.IsEmpty() {
+		return nil
+	}
+	if c.Repository == nil {
+		return fmt.Errorf("repository is required when searching by branch or pull request number")
+	}
+	owner, name, ok := strings.Cut(*c.Repository, "/")
+	if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return fmt.Errorf("repository must be of the form owner/name")
+	}
+	if c.Branch != nil && *c.Branch == "" {
+		return fmt.Errorf("branch must not be empty")
+	}
+	if c.PullRequestNumber != nil && *c.PullRequestNumber <= 0 {
+		return fmt.Errorf("pull request number must be positive")
+	}
+	return nil
+}
+
+// ResolvePullRequestID looks up the ID of the pull request identified by Repository and PullRequestNumber, so that it
+// can be used as SearchTasksRequest.PullRequestID.
+func (c *SearchTasksCriteria) ResolvePullRequestID(ctx context.Context, fetcher PRStatusFetcher) (int64, error) {
+	if c == nil || c.Repository == nil || c.PullRequestNumber == nil {
+		return 0, fmt.Errorf("repository and pull request number are required to look up a pull request")
+	}
+	if err := c.Validate(); err != nil {
+		return 0, err
+	}
+	state, err := fetcher.FetchPRState(ctx, *c.Repository, *c.PullRequestNumber)
+	if err != nil {
+		return 0, fmt.Errorf("unable to look up pull request %s#%d: %w", *c.Repository, *c.PullRequestNumber, err)
+	}
+	id, err := strconv.ParseInt(state.ID, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("pull request %s#%d has no id", *c.Repository, *c.PullRequestNumber)
+	}
+	return id, nil
+}
+
+// matches reports whether a task's repo satisfies the criteria.
+func (c *SearchTasksCriteria) matches(repo string, info *RepoInfo) bool {
+	if c.IsEmpty() {
+		return true
+	}
+	switch {
+	case c.Repository != nil && !strings.EqualFold(repo, *c.Repository):
+		return false
+	case c.Branch != nil && info.FeatureBranch != *c.Branch:
+		return false
+	case c.PullRequestNumber != nil && !equalPtr(info.PRNumber, *c.PullRequestNumber):
+		return false
+	}
+	return true
+}
+
 // SearchTasksRequest is the request payload for SearchTasks.
 type SearchTasksRequest struct {
 	FeatureFlags
@@ -116,6 +217,9 @@ type SearchTasksRequest struct {
 	PullRequestID *int64         `json:"-"`
 	TaskID        *string        `json:"-"`
 	Body          map[string]any `json:"-"`
+
+	// Criteria, if set, filters the tasks found by PullRequestID or TaskID. It is not sent to the service.
+	Criteria *SearchTasksCriteria `json:"-"`
 }
 
 // GetField retrieves the value of a field by name.
@@ -136,6 +240,22 @@ func (r *SearchTasksRequest) GetField(name string) (any, bool) {
 	}
 }
 
+// TaskSearchMatch identifies the repo of a task that matched a search.
+type TaskSearchMatch struct {
+	TaskID     string    `json:"TaskId"`
+	Repository string    `json:"Repository"`
+	RepoInfo   *RepoInfo `json:"RepoInfo"`
+}
+
+// SearchTasksResponse is the response from SearchTasksWithMatches.
+type SearchTasksResponse struct {
+	ListTasksResponse
+
+	// Matches lists, for each task, the repos whose pull request matched the search. It is computed by the SDK from
+	// the tasks' RepoInfo.
+	Matches []TaskSearchMatch `json:"Matches"`
+}
+
 // GetTask retrieves a task by ID.
 // nolint:dupl
 func (c *Client) GetTask(ctx context.Context, req *GetTaskRequest) (*Task, error) {
@@ -256,8 +376,18 @@ func (c *Client) GetTaskGithubCreds(ctx context.Context, req *GetTaskGithubCreds
 	return &out, nil
 }
 
-// SearchTasks performs an admin-scoped task search.
+// SearchTasks performs an admin-scoped task search. Tasks that don't match the request's Criteria are removed from
+// the response.
 func (c *Client) SearchTasks(ctx context.Context, req *SearchTasksRequest) (*ListTasksResponse, error) {
+	out, err := c.SearchTasksWithMatches(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &out.ListTasksResponse, nil
+}
+
+// SearchTasksWithMatches performs the same search as SearchTasks, and also reports which repos of each task matched.
+func (c *Client) SearchTasksWithMatches(ctx context.Context, req *SearchTasksRequest) (*SearchTasksResponse, error) {
 	if req == nil {
 		return nil, fmt.Errorf("req is nil")
 	}
@@ -272,6 +402,9 @@ func (c *Client) SearchTasks(ctx context.Context, req *SearchTasksRequest) (*Lis
 			return nil, fmt.Errorf("task id must be a valid uuid")
 		}
 	}
+	if err := req.Criteria.Validate(); err != nil {
+		return nil, err
+	}
 
 	u := c.BaseURL.JoinPath("v1", "tasks", "search")
 	q := u.Query()
@@ -315,13 +448,38 @@ func (c *Client) SearchTasks(ctx context.Context, req *SearchTasksRequest) (*Lis
 		return nil, decodeError(resp)
 	}
 
-	var out ListTasksResponse
+	var out SearchTasksResponse
 	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
 		return nil, err
 	}
+	out.match(req)
 	return &out, nil
 }
 
+// match drops the tasks that don't match the request's criteria, and records the repos that matched.
+func (r *SearchTasksResponse) match(req *SearchTasksRequest) {
+	tasks := r.Tasks[:0]
+	r.Matches = nil
+	for _, task := range r.Tasks {
+		matched := false
+		for _, repo := range slices.Sorted(maps.Keys(task.RepoInfo)) {
+			info := task.RepoInfo[repo]
+			if info == nil || !req.Criteria.matches(repo, info) {
+				continue
+			}
+			if req.PullRequestID != nil && !equalPtr(info.PRID, strconv.FormatInt(*req.PullRequestID, 10)) {
+				continue
+			}
+			matched = true
+			r.Matches = append(r.Matches, TaskSearchMatch{TaskID: task.TaskID, Repository: repo, RepoInfo: info})
+		}
+		if matched || req.Criteria.IsEmpty() {
+			tasks = append(tasks, task)
+		}
+	}
+	r.Tasks = tasks
+}
+
 // GetWorkstreamTaskRequest is the request payload for GetWorkstreamTask.
 type GetWorkstreamTaskRequest struct {
 	FeatureFlags
@@ -797,6 +955,11 @@ type UpdateWorkstreamTaskRequest struct {
 	BeforeTaskID       *string               `json:"BeforeTaskId,omitempty"`
 	AfterTaskID        *string               `json:"AfterTaskId,omitempty"`
 	Deleted            *bool                 `json:"Deleted,omitempty"`
+
+	// CurrentState is the task's state before the update. If set along with State, UpdateWorkstreamTask checks the
+	// transition against TaskLifecycle and fails with an *InvalidTransitionError, without sending the request, if it
+	// is illegal. It is not sent to the service.
+	CurrentState *TaskState `json:"-"`
 }
 
 // GetField retrieves the value of a field by name.
@@ -855,6 +1018,11 @@ func (c *Client) UpdateWorkstreamTask(ctx context.Context, req *UpdateWorkstream
 	if req.TaskID == "" {
 		return nil, fmt.Errorf("task id is required")
 	}
+	if req.CurrentState != nil && req.State != nil {
+		if err := TaskLifecycle.Validate(*req.CurrentState, *req.State); err != nil {
+			return nil, err
+		}
+	}
 
 	bodyBytes, err := json.Marshal(req)
 	if err != nil {
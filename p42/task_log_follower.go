@@ -0,0 +1,292 @@
+package p42
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/plan42-ai/concurrency"
+	"github.com/plan42-ai/sdk-go/internal/util"
+)
+
+// TaskTurnLog is a TurnLog tagged with the index of the turn it belongs to.
+type TaskTurnLog struct {
+	TurnIndex int `json:"TurnIndex"`
+	TurnLog
+}
+
+// TaskLogFollowerConfig holds configuration for TaskLogFollower.
+type TaskLogFollowerConfig struct {
+	Client   *Client
+	TenantID string
+	TaskID   string
+
+	// StartTurnIndex is the first turn to stream logs for. If nil, the follower starts at the task's first turn, as
+	// reported by ListTurns, waiting for it to be created if the task has no turns yet.
+	StartTurnIndex *int
+
+	IncludeDeleted bool
+	FeatureFlags   map[string]bool
+	DelegatedAuth  DelegatedAuthInfo
+
+	// PollInterval is how often the task is checked for a new turn, once the current turn's logs have ended.
+	// Defaults to 2 seconds.
+	PollInterval time.Duration
+
+	// Buffer is the size of the Logs channel.
+	Buffer int
+}
+
+// TaskLogFollower streams the logs of a task across turns. It streams the logs of one turn until they end, then
+// waits for the next turn to be created and moves on to it. It stops once the task is completed and the logs of its
+// last turn have been streamed.
+type TaskLogFollower struct {
+	cg             *concurrency.ContextGroup
+	client         *Client
+	tenantID       string
+	taskID         string
+	turnIndex      int
+	startTurnKnown bool
+	includeDeleted bool
+	featureFlags   map[string]bool
+	delegatedAuth  DelegatedAuthInfo
+	pollInterval   time.Duration
+	logs           chan TaskTurnLog
+	stopAfterFunc  func() bool
+
+	errMu sync.Mutex
+	err   error
+}
+
+const defaultPollInterval = 2 * time.Second
+
+// NewTaskLogFollower creates and starts a TaskLogFollower. The follower stops when ctx is done, in which case Err
+// returns the context's error.
+func NewTaskLogFollower(ctx context.Context, cfg *TaskLogFollowerConfig) *TaskLogFollower {
+	if cfg == nil {
+		cfg = &TaskLogFollowerConfig{}
+	}
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+
+	f := &TaskLogFollower{
+		cg:             concurrency.NewContextGroup(),
+		client:         cfg.Client,
+		tenantID:       cfg.TenantID,
+		taskID:         cfg.TaskID,
+		startTurnKnown: cfg.StartTurnIndex != nil,
+		includeDeleted: cfg.IncludeDeleted,
+		featureFlags:   cfg.FeatureFlags,
+		delegatedAuth:  cfg.DelegatedAuth,
+		pollInterval:   cfg.PollInterval,
+		logs:           make(chan TaskTurnLog, cfg.Buffer),
+	}
+	if cfg.StartTurnIndex != nil {
+		f.turnIndex = *cfg.StartTurnIndex
+	}
+	f.stopAfterFunc = context.AfterFunc(
+		ctx, func() {
+			f.setErr(ctx.Err())
+			f.cg.Cancel()
+		},
+	)
+
+	f.cg.Add(1)
+	go f.run()
+	return f
+}
+
+// Logs returns a channel that emits the logs of each turn, in turn order. It is closed when the follower stops.
+func (f *TaskLogFollower) Logs() <-chan TaskTurnLog { return f.logs }
+
+// Close cancels the follower and waits for shutdown.
+func (f *TaskLogFollower) Close() error { return f.cg.Close() }
+
+// ShutdownContext waits for the follower to finish with a context.
+func (f *TaskLogFollower) ShutdownContext(ctx context.Context) error { return f.cg.WaitContext(ctx) }
+
+// ShutdownTimeout waits for the follower to finish with a timeout.
+func (f *TaskLogFollower) ShutdownTimeout(d time.Duration) error { return f.cg.WaitTimeout(d) }
+
+// Err returns the error that stopped the follower. It returns nil if the follower stopped because the task completed,
+// or because it was stopped with Close.
+func (f *TaskLogFollower) Err() error {
+	f.errMu.Lock()
+	defer f.errMu.Unlock()
+	return f.err
+}
+
+func (f *TaskLogFollower) setErr(err error) {
+	f.errMu.Lock()
+	defer f.errMu.Unlock()
+	if f.err == nil {
+		f.err = err
+	}
+}
+
+func (f *TaskLogFollower) run() {
+	defer f.cg.Done()
+	defer f.cg.Cancel()
+	defer close(f.logs)
+	defer f.stopAfterFunc()
+
+	if !f.startTurnKnown && !f.waitForFirstTurn() {
+		return
+	}
+	for {
+		if !f.streamTurn() {
+			return
+		}
+		if !f.waitForNextTurn() {
+			return
+		}
+		f.turnIndex++
+	}
+}
+
+// streamTurn forwards the logs of the current turn until they end. It returns false if the follower should stop.
+func (f *TaskLogFollower) streamTurn() bool {
+	ctx := f.cg.Context()
+	ls := NewLogStreamContext(
+		ctx,
+		f.client,
+		f.tenantID,
+		f.taskID,
+		f.turnIndex,
+		cap(f.logs),
+		WithIncludeDeleted(f.includeDeleted),
+		WithFeatureFlags(f.featureFlags),
+		WithDelegatedAuth(f.delegatedAuth),
+	)
+	defer ls.Close()
+
+	for log := range ls.Logs() {
+		select {
+		case f.logs <- TaskTurnLog{TurnIndex: f.turnIndex, TurnLog: log}:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	if err := ls.Err(); err != nil {
+		f.setErr(err)
+		return false
+	}
+	return ctx.Err() == nil
+}
+
+// waitForFirstTurn sets the current turn to the task's first turn, polling the task until it has one. It returns false
+// if the follower should stop, either because the task is completed without turns or because of an error.
+func (f *TaskLogFollower) waitForFirstTurn() bool {
+	return f.pollTask(
+		func(task *Task) (bool, error) {
+			if task != nil && task.LastTurnIndex == nil {
+				return false, nil
+			}
+			first, found, err := f.firstTurnIndex()
+			if err != nil || !found {
+				return false, err
+			}
+			f.turnIndex = first
+			return true, nil
+		},
+	)
+}
+
+// firstTurnIndex returns the lowest turn index of the task. found is false if the task has no turns.
+func (f *TaskLogFollower) firstTurnIndex() (index int, found bool, err error) {
+	req := &ListTurnsRequest{
+		FeatureFlags:      FeatureFlags{FeatureFlags: f.featureFlags},
+		DelegatedAuthInfo: f.delegatedAuth,
+		TenantID:          f.tenantID,
+		TaskID:            f.taskID,
+		IncludeDeleted:    util.Pointer(f.includeDeleted),
+	}
+	for {
+		resp, err := f.client.ListTurns(f.cg.Context(), req)
+		if err != nil {
+			return 0, false, err
+		}
+		for _, turn := range resp.Turns {
+			if !found || turn.TurnIndex < index {
+				index = turn.TurnIndex
+				found = true
+			}
+		}
+		if resp.NextToken == nil {
+			return index, found, nil
+		}
+		req.Token = resp.NextToken
+	}
+}
+
+// waitForNextTurn polls the task until a turn after the current one exists. It returns false if the follower should
+// stop, either because the task is completed or because of an error.
+func (f *TaskLogFollower) waitForNextTurn() bool {
+	return f.pollTask(
+		func(task *Task) (bool, error) {
+			return task != nil && task.LastTurnIndex != nil && *task.LastTurnIndex > f.turnIndex, nil
+		},
+	)
+}
+
+// pollTask calls ready until it returns true, and returns true. ready is first called with a nil task, so that it can
+// check for what it is waiting for without fetching the task. After that, the task is fetched every poll interval,
+// and passed to ready. pollTask returns false if the follower should stop, either because the task is completed or
+// because of an error. Transient errors, from fetching the task or from ready, are retried with backoff.
+func (f *TaskLogFollower) pollTask(ready func(task *Task) (bool, error)) bool {
+	ctx := f.cg.Context()
+	backoff := util.NewBackoff(f.pollInterval, 30*f.pollInterval)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	var task *Task
+	first := true
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-timer.C:
+		}
+
+		var err error
+		if !first {
+			task, err = f.client.GetTask(
+				ctx, &GetTaskRequest{
+					FeatureFlags:      FeatureFlags{FeatureFlags: f.featureFlags},
+					DelegatedAuthInfo: f.delegatedAuth,
+					TenantID:          f.tenantID,
+					TaskID:            f.taskID,
+					IncludeDeleted:    util.Pointer(f.includeDeleted),
+				},
+			)
+		}
+		ok := false
+		if err == nil {
+			ok, err = ready(task)
+		}
+		switch {
+		case err != nil && !isRetryableError(err):
+			f.setErr(err)
+			return false
+		case err != nil:
+			backoff.Backoff()
+			timer.Reset(backoff.Current())
+			continue
+		}
+		backoff.Recover()
+		first = false
+
+		if ok {
+			return true
+		}
+		if task == nil {
+			timer.Reset(0)
+			continue
+		}
+		if task.State == TaskStateCompleted {
+			return false
+		}
+		timer.Reset(f.pollInterval)
+	}
+}
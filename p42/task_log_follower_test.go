@@ -0,0 +1,207 @@
+package p42_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/plan42-ai/sdk-go/internal/util"
+	"github.com/plan42-ai/sdk-go/p42"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTaskServer serves turn logs and task state for TaskLogFollower tests. Each turn's logs are served once, after
+// which the stream for that turn ends with 204. Each GetTask call returns the next entry of tasks, repeating the last.
+// ListTurns returns the turns of turnLogs up to the LastTurnIndex of the next entry of tasks.
+type fakeTaskServer struct {
+	mu       sync.Mutex
+	turnLogs map[string][]string
+	streamed map[string]bool
+	tasks    []p42.Task
+}
+
+func (s *fakeTaskServer) handler(t *testing.T) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(
+		"GET /v1/tenants/ten/tasks/task/turns/{turn}/logs", func(w http.ResponseWriter, r *http.Request) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			turn := r.PathValue("turn")
+			if s.streamed[turn] {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			s.streamed[turn] = true
+			w.Header().Set("Content-Type", "text/event-stream")
+			for i, msg := range s.turnLogs[turn] {
+				fmt.Fprintf(
+					w,
+					"id: %d\nevent: log\ndata: {\"Timestamp\":\"2025-01-01T00:00:00Z\",\"Message\":%q}\n\n",
+					i+1,
+					msg,
+				)
+			}
+		},
+	)
+	mux.HandleFunc(
+		"GET /v1/tenants/ten/tasks/task/turns", func(w http.ResponseWriter, _ *http.Request) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			var resp p42.ListTurnsResponse
+			if last := s.tasks[0].LastTurnIndex; last != nil {
+				for turn := range s.turnLogs {
+					if index, _ := strconv.Atoi(turn); index <= *last {
+						resp.Turns = append(resp.Turns, p42.Turn{TaskID: "task", TurnIndex: index})
+					}
+				}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			require.NoError(t, json.NewEncoder(w).Encode(resp))
+		},
+	)
+	mux.HandleFunc(
+		"GET /v1/tenants/ten/tasks/task", func(w http.ResponseWriter, _ *http.Request) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			task := s.tasks[0]
+			if len(s.tasks) > 1 {
+				s.tasks = s.tasks[1:]
+			}
+			w.Header().Set("Content-Type", "application/json")
+			require.NoError(t, json.NewEncoder(w).Encode(task))
+		},
+	)
+	return mux
+}
+
+func TestTaskLogFollower(t *testing.T) {
+	t.Parallel()
+	fake := &fakeTaskServer{
+		turnLogs: map[string][]string{"1": {"a", "b"}, "2": {"c"}, "3": {"d"}},
+		streamed: map[string]bool{},
+		tasks: []p42.Task{
+			{TaskID: "task", LastTurnIndex: util.Pointer(1), State: p42.TaskStateExecuting},
+			{TaskID: "task", LastTurnIndex: util.Pointer(2), State: p42.TaskStateExecuting},
+			{TaskID: "task", LastTurnIndex: util.Pointer(2), State: p42.TaskStateCompleted},
+		},
+	}
+	srv := httptest.NewServer(fake.handler(t))
+	defer srv.Close()
+
+	f := p42.NewTaskLogFollower(
+		context.Background(),
+		&p42.TaskLogFollowerConfig{
+			Client:       p42.NewClient(srv.URL),
+			TenantID:     "ten",
+			TaskID:       "task",
+			PollInterval: time.Millisecond,
+		},
+	)
+
+	var got []string
+	for log := range f.Logs() {
+		got = append(got, fmt.Sprintf("%d:%s", log.TurnIndex, log.Message))
+	}
+	require.NoError(t, f.ShutdownTimeout(2*time.Second))
+	require.NoError(t, f.Err())
+	require.Equal(t, []string{"1:a", "1:b", "2:c"}, got)
+}
+
+func TestTaskLogFollowerStartTurn(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		start    int
+		expected []string
+	}{
+		{0, []string{"0:a", "1:b", "2:c"}},
+		{2, []string{"2:c"}},
+	}
+	for _, tc := range tests {
+		fake := &fakeTaskServer{
+			turnLogs: map[string][]string{"0": {"a"}, "1": {"b"}, "2": {"c"}},
+			streamed: map[string]bool{},
+			tasks:    []p42.Task{{TaskID: "task", LastTurnIndex: util.Pointer(2), State: p42.TaskStateCompleted}},
+		}
+		srv := httptest.NewServer(fake.handler(t))
+
+		f := p42.NewTaskLogFollower(
+			context.Background(),
+			&p42.TaskLogFollowerConfig{
+				Client:         p42.NewClient(srv.URL),
+				TenantID:       "ten",
+				TaskID:         "task",
+				StartTurnIndex: util.Pointer(tc.start),
+				PollInterval:   time.Millisecond,
+			},
+		)
+
+		var got []string
+		for log := range f.Logs() {
+			got = append(got, fmt.Sprintf("%d:%s", log.TurnIndex, log.Message))
+		}
+		require.NoError(t, f.Err())
+		require.Equal(t, tc.expected, got)
+		srv.Close()
+	}
+}
+
+func TestTaskLogFollowerWaitsForFirstTurn(t *testing.T) {
+	t.Parallel()
+	fake := &fakeTaskServer{
+		turnLogs: map[string][]string{"0": {"a"}, "1": {"b"}},
+		streamed: map[string]bool{},
+		tasks: []p42.Task{
+			{TaskID: "task", State: p42.TaskStatePending},
+			{TaskID: "task", LastTurnIndex: util.Pointer(1), State: p42.TaskStateCompleted},
+		},
+	}
+	srv := httptest.NewServer(fake.handler(t))
+	defer srv.Close()
+
+	// The first turn is taken from ListTurns, once the task has one.
+	f := p42.NewTaskLogFollower(
+		context.Background(),
+		&p42.TaskLogFollowerConfig{
+			Client:       p42.NewClient(srv.URL),
+			TenantID:     "ten",
+			TaskID:       "task",
+			PollInterval: time.Millisecond,
+		},
+	)
+
+	var got []string
+	for log := range f.Logs() {
+		got = append(got, fmt.Sprintf("%d:%s", log.TurnIndex, log.Message))
+	}
+	require.NoError(t, f.Err())
+	require.Equal(t, []string{"0:a", "1:b"}, got)
+}
+
+func TestTaskLogFollowerTaskNotFound(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+				_ = json.NewEncoder(w).Encode(p42.Error{ResponseCode: http.StatusNotFound, Message: "not found"})
+			},
+		),
+	)
+	defer srv.Close()
+
+	f := p42.NewTaskLogFollower(
+		context.Background(),
+		&p42.TaskLogFollowerConfig{Client: p42.NewClient(srv.URL), TenantID: "ten", TaskID: "task"},
+	)
+	for range f.Logs() {
+	}
+	var apiErr *p42.Error
+	require.ErrorAs(t, f.Err(), &apiErr)
+	require.Equal(t, http.StatusNotFound, apiErr.ResponseCode)
+}
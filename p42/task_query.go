@@ -0,0 +1,435 @@
+package p42
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/plan42-ai/sdk-go/internal/util"
+)
+
+// TaskQuery selects tasks by their fields. Every condition that is set must match. Conditions that take a list match
+// if the task matches any of the values. Build one with NewTaskQuery or ParseTaskFilter, and run it with QueryTasks.
+type TaskQuery struct {
+	States             []TaskState  `json:"States,omitempty"`
+	Models             []ModelType  `json:"Models,omitempty"`
+	AssignedToAI       *bool        `json:"AssignedToAI,omitempty"`
+	AssignedToTenantID *string      `json:"AssignedToTenantId,omitempty"`
+	WorkstreamID       *string      `json:"WorkstreamId,omitempty"`
+	LastTurnStatuses   []TurnStatus `json:"LastTurnStatuses,omitempty"`
+	CreatedAfter       *time.Time   `json:"CreatedAfter,omitempty"`
+	CreatedBefore      *time.Time   `json:"CreatedBefore,omitempty"`
+	UpdatedAfter       *time.Time   `json:"UpdatedAfter,omitempty"`
+	UpdatedBefore      *time.Time   `json:"UpdatedBefore,omitempty"`
+
+	// PRStatuses matches tasks with a pull request in one of the statuses, in any repo or in PRRepo if it is set.
+	PRStatuses []string `json:"PRStatuses,omitempty"`
+	PRRepo     *string  `json:"PRRepo,omitempty"`
+
+	// TaskID and PullRequestID are answered by SearchTasks rather than by listing every task.
+	TaskID        *string `json:"TaskId,omitempty"`
+	PullRequestID *int64  `json:"PullRequestId,omitempty"`
+
+	IncludeDeleted bool `json:"IncludeDeleted,omitempty"`
+}
+
+// NewTaskQuery returns an empty query, which matches every task that isn't deleted.
+func NewTaskQuery() *TaskQuery {
+	return &TaskQuery{}
+}
+
+// WithStates adds to the states a task may be in.
+func (q *TaskQuery) WithStates(states ...TaskState) *TaskQuery {
+	q.States = append(q.States, states...)
+	return q
+}
+
+// WithModels adds to the models a task may use.
+func (q *TaskQuery) WithModels(models ...ModelType) *TaskQuery {
+	q.Models = append(q.Models, models...)
+	return q
+}
+
+// WithAssignedToAI matches tasks that are, or are not, assigned to the AI.
+func (q *TaskQuery) WithAssignedToAI(assigned bool) *TaskQuery {
+	q.AssignedToAI = util.Pointer(assigned)
+	return q
+}
+
+// WithAssignedToTenantID matches tasks assigned to a tenant.
+func (q *TaskQuery) WithAssignedToTenantID(tenantID string) *TaskQuery {
+	q.AssignedToTenantID = util.Pointer(tenantID)
+	return q
+}
+
+// WithWorkstreamID matches the tasks of a workstream.
+func (q *TaskQuery) WithWorkstreamID(workstreamID string) *TaskQuery {
+	q.WorkstreamID = util.Pointer(workstreamID)
+	return q
+}
+
+// WithLastTurnStatuses adds to the statuses a task's last turn may have.
+func (q *TaskQuery) WithLastTurnStatuses(statuses ...TurnStatus) *TaskQuery {
+	q.LastTurnStatuses = append(q.LastTurnStatuses, statuses...)
+	return q
+}
+
+// WithCreatedBetween matches tasks created in [after, before). A zero time leaves that end of the range open.
+func (q *TaskQuery) WithCreatedBetween(after, before time.Time) *TaskQuery {
+	q.CreatedAfter, q.CreatedBefore = timeBound(after), timeBound(before)
+	return q
+}
+
+// WithUpdatedBetween matches tasks last updated in [after, before). A zero time leaves that end of the range open.
+func (q *TaskQuery) WithUpdatedBetween(after, before time.Time) *TaskQuery {
+	q.UpdatedAfter, q.UpdatedBefore = timeBound(after), timeBound(before)
+	return q
+}
+
+// WithPRStatuses adds to the pull request statuses a task may have.
+func (q *TaskQuery) WithPRStatuses(statuses ...string) *TaskQuery {
+	q.PRStatuses = append(q.PRStatuses, statuses...)
+	return q
+}
+
+// WithPRRepo limits WithPRStatuses to the pull request for a repo.
+func (q *TaskQuery) WithPRRepo(repo string) *TaskQuery {
+	q.PRRepo = util.Pointer(repo)
+	return q
+}
+
+// WithTaskID matches a single task.
+func (q *TaskQuery) WithTaskID(taskID string) *TaskQuery {
+	q.TaskID = util.Pointer(taskID)
+	return q
+}
+
+// WithPullRequestID matches the tasks associated with a GitHub pull request.
+func (q *TaskQuery) WithPullRequestID(pullRequestID int64) *TaskQuery {
+	q.PullRequestID = util.Pointer(pullRequestID)
+	return q
+}
+
+// WithIncludeDeleted matches deleted tasks as well.
+func (q *TaskQuery) WithIncludeDeleted(include bool) *TaskQuery {
+	q.IncludeDeleted = include
+	return q
+}
+
+func timeBound(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// Matches reports whether a task satisfies every condition of the query.
+func (q *TaskQuery) Matches(task *Task) bool {
+	switch {
+	case task.Deleted && !q.IncludeDeleted:
+		return false
+	case len(q.States) > 0 && !slices.Contains(q.States, task.State):
+		return false
+	case len(q.Models) > 0 && (task.Model == nil || !slices.Contains(q.Models, *task.Model)):
+		return false
+	case q.AssignedToAI != nil && task.AssignedToAI != *q.AssignedToAI:
+		return false
+	case q.AssignedToTenantID != nil && !equalPtr(task.AssignedToTenantID, *q.AssignedToTenantID):
+		return false
+	case q.WorkstreamID != nil && !equalPtr(task.WorkstreamID, *q.WorkstreamID):
+		return false
+	case len(q.LastTurnStatuses) > 0 &&
+		(task.LastTurnStatus == nil || !slices.Contains(q.LastTurnStatuses, *task.LastTurnStatus)):
+		return false
+	case !inRange(task.CreatedAt, q.CreatedAfter, q.CreatedBefore):
+		return false
+	case !inRange(task.UpdatedAt, q.UpdatedAfter, q.UpdatedBefore):
+		return false
+	case q.TaskID != nil && task.TaskID != *q.TaskID:
+		return false
+	}
+	return q.matchesRepos(task)
+}
+
+func (q *TaskQuery) matchesRepos(task *Task) bool {
+	if len(q.PRStatuses) == 0 && q.PullRequestID == nil {
+		return true
+	}
+	for repo, info := range task.RepoInfo {
+		if info == nil || (q.PRRepo != nil && repo != *q.PRRepo) {
+			continue
+		}
+		if len(q.PRStatuses) > 0 && (info.PRStatus == nil || !slices.Contains(q.PRStatuses, *info.PRStatus)) {
+			continue
+		}
+		if q.PullRequestID != nil && !equalPtr(info.PRID, strconv.FormatInt(*q.PullRequestID, 10)) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func equalPtr[T comparable](p *T, v T) bool {
+	return p != nil && *p == v
+}
+
+func inRange(t time.Time, after, before *time.Time) bool {
+	return (after == nil || !t.Before(*after)) && (before == nil || t.Before(*before))
+}
+
+// ParseTaskFilter parses a filter expression into a TaskQuery. An expression is a comma separated list of conditions,
+// all of which must match. A condition is "field=value", where value may list alternatives separated by "|", or
+// "field>time" / "field<time" for createdAt and updatedAt. Times are RFC 3339 timestamps or dates (YYYY-MM-DD).
+// Field names are case-insensitive. For example:
+//
+//	state=Pending|Executing,model=O3,assignedToAI=true,createdAt>2025-01-01,prStatus=open
+//
+// The supported fields are state, model, assignedToAI, assignedToTenantId, workstreamId, lastTurnStatus, createdAt,
+// updatedAt, prStatus, prRepo, taskId, pullRequestId and includeDeleted.
+func ParseTaskFilter(filter string) (*TaskQuery, error) {
+	q := NewTaskQuery()
+	for term := range strings.SplitSeq(filter, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		i := strings.IndexAny(term, "=<>")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid filter condition %q: expected field=value", term)
+		}
+		field, op, value := strings.TrimSpace(term[:i]), term[i], strings.TrimSpace(term[i+1:])
+		if value == "" {
+			return nil, fmt.Errorf("invalid filter condition %q: missing value", term)
+		}
+		if err := q.parseCondition(strings.ToLower(field), op, value); err != nil {
+			return nil, fmt.Errorf("invalid filter condition %q: %w", term, err)
+		}
+	}
+	return q, nil
+}
+
+func (q *TaskQuery) parseCondition(field string, op byte, value string) error {
+	switch field {
+	case "createdat", "updatedat":
+		return q.parseTimeCondition(field, op, value)
+	}
+	if op != '=' {
+		return fmt.Errorf("field %s only supports =", field)
+	}
+	values := strings.Split(value, "|")
+	switch field {
+	case "state":
+		q.WithStates(convertStrings[TaskState](values)...)
+	case "model":
+		q.WithModels(convertStrings[ModelType](values)...)
+	case "lastturnstatus":
+		q.WithLastTurnStatuses(convertStrings[TurnStatus](values)...)
+	case "prstatus":
+		q.WithPRStatuses(values...)
+	case "prrepo":
+		q.WithPRRepo(value)
+	case "assignedtotenantid":
+		q.WithAssignedToTenantID(value)
+	case "workstreamid":
+		q.WithWorkstreamID(value)
+	case "taskid":
+		q.WithTaskID(value)
+	case "assignedtoai", "includedeleted":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		if field == "assignedtoai" {
+			q.WithAssignedToAI(b)
+		} else {
+			q.WithIncludeDeleted(b)
+		}
+	case "pullrequestid":
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		q.WithPullRequestID(id)
+	default:
+		return fmt.Errorf("unknown field %s", field)
+	}
+	return nil
+}
+
+func (q *TaskQuery) parseTimeCondition(field string, op byte, value string) error {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t, err = time.Parse(time.DateOnly, value)
+	}
+	if err != nil {
+		return fmt.Errorf("expected an RFC 3339 timestamp or a date: %w", err)
+	}
+
+	var bound **time.Time
+	switch {
+	case field == "createdat" && op == '>':
+		bound = &q.CreatedAfter
+	case field == "createdat" && op == '<':
+		bound = &q.CreatedBefore
+	case field == "updatedat" && op == '>':
+		bound = &q.UpdatedAfter
+	case field == "updatedat" && op == '<':
+		bound = &q.UpdatedBefore
+	default:
+		return fmt.Errorf("field %s only supports > and <", field)
+	}
+	*bound = &t
+	return nil
+}
+
+func convertStrings[S ~string](values []string) []S {
+	out := make([]S, len(values))
+	for i, v := range values {
+		out[i] = S(v)
+	}
+	return out
+}
+
+// QueryTasksRequest is the request for QueryTasks.
+type QueryTasksRequest struct {
+	FeatureFlags
+	DelegatedAuthInfo
+	TenantID string
+
+	// Query selects the tasks to return. If nil, every task that isn't deleted is returned.
+	Query *TaskQuery
+
+	// PageSize is the number of tasks to request per page. If zero, the service default is used.
+	PageSize int
+}
+
+// QueryTasks returns the tenant's tasks that match a query. Queries on TaskID or PullRequestID are sent to SearchTasks.
+// Other queries page through ListTasks, or ListWorkstreamTasks if the query has a WorkstreamID, and filter the tasks
+// client-side. In both cases every condition of the query is checked against the returned tasks.
+//
+// Tasks are fetched lazily as the sequence is iterated. If a request fails, the error is yielded with a nil task and
+// iteration stops.
+func QueryTasks(ctx context.Context, client *Client, req *QueryTasksRequest) iter.Seq2[*Task, error] {
+	return func(yield func(*Task, error) bool) {
+		switch {
+		case req == nil:
+			yield(nil, fmt.Errorf("req is nil"))
+			return
+		case req.TenantID == "":
+			yield(nil, fmt.Errorf("tenant id is required"))
+			return
+		}
+		query := req.Query
+		if query == nil {
+			query = NewTaskQuery()
+		}
+
+		if query.TaskID != nil || query.PullRequestID != nil {
+			searchTasks(ctx, client, req, query, yield)
+			return
+		}
+		listTasks(ctx, client, req, query, yield)
+	}
+}
+
+func searchTasks(
+	ctx context.Context,
+	client *Client,
+	req *QueryTasksRequest,
+	query *TaskQuery,
+	yield func(*Task, error) bool,
+) {
+	resp, err := client.SearchTasks(
+		ctx, &SearchTasksRequest{
+			FeatureFlags:      req.FeatureFlags,
+			DelegatedAuthInfo: req.DelegatedAuthInfo,
+			PullRequestID:     query.PullRequestID,
+			TaskID:            query.TaskID,
+		},
+	)
+	if err != nil {
+		yield(nil, err)
+		return
+	}
+
+	// A task linked to several pull requests is returned once per pull request.
+	seen := make(map[string]bool)
+	for i := range resp.Tasks {
+		task := &resp.Tasks[i]
+		if task.TenantID != req.TenantID || seen[task.TaskID] || !query.Matches(task) {
+			continue
+		}
+		seen[task.TaskID] = true
+		if !yield(task, nil) {
+			return
+		}
+	}
+}
+
+func listTasks(
+	ctx context.Context,
+	client *Client,
+	req *QueryTasksRequest,
+	query *TaskQuery,
+	yield func(*Task, error) bool,
+) {
+	var maxResults *int
+	if req.PageSize > 0 {
+		maxResults = util.Pointer(req.PageSize)
+	}
+	var token *string
+
+	for {
+		var page []Task
+		var next *string
+		if query.WorkstreamID != nil {
+			resp, err := client.ListWorkstreamTasks(
+				ctx, &ListWorkstreamTasksRequest{
+					FeatureFlags:      req.FeatureFlags,
+					DelegatedAuthInfo: req.DelegatedAuthInfo,
+					TenantID:          req.TenantID,
+					WorkstreamID:      *query.WorkstreamID,
+					MaxResults:        maxResults,
+					Token:             token,
+					IncludeDeleted:    util.Pointer(query.IncludeDeleted),
+				},
+			)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			page, next = resp.Items, resp.NextToken
+		} else {
+			resp, err := client.ListTasks(
+				ctx, &ListTasksRequest{
+					FeatureFlags:      req.FeatureFlags,
+					DelegatedAuthInfo: req.DelegatedAuthInfo,
+					TenantID:          req.TenantID,
+					MaxResults:        maxResults,
+					Token:             token,
+					IncludeDeleted:    util.Pointer(query.IncludeDeleted),
+				},
+			)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			page, next = resp.Tasks, resp.NextToken
+		}
+
+		for i := range page {
+			if query.Matches(&page[i]) && !yield(&page[i], nil) {
+				return
+			}
+		}
+		if next == nil {
+			return
+		}
+		token = next
+	}
+}
@@ -0,0 +1,293 @@
+package p42_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/plan42-ai/sdk-go/internal/util"
+	"github.com/plan42-ai/sdk-go/p42"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTaskFilter(t *testing.T) {
+	t.Parallel()
+	q, err := p42.ParseTaskFilter(
+		"state=Pending|Executing, model=O3, assignedToAI=true, lastTurnStatus=Done, " +
+			"createdAt>2025-01-01, updatedAt<2025-02-01T12:00:00Z, prStatus=open, prRepo=org/repo, WorkstreamId=ws",
+	)
+	require.NoError(t, err)
+	expected := p42.NewTaskQuery().
+		WithStates(p42.TaskStatePending, p42.TaskStateExecuting).
+		WithModels(p42.ModelTypeO3).
+		WithAssignedToAI(true).
+		WithLastTurnStatuses(p42.TurnStatusDone).
+		WithCreatedBetween(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Time{}).
+		WithUpdatedBetween(time.Time{}, time.Date(2025, 2, 1, 12, 0, 0, 0, time.UTC)).
+		WithPRStatuses("open").
+		WithPRRepo("org/repo").
+		WithWorkstreamID("ws")
+	require.Equal(t, expected, q)
+
+	q, err = p42.ParseTaskFilter("")
+	require.NoError(t, err)
+	require.Equal(t, p42.NewTaskQuery(), q)
+
+	for filter, msg := range map[string]string{
+		"state":                  "expected field=value",
+		"model=":                 "missing value",
+		"color=red":              "unknown field color",
+		"state>Pending":          "field state only supports =",
+		"createdAt=2025-01-01":   "field createdat only supports > and <",
+		"createdAt>yesterday":    "expected an RFC 3339 timestamp or a date",
+		"assignedToAI=sometimes": "invalid syntax",
+	} {
+		_, err := p42.ParseTaskFilter(filter)
+		require.ErrorContains(t, err, msg, filter)
+	}
+}
+
+func TestTaskQueryMatches(t *testing.T) {
+	t.Parallel()
+	created := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	task := &p42.Task{
+		TaskID:         "a",
+		State:          p42.TaskStateExecuting,
+		Model:          util.Pointer(p42.ModelTypeO3),
+		WorkstreamID:   util.Pointer("ws"),
+		LastTurnStatus: util.Pointer(p42.TurnStatus("Running tests")),
+		CreatedAt:      created,
+		UpdatedAt:      created,
+		RepoInfo: map[string]*p42.RepoInfo{
+			"org/a": {PRStatus: util.Pointer("open"), PRID: util.Pointer("123")},
+			"org/b": {PRStatus: util.Pointer("merged")},
+		},
+	}
+	tests := []struct {
+		filter  string
+		matches bool
+	}{
+		{"", true},
+		{"state=Pending|Executing", true},
+		{"state=Pending", false},
+		{"model=O3,workstreamId=ws", true},
+		{"model=GPT-5", false},
+		{"assignedToAI=true", false},
+		{"assignedToTenantId=ten", false},
+		{"lastTurnStatus=Done", false},
+		{"createdAt>2025-01-15", true},
+		{"createdAt<2025-01-15", false},
+		{"updatedAt>2025-01-01,updatedAt<2025-02-01", true},
+		{"prStatus=merged", true},
+		{"prStatus=merged,prRepo=org/a", false},
+		{"pullRequestId=123", true},
+		{"pullRequestId=124", false},
+	}
+	for _, tc := range tests {
+		q, err := p42.ParseTaskFilter(tc.filter)
+		require.NoError(t, err)
+		require.Equal(t, tc.matches, q.Matches(task), tc.filter)
+	}
+
+	task.Deleted = true
+	require.False(t, p42.NewTaskQuery().Matches(task))
+	require.True(t, p42.NewTaskQuery().WithIncludeDeleted(true).Matches(task))
+}
+
+func TestQueryTasksListsAndFilters(t *testing.T) {
+	t.Parallel()
+	tasks := []p42.Task{
+		{TaskID: "a", State: p42.TaskStatePending},
+		{TaskID: "b", State: p42.TaskStateCompleted},
+		{TaskID: "c", State: p42.TaskStatePending},
+		{TaskID: "d", State: p42.TaskStatePending},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(
+		"GET /v1/tenants/ten/tasks", func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "false", r.URL.Query().Get("includeDeleted"))
+			require.Equal(t, "2", r.URL.Query().Get("maxResults"))
+			page := 0
+			if token := r.URL.Query().Get("token"); token != "" {
+				page, _ = strconv.Atoi(token)
+			}
+			resp := p42.ListTasksResponse{Tasks: tasks[page*2 : page*2+2]}
+			if page == 0 {
+				resp.NextToken = util.Pointer("1")
+			}
+			w.Header().Set("Content-Type", "application/json")
+			require.NoError(t, json.NewEncoder(w).Encode(resp))
+		},
+	)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	var ids []string
+	for task, err := range p42.QueryTasks(
+		context.Background(),
+		p42.NewClient(srv.URL),
+		&p42.QueryTasksRequest{
+			TenantID: "ten",
+			Query:    p42.NewTaskQuery().WithStates(p42.TaskStatePending),
+			PageSize: 2,
+		},
+	) {
+		require.NoError(t, err)
+		ids = append(ids, task.TaskID)
+		if len(ids) == 2 {
+			break
+		}
+	}
+	require.Equal(t, []string{"a", "c"}, ids)
+}
+
+func TestQueryTasksSearch(t *testing.T) {
+	t.Parallel()
+	taskID := uuid.NewString()
+	srv := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, "/v1/tasks/search", r.URL.Path)
+				require.Equal(t, taskID, r.URL.Query().Get("taskId"))
+				task := p42.Task{TenantID: "ten", TaskID: taskID, State: p42.TaskStatePending}
+				other := p42.Task{TenantID: "other", TaskID: taskID, State: p42.TaskStatePending}
+				w.Header().Set("Content-Type", "application/json")
+				require.NoError(t, json.NewEncoder(w).Encode(p42.ListTasksResponse{Tasks: []p42.Task{task, task, other}}))
+			},
+		),
+	)
+	defer srv.Close()
+
+	var found []*p42.Task
+	for task, err := range p42.QueryTasks(
+		context.Background(),
+		p42.NewClient(srv.URL),
+		&p42.QueryTasksRequest{TenantID: "ten", Query: p42.NewTaskQuery().WithTaskID(taskID)},
+	) {
+		require.NoError(t, err)
+		found = append(found, task)
+	}
+	require.Len(t, found, 1)
+	require.Equal(t, "ten", found[0].TenantID)
+}
+
+func TestQueryTasksError(t *testing.T) {
+	t.Parallel()
+	for task, err := range p42.QueryTasks(context.Background(), p42.NewClient("http://localhost"), &p42.QueryTasksRequest{}) {
+		require.Nil(t, task)
+		require.EqualError(t, err, "tenant id is required")
+	}
+}
+
+func TestParsePullRequestURL(t *testing.T) {
+	t.Parallel()
+	criteria, err := p42.ParsePullRequestURL("https://github.com/org/repo/pull/123")
+	require.NoError(t, err)
+	require.Equal(t, "org/repo", *criteria.Repository)
+	require.Equal(t, 123, *criteria.PullRequestNumber)
+
+	for _, link := range []string{"https://github.com/org/repo", "https://github.com/org/repo/issues/1", "org/repo/pull/1", "https://github.com/org/repo/pull/x"} {
+		_, err := p42.ParsePullRequestURL(link)
+		require.Error(t, err, link)
+	}
+}
+
+func TestSearchTasksCriteria(t *testing.T) {
+	t.Parallel()
+	matching := p42.Task{
+		TenantID: "ten",
+		TaskID:   "a",
+		RepoInfo: map[string]*p42.RepoInfo{
+			"org/other": {FeatureBranch: "feature", PRNumber: util.Pointer(7)},
+			"org/repo":  {FeatureBranch: "feature", PRNumber: util.Pointer(7), PRID: util.Pointer("42")},
+		},
+	}
+	other := p42.Task{
+		TenantID: "ten",
+		TaskID:   "b",
+		RepoInfo: map[string]*p42.RepoInfo{
+			"org/repo": {FeatureBranch: "main", PRNumber: util.Pointer(8), PRID: util.Pointer("42")},
+		},
+	}
+	srv := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				// Criteria are applied to the results, and the documented empty body is sent.
+				require.Equal(t, "42", r.URL.Query().Get("pullRequestId"))
+				var body map[string]any
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+				require.Empty(t, body)
+				w.Header().Set("Content-Type", "application/json")
+				require.NoError(t, json.NewEncoder(w).Encode(p42.ListTasksResponse{Tasks: []p42.Task{matching, other}}))
+			},
+		),
+	)
+	defer srv.Close()
+	client := p42.NewClient(srv.URL)
+
+	req := &p42.SearchTasksRequest{
+		PullRequestID: util.Pointer[int64](42),
+		Criteria:      &p42.SearchTasksCriteria{Repository: util.Pointer("org/repo"), Branch: util.Pointer("feature")},
+	}
+	resp, err := client.SearchTasksWithMatches(context.Background(), req)
+	require.NoError(t, err)
+	require.Len(t, resp.Tasks, 1)
+	require.Equal(t, "a", resp.Tasks[0].TaskID)
+	require.Equal(t, []p42.TaskSearchMatch{{TaskID: "a", Repository: "org/repo", RepoInfo: matching.RepoInfo["org/repo"]}}, resp.Matches)
+
+	tasks, err := client.SearchTasks(context.Background(), req)
+	require.NoError(t, err)
+	require.Len(t, tasks.Tasks, 1)
+
+	for criteria, msg := range map[*p42.SearchTasksCriteria]string{
+		{Branch: util.Pointer("feature")}:                                          "repository is required when searching by branch or pull request number",
+		{Repository: util.Pointer("repo")}:                                         "repository must be of the form owner/name",
+		{Repository: util.Pointer("org/repo"), PullRequestNumber: util.Pointer(0)}: "pull request number must be positive",
+	} {
+		_, err := client.SearchTasks(
+			context.Background(), &p42.SearchTasksRequest{PullRequestID: util.Pointer[int64](42), Criteria: criteria},
+		)
+		require.EqualError(t, err, msg)
+	}
+
+	// Criteria alone are not a search the service supports.
+	_, err = client.SearchTasks(
+		context.Background(), &p42.SearchTasksRequest{Criteria: &p42.SearchTasksCriteria{Repository: util.Pointer("org/repo")}},
+	)
+	require.EqualError(t, err, "either pull request id or task id is required")
+}
+
+type fakePRFetcher map[int]*p42.PRState
+
+func (f fakePRFetcher) FetchPRState(_ context.Context, repo string, number int) (*p42.PRState, error) {
+	if state, ok := f[number]; ok && repo == "org/repo" {
+		return state, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func TestSearchTasksCriteriaResolvePullRequestID(t *testing.T) {
+	t.Parallel()
+	fetcher := fakePRFetcher{7: {Status: p42.PRStatusOpen, ID: "42"}, 8: {Status: p42.PRStatusOpen}}
+
+	criteria, err := p42.ParsePullRequestURL("https://github.com/org/repo/pull/7")
+	require.NoError(t, err)
+	id, err := criteria.ResolvePullRequestID(context.Background(), fetcher)
+	require.NoError(t, err)
+	require.Equal(t, int64(42), id)
+
+	for criteria, msg := range map[*p42.SearchTasksCriteria]string{
+		{Repository: util.Pointer("org/repo")}:                                     "repository and pull request number are required to look up a pull request",
+		{Repository: util.Pointer("org/repo"), PullRequestNumber: util.Pointer(8)}: "pull request org/repo#8 has no id",
+		{Repository: util.Pointer("org/repo"), PullRequestNumber: util.Pointer(9)}: "unable to look up pull request org/repo#9: not found",
+	} {
+		_, err := criteria.ResolvePullRequestID(context.Background(), fetcher)
+		require.EqualError(t, err, msg)
+	}
+}
@@ -0,0 +1,361 @@
+package p42
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/plan42-ai/concurrency"
+	"github.com/plan42-ai/sdk-go/internal/util"
+)
+
+// TaskEventType identifies the kind of change a TaskEvent reports.
+type TaskEventType string
+
+const (
+	// TaskEventCreated reports a task that wasn't in the previous snapshot.
+	TaskEventCreated TaskEventType = "Created"
+
+	// TaskEventUpdated reports a task whose version changed. It is sent for every change, before any more specific
+	// events for the same change.
+	TaskEventUpdated TaskEventType = "Updated"
+
+	// TaskEventStateChanged reports a change to a task's State.
+	TaskEventStateChanged TaskEventType = "StateChanged"
+
+	// TaskEventPRStatusChanged reports a change to the PRStatus of one of a task's repos.
+	TaskEventPRStatusChanged TaskEventType = "PRStatusChanged"
+
+	// TaskEventDeleted reports a task that was deleted, or that is no longer listed.
+	TaskEventDeleted TaskEventType = "Deleted"
+)
+
+// TaskEvent is a change to a task, found by comparing successive snapshots.
+type TaskEvent struct {
+	Type   TaskEventType `json:"Type"`
+	TaskID string        `json:"TaskId"`
+
+	// Task is the task after the change. For a task that is no longer listed, it is the last version seen.
+	Task *Task `json:"Task"`
+
+	// Previous is the task before the change. It is nil for TaskEventCreated.
+	Previous *Task `json:"Previous,omitempty"`
+
+	// Repo is the key in the task's RepoInfo whose pull request status changed, for TaskEventPRStatusChanged.
+	Repo string `json:"Repo,omitempty"`
+}
+
+// TaskWatchCursor records the tasks a TaskWatcher has seen. Persist it (it marshals to JSON) and pass it back in
+// TaskWatcherConfig to resume watching without repeating events.
+type TaskWatchCursor struct {
+	Tasks map[string]*Task `json:"Tasks"`
+}
+
+// TaskWatcherConfig holds configuration for TaskWatcher.
+type TaskWatcherConfig struct {
+	Client   *Client
+	TenantID string
+
+	// WorkstreamID limits the watch to the tasks of a workstream. If nil, every task of the tenant is watched.
+	WorkstreamID *string
+
+	// IncludeDeleted lists deleted tasks, so that deleting a task is reported once, when its Deleted flag is set.
+	// Otherwise a deleted task is reported when it stops being listed.
+	IncludeDeleted bool
+	FeatureFlags   map[string]bool
+	DelegatedAuth  DelegatedAuthInfo
+
+	// Interval is how often tasks are listed. Defaults to 10 seconds.
+	Interval time.Duration
+
+	// PageSize is the number of tasks to request per page. If zero, the service default is used.
+	PageSize int
+
+	// Cursor resumes a previous watch. Changes since the cursor was taken are reported by the first snapshot.
+	Cursor *TaskWatchCursor
+
+	// EmitInitial reports every task in the first snapshot as created. It has no effect when resuming from a Cursor.
+	// Otherwise, the first snapshot only establishes the baseline.
+	EmitInitial bool
+
+	// Buffer is the size of the Events channel.
+	Buffer int
+}
+
+// TaskWatcher polls the tasks of a tenant or workstream and emits an event for each change between snapshots.
+type TaskWatcher struct {
+	cg            *concurrency.ContextGroup
+	client        *Client
+	cfg           TaskWatcherConfig
+	events        chan TaskEvent
+	stopAfterFunc func() bool
+
+	// primed is set once the baseline is established, so that changes are reported.
+	primed bool
+
+	mu    sync.Mutex
+	known map[string]*Task
+	err   error
+}
+
+const defaultTaskWatchInterval = 10 * time.Second
+
+// NewTaskWatcher creates and starts a TaskWatcher. The watcher stops when ctx is done, in which case Err returns the
+// context's error.
+func NewTaskWatcher(ctx context.Context, cfg *TaskWatcherConfig) *TaskWatcher {
+	if cfg == nil {
+		cfg = &TaskWatcherConfig{}
+	}
+	w := &TaskWatcher{
+		cg:     concurrency.NewContextGroup(),
+		client: cfg.Client,
+		cfg:    *cfg,
+		events: make(chan TaskEvent, cfg.Buffer),
+		known:  make(map[string]*Task),
+	}
+	if w.cfg.Interval <= 0 {
+		w.cfg.Interval = defaultTaskWatchInterval
+	}
+	if cfg.Cursor != nil {
+		maps.Copy(w.known, cfg.Cursor.Tasks)
+		w.primed = true
+	} else {
+		w.primed = cfg.EmitInitial
+	}
+	w.stopAfterFunc = context.AfterFunc(
+		ctx, func() {
+			w.setErr(ctx.Err())
+			w.cg.Cancel()
+		},
+	)
+
+	w.cg.Add(1)
+	go w.run()
+	return w
+}
+
+// Events returns a channel that emits task events. It is closed when the watcher stops.
+func (w *TaskWatcher) Events() <-chan TaskEvent { return w.events }
+
+// Close cancels the watcher and waits for shutdown.
+func (w *TaskWatcher) Close() error { return w.cg.Close() }
+
+// ShutdownContext waits for the watcher to finish with a context.
+func (w *TaskWatcher) ShutdownContext(ctx context.Context) error { return w.cg.WaitContext(ctx) }
+
+// ShutdownTimeout waits for the watcher to finish with a timeout.
+func (w *TaskWatcher) ShutdownTimeout(d time.Duration) error { return w.cg.WaitTimeout(d) }
+
+// Err returns the error that stopped the watcher, or nil if it was stopped with Close.
+func (w *TaskWatcher) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Cursor returns a cursor for the tasks whose events have been sent on the Events channel.
+func (w *TaskWatcher) Cursor() *TaskWatchCursor {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return &TaskWatchCursor{Tasks: maps.Clone(w.known)}
+}
+
+func (w *TaskWatcher) setErr(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+func (w *TaskWatcher) run() {
+	defer w.cg.Done()
+	defer w.cg.Cancel()
+	defer close(w.events)
+	defer w.stopAfterFunc()
+
+	ctx := w.cg.Context()
+	backoff := util.NewBackoff(w.cfg.Interval, 30*w.cfg.Interval)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		tasks, err := w.snapshot(ctx)
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return
+		case err != nil && !isRetryableError(err):
+			w.setErr(err)
+			return
+		case err != nil:
+			backoff.Backoff()
+			timer.Reset(backoff.Current())
+			continue
+		}
+		backoff.Recover()
+
+		if !w.apply(ctx, tasks) {
+			return
+		}
+		timer.Reset(w.cfg.Interval)
+	}
+}
+
+// snapshot lists every task being watched.
+func (w *TaskWatcher) snapshot(ctx context.Context) ([]Task, error) {
+	var maxResults *int
+	if w.cfg.PageSize > 0 {
+		maxResults = util.Pointer(w.cfg.PageSize)
+	}
+	flags := FeatureFlags{FeatureFlags: w.cfg.FeatureFlags}
+	var token *string
+	var tasks []Task
+
+	for {
+		var page []Task
+		var next *string
+		if w.cfg.WorkstreamID != nil {
+			resp, err := w.client.ListWorkstreamTasks(
+				ctx, &ListWorkstreamTasksRequest{
+					FeatureFlags:      flags,
+					DelegatedAuthInfo: w.cfg.DelegatedAuth,
+					TenantID:          w.cfg.TenantID,
+					WorkstreamID:      *w.cfg.WorkstreamID,
+					MaxResults:        maxResults,
+					Token:             token,
+					IncludeDeleted:    util.Pointer(w.cfg.IncludeDeleted),
+				},
+			)
+			if err != nil {
+				return nil, err
+			}
+			page, next = resp.Items, resp.NextToken
+		} else {
+			resp, err := w.client.ListTasks(
+				ctx, &ListTasksRequest{
+					FeatureFlags:      flags,
+					DelegatedAuthInfo: w.cfg.DelegatedAuth,
+					TenantID:          w.cfg.TenantID,
+					MaxResults:        maxResults,
+					Token:             token,
+					IncludeDeleted:    util.Pointer(w.cfg.IncludeDeleted),
+				},
+			)
+			if err != nil {
+				return nil, err
+			}
+			page, next = resp.Tasks, resp.NextToken
+		}
+		tasks = append(tasks, page...)
+		if next == nil {
+			return tasks, nil
+		}
+		token = next
+	}
+}
+
+// apply diffs a snapshot against the known tasks and sends the resulting events. Each task is recorded as known once
+// its events are sent, so the cursor never skips an event. It returns false if the watcher was stopped. Only the run
+// goroutine modifies known, so it reads it without holding mu.
+func (w *TaskWatcher) apply(ctx context.Context, tasks []Task) bool {
+	primed := w.primed
+	w.primed = true
+
+	seen := make(map[string]bool, len(tasks))
+	for i := range tasks {
+		task := &tasks[i]
+		seen[task.TaskID] = true
+		prev := w.known[task.TaskID]
+
+		var events []TaskEvent
+		if primed {
+			events = diffTask(prev, task)
+		}
+		if !w.send(ctx, events) {
+			return false
+		}
+		w.mu.Lock()
+		w.known[task.TaskID] = task
+		w.mu.Unlock()
+	}
+
+	var gone []string
+	for id := range w.known {
+		if !seen[id] {
+			gone = append(gone, id)
+		}
+	}
+	slices.Sort(gone)
+
+	for _, id := range gone {
+		prev := w.known[id]
+		// A task already reported deleted via its Deleted flag isn't reported again when it stops being listed.
+		if primed && !prev.Deleted {
+			if !w.send(ctx, []TaskEvent{{Type: TaskEventDeleted, TaskID: id, Task: prev, Previous: prev}}) {
+				return false
+			}
+		}
+		w.mu.Lock()
+		delete(w.known, id)
+		w.mu.Unlock()
+	}
+	return true
+}
+
+func (w *TaskWatcher) send(ctx context.Context, events []TaskEvent) bool {
+	for _, event := range events {
+		select {
+		case w.events <- event:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
+
+// diffTask returns the events for the change from prev to task. prev is nil for a task that wasn't known.
+func diffTask(prev, task *Task) []TaskEvent {
+	if prev == nil {
+		return []TaskEvent{{Type: TaskEventCreated, TaskID: task.TaskID, Task: task}}
+	}
+	if prev.Version == task.Version {
+		return nil
+	}
+
+	events := []TaskEvent{{Type: TaskEventUpdated, TaskID: task.TaskID, Task: task, Previous: prev}}
+	if prev.State != task.State {
+		events = append(
+			events,
+			TaskEvent{Type: TaskEventStateChanged, TaskID: task.TaskID, Task: task, Previous: prev},
+		)
+	}
+	repos := slices.Sorted(maps.Keys(task.RepoInfo))
+	for _, repo := range repos {
+		if prStatus(prev, repo) != prStatus(task, repo) {
+			events = append(
+				events,
+				TaskEvent{Type: TaskEventPRStatusChanged, TaskID: task.TaskID, Task: task, Previous: prev, Repo: repo},
+			)
+		}
+	}
+	if task.Deleted && !prev.Deleted {
+		events = append(events, TaskEvent{Type: TaskEventDeleted, TaskID: task.TaskID, Task: task, Previous: prev})
+	}
+	return events
+}
+
+func prStatus(task *Task, repo string) string {
+	info := task.RepoInfo[repo]
+	if info == nil || info.PRStatus == nil {
+		return ""
+	}
+	return *info.PRStatus
+}
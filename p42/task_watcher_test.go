@@ -0,0 +1,206 @@
+package p42_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/plan42-ai/sdk-go/internal/util"
+	"github.com/plan42-ai/sdk-go/p42"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTaskListServer serves successive snapshots of a tenant's tasks, one task per page. Each listing from the first
+// page moves to the next snapshot, repeating the last.
+type fakeTaskListServer struct {
+	mu        sync.Mutex
+	snapshots [][]p42.Task
+	current   int
+}
+
+func (s *fakeTaskListServer) handler(t *testing.T) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(
+		"GET /v1/tenants/ten/tasks", func(w http.ResponseWriter, r *http.Request) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			page := 0
+			if token := r.URL.Query().Get("token"); token != "" {
+				page, _ = strconv.Atoi(token)
+			} else {
+				s.current = min(s.current+1, len(s.snapshots))
+			}
+			snapshot := s.snapshots[s.current-1]
+
+			var resp p42.ListTasksResponse
+			if page < len(snapshot) {
+				resp.Tasks = snapshot[page : page+1]
+			}
+			if page+1 < len(snapshot) {
+				resp.NextToken = util.Pointer(strconv.Itoa(page + 1))
+			}
+			w.Header().Set("Content-Type", "application/json")
+			require.NoError(t, json.NewEncoder(w).Encode(resp))
+		},
+	)
+	return mux
+}
+
+func watchedTask(id string, version int, state p42.TaskState, prStatus string) p42.Task {
+	task := p42.Task{TaskID: id, Version: version, State: state}
+	if prStatus != "" {
+		task.RepoInfo = map[string]*p42.RepoInfo{"org/repo": {PRStatus: util.Pointer(prStatus)}}
+	}
+	return task
+}
+
+func collectEvents(t *testing.T, w *p42.TaskWatcher, n int) []string {
+	t.Helper()
+	var got []string
+	timeout := time.After(5 * time.Second)
+	for len(got) < n {
+		select {
+		case event, ok := <-w.Events():
+			require.True(t, ok, "events closed early: %v", w.Err())
+			got = append(got, fmt.Sprintf("%s:%s", event.TaskID, event.Type))
+		case <-timeout:
+			require.FailNow(t, "timed out", "got %v", got)
+		}
+	}
+	return got
+}
+
+func TestTaskWatcher(t *testing.T) {
+	t.Parallel()
+	fake := &fakeTaskListServer{
+		snapshots: [][]p42.Task{
+			{
+				watchedTask("a", 1, p42.TaskStatePending, ""),
+				watchedTask("b", 1, p42.TaskStateExecuting, "open"),
+			},
+			{
+				watchedTask("a", 2, p42.TaskStateExecuting, ""),
+				watchedTask("b", 2, p42.TaskStateExecuting, "merged"),
+				watchedTask("c", 1, p42.TaskStatePending, ""),
+			},
+			{
+				watchedTask("a", 3, p42.TaskStateAwaitingCodeReview, ""),
+				watchedTask("c", 1, p42.TaskStatePending, ""),
+			},
+		},
+	}
+	srv := httptest.NewServer(fake.handler(t))
+	defer srv.Close()
+
+	w := p42.NewTaskWatcher(
+		context.Background(),
+		&p42.TaskWatcherConfig{
+			Client:   p42.NewClient(srv.URL),
+			TenantID: "ten",
+			Interval: time.Millisecond,
+		},
+	)
+	defer w.Close()
+
+	// The first snapshot only establishes the baseline.
+	require.Equal(
+		t,
+		[]string{
+			"a:Updated", "a:StateChanged",
+			"b:Updated", "b:PRStatusChanged",
+			"c:Created",
+			"a:Updated", "a:StateChanged",
+			"b:Deleted",
+		},
+		collectEvents(t, w, 8),
+	)
+
+	require.NoError(t, w.Close())
+	require.NoError(t, w.Err())
+	cursor := w.Cursor()
+	require.Len(t, cursor.Tasks, 2)
+	require.Equal(t, 3, cursor.Tasks["a"].Version)
+}
+
+func TestTaskWatcherEmitInitial(t *testing.T) {
+	t.Parallel()
+	fake := &fakeTaskListServer{snapshots: [][]p42.Task{{watchedTask("a", 1, p42.TaskStatePending, "")}}}
+	srv := httptest.NewServer(fake.handler(t))
+	defer srv.Close()
+
+	w := p42.NewTaskWatcher(
+		context.Background(),
+		&p42.TaskWatcherConfig{
+			Client:      p42.NewClient(srv.URL),
+			TenantID:    "ten",
+			Interval:    time.Millisecond,
+			EmitInitial: true,
+		},
+	)
+	defer w.Close()
+	require.Equal(t, []string{"a:Created"}, collectEvents(t, w, 1))
+}
+
+func TestTaskWatcherResume(t *testing.T) {
+	t.Parallel()
+	fake := &fakeTaskListServer{
+		snapshots: [][]p42.Task{
+			{watchedTask("a", 1, p42.TaskStatePending, ""), watchedTask("b", 4, p42.TaskStateCompleted, "")},
+		},
+	}
+	srv := httptest.NewServer(fake.handler(t))
+	defer srv.Close()
+
+	cursor := &p42.TaskWatchCursor{
+		Tasks: map[string]*p42.Task{
+			"a": util.Pointer(watchedTask("a", 1, p42.TaskStatePending, "")),
+			"b": util.Pointer(watchedTask("b", 3, p42.TaskStateExecuting, "")),
+		},
+	}
+	// Round trip the cursor through JSON, as a caller persisting it would.
+	data, err := json.Marshal(cursor)
+	require.NoError(t, err)
+	var resumed p42.TaskWatchCursor
+	require.NoError(t, json.Unmarshal(data, &resumed))
+
+	w := p42.NewTaskWatcher(
+		context.Background(),
+		&p42.TaskWatcherConfig{
+			Client:   p42.NewClient(srv.URL),
+			TenantID: "ten",
+			Interval: time.Millisecond,
+			Cursor:   &resumed,
+		},
+	)
+	defer w.Close()
+	require.Equal(t, []string{"b:Updated", "b:StateChanged"}, collectEvents(t, w, 2))
+}
+
+func TestTaskWatcherPermanentError(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusForbidden)
+				_ = json.NewEncoder(w).Encode(p42.Error{ResponseCode: http.StatusForbidden, Message: "forbidden"})
+			},
+		),
+	)
+	defer srv.Close()
+
+	w := p42.NewTaskWatcher(
+		context.Background(),
+		&p42.TaskWatcherConfig{Client: p42.NewClient(srv.URL), TenantID: "ten", Interval: time.Millisecond},
+	)
+	for range w.Events() {
+	}
+	var apiErr *p42.Error
+	require.ErrorAs(t, w.Err(), &apiErr)
+	require.Equal(t, http.StatusForbidden, apiErr.ResponseCode)
+}
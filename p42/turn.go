@@ -19,7 +19,7 @@ type Turn struct {
 	Prompt             string                `json:"Prompt"`
 	PreviousResponseID *string               `json:"PreviousResponseID,omitempty"`
 	CommitInfo         map[string]CommitInfo `json:"CommitInfo"`
-	Status             string                `json:"Status"`
+	Status             TurnStatus            `json:"Status"`
 	OutputMessage      *string               `json:"OutputMessage,omitempty"`
 	ErrorMessage       *string               `json:"ErrorMessage,omitempty"`
 	CreatedAt          time.Time             `json:"CreatedAt"`
@@ -300,10 +300,15 @@ type UpdateTurnRequest struct {
 	Version            int                    `json:"-"`
 	PreviousResponseID *string                `json:"PreviousResponseID,omitempty"`
 	CommitInfo         *map[string]CommitInfo `json:"CommitInfo,omitempty"`
-	Status             *string                `json:"Status,omitempty"`
+	Status             *TurnStatus            `json:"Status,omitempty"`
 	OutputMessage      *string                `json:"OutputMessage,omitempty"`
 	ErrorMessage       *string                `json:"ErrorMessage,omitempty"`
 	CompletedAt        *time.Time             `json:"CompletedAt,omitempty"`
+
+	// CurrentStatus is the turn's status before the update. If set along with Status, UpdateTurn checks the
+	// transition against TurnLifecycle and fails with an *InvalidTransitionError, without sending the request, if it
+	// is illegal. It is not sent to the service.
+	CurrentStatus *TurnStatus `json:"-"`
 }
 
 // GetField retrieves the value of a field by name.
@@ -349,6 +354,11 @@ func (c *Client) UpdateTurn(ctx context.Context, req *UpdateTurnRequest) (*Turn,
 	if req.TurnIndex < 0 {
 		return nil, fmt.Errorf("turn index is required")
 	}
+	if req.CurrentStatus != nil && req.Status != nil {
+		if err := TurnLifecycle.Validate(*req.CurrentStatus, *req.Status); err != nil {
+			return nil, err
+		}
+	}
 
 	bodyBytes, err := json.Marshal(req)
 	if err != nil {
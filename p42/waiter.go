@@ -0,0 +1,382 @@
+package p42
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/plan42-ai/sdk-go/internal/util"
+)
+
+// TurnFailedError is returned by WaitForTurnCompletion when the turn completes with an error message.
+type TurnFailedError struct {
+	Turn *Turn
+}
+
+func (e *TurnFailedError) Error() string {
+	return fmt.Sprintf("turn %d of task %s failed: %s", e.Turn.TurnIndex, e.Turn.TaskID, *e.Turn.ErrorMessage)
+}
+
+type waitConfig struct {
+	pollInterval    time.Duration
+	maxPollInterval time.Duration
+	timeout         time.Duration
+	sse             bool
+}
+
+const (
+	defaultWaitPollInterval    = 2 * time.Second
+	defaultWaitMaxPollInterval = 30 * time.Second
+)
+
+// WaitOption configures WaitForTurnCompletion, WaitForTaskState and WaitForPRStatus.
+type WaitOption func(cfg *waitConfig)
+
+// WithPollInterval sets the initial interval between polls. The interval doubles after each poll that doesn't finish
+// the wait, up to the maximum set with WithMaxPollInterval. Defaults to 2 seconds.
+func WithPollInterval(d time.Duration) WaitOption {
+	return func(cfg *waitConfig) {
+		cfg.pollInterval = d
+	}
+}
+
+// WithMaxPollInterval sets the maximum interval between polls. Defaults to 30 seconds.
+func WithMaxPollInterval(d time.Duration) WaitOption {
+	return func(cfg *waitConfig) {
+		cfg.maxPollInterval = d
+	}
+}
+
+// WithWaitTimeout limits how long to wait. When it expires, the wait fails with an error wrapping
+// context.DeadlineExceeded.
+func WithWaitTimeout(d time.Duration) WaitOption {
+	return func(cfg *waitConfig) {
+		cfg.timeout = d
+	}
+}
+
+// WithSSENotifications makes WaitForTurnCompletion subscribe to the turn's log stream, and poll as soon as the turn's
+// status changes rather than waiting for the next poll interval. Polling continues as a fallback. It has no effect on
+// the other waiters.
+func WithSSENotifications() WaitOption {
+	return func(cfg *waitConfig) {
+		cfg.sse = true
+	}
+}
+
+func newWaitConfig(options []WaitOption) *waitConfig {
+	cfg := &waitConfig{}
+	for _, option := range options {
+		option(cfg)
+	}
+	if cfg.pollInterval <= 0 {
+		cfg.pollInterval = defaultWaitPollInterval
+	}
+	if cfg.maxPollInterval < cfg.pollInterval {
+		cfg.maxPollInterval = max(defaultWaitMaxPollInterval, cfg.pollInterval)
+	}
+	return cfg
+}
+
+// WaitForTurnCompletionRequest identifies the turn to wait for.
+type WaitForTurnCompletionRequest struct {
+	FeatureFlags
+	DelegatedAuthInfo
+	TenantID string
+	TaskID   string
+
+	// TurnIndex is the turn to wait for. If nil, waits for the task's last turn.
+	TurnIndex      *int
+	IncludeDeleted *bool
+
+	// Until overrides when the wait ends. By default, the wait ends when the turn has completed or its status is
+	// terminal in TurnLifecycle.
+	Until func(turn *Turn) bool
+}
+
+// WaitForTurnCompletion polls a turn until it completes, or until req.Until returns true. It returns the final turn.
+// If the turn completed with an error message, it also returns a *TurnFailedError. If the wait fails, the last turn
+// observed (if any) is returned with the error.
+func WaitForTurnCompletion(
+	ctx context.Context,
+	client *Client,
+	req *WaitForTurnCompletionRequest,
+	options ...WaitOption,
+) (*Turn, error) {
+	if req == nil {
+		return nil, fmt.Errorf("req is nil")
+	}
+	if req.TenantID == "" {
+		return nil, fmt.Errorf("tenant id is required")
+	}
+	if req.TaskID == "" {
+		return nil, fmt.Errorf("task id is required")
+	}
+	cfg := newWaitConfig(options)
+	ctx, cancel := cfg.context(ctx)
+	defer cancel()
+
+	until := req.Until
+	if until == nil {
+		until = func(turn *Turn) bool { return turn.CompletedAt != nil || TurnLifecycle.IsTerminal(turn.Status) }
+	}
+
+	notify := make(chan struct{}, 1)
+	var ls *LogStream
+	defer func() {
+		if ls != nil {
+			_ = ls.Close()
+		}
+	}()
+
+	get := func(ctx context.Context) (*Turn, error) {
+		var turn *Turn
+		var err error
+		if req.TurnIndex == nil {
+			turn, err = client.GetLastTurn(
+				ctx, &GetLastTurnRequest{
+					FeatureFlags:      req.FeatureFlags,
+					DelegatedAuthInfo: req.DelegatedAuthInfo,
+					TenantID:          req.TenantID,
+					TaskID:            req.TaskID,
+					IncludeDeleted:    req.IncludeDeleted,
+				},
+			)
+		} else {
+			turn, err = client.GetTurn(
+				ctx, &GetTurnRequest{
+					FeatureFlags:      req.FeatureFlags,
+					DelegatedAuthInfo: req.DelegatedAuthInfo,
+					TenantID:          req.TenantID,
+					TaskID:            req.TaskID,
+					TurnIndex:         *req.TurnIndex,
+					IncludeDeleted:    req.IncludeDeleted,
+				},
+			)
+		}
+		if err == nil && cfg.sse && ls == nil {
+			ls = watchTurnStatus(ctx, client, req, turn.TurnIndex, notify)
+		}
+		return turn, err
+	}
+
+	turn, err := poll(ctx, cfg, notify, get, until)
+	if err != nil {
+		return turn, err
+	}
+	if turn.ErrorMessage != nil {
+		return turn, &TurnFailedError{Turn: turn}
+	}
+	return turn, nil
+}
+
+// watchTurnStatus starts a LogStream for the turn that signals notify whenever the turn's status changes, and when
+// the stream ends.
+func watchTurnStatus(
+	ctx context.Context,
+	client *Client,
+	req *WaitForTurnCompletionRequest,
+	turnIndex int,
+	notify chan<- struct{},
+) *LogStream {
+	signal := func() {
+		select {
+		case notify <- struct{}{}:
+		default:
+		}
+	}
+
+	ls := NewLogStreamContext(
+		ctx,
+		client,
+		req.TenantID,
+		req.TaskID,
+		turnIndex,
+		0,
+		WithIncludeDeleted(req.IncludeDeleted != nil && *req.IncludeDeleted),
+		WithFeatureFlags(req.FeatureFlags.FeatureFlags),
+		WithDelegatedAuth(req.DelegatedAuthInfo),
+		WithTurnStatusHandler(func(TurnStatusEvent) { signal() }),
+	)
+	go func() {
+		for range ls.Logs() {
+		}
+		signal()
+	}()
+	return ls
+}
+
+// WaitForTaskStateRequest identifies the task to wait for, and the states to wait for.
+type WaitForTaskStateRequest struct {
+	FeatureFlags
+	DelegatedAuthInfo
+	TenantID       string
+	TaskID         string
+	IncludeDeleted *bool
+
+	// States ends the wait when the task is in any of them.
+	States []TaskState
+
+	// Until ends the wait when it returns true. At least one of States and Until is required.
+	Until func(task *Task) bool
+}
+
+// WaitForTaskState polls a task until it is in one of req.States, or until req.Until returns true. It returns the
+// final task. If the wait fails, the last task observed (if any) is returned with the error.
+func WaitForTaskState(
+	ctx context.Context,
+	client *Client,
+	req *WaitForTaskStateRequest,
+	options ...WaitOption,
+) (*Task, error) {
+	if req == nil {
+		return nil, fmt.Errorf("req is nil")
+	}
+	if len(req.States) == 0 && req.Until == nil {
+		return nil, fmt.Errorf("states or until is required")
+	}
+	return waitForTask(
+		ctx, client, &req.FeatureFlags, &req.DelegatedAuthInfo, req.TenantID, req.TaskID, req.IncludeDeleted,
+		func(task *Task) bool {
+			return slices.Contains(req.States, task.State) || (req.Until != nil && req.Until(task))
+		},
+		options,
+	)
+}
+
+// WaitForPRStatusRequest identifies the task to wait for, and the pull request statuses to wait for.
+type WaitForPRStatusRequest struct {
+	FeatureFlags
+	DelegatedAuthInfo
+	TenantID       string
+	TaskID         string
+	IncludeDeleted *bool
+
+	// Repo is the key in the task's RepoInfo of the pull request to watch. If nil, the wait ends when the pull
+	// request of any repo has a matching status.
+	Repo *string
+
+	// Statuses ends the wait when the pull request has any of them.
+	Statuses []string
+
+	// Until ends the wait when it returns true. At least one of Statuses and Until is required.
+	Until func(task *Task) bool
+}
+
+// WaitForPRStatus polls a task until the status of its pull request is one of req.Statuses, or until req.Until
+// returns true. It returns the final task. If the wait fails, the last task observed (if any) is returned with the
+// error.
+func WaitForPRStatus(
+	ctx context.Context,
+	client *Client,
+	req *WaitForPRStatusRequest,
+	options ...WaitOption,
+) (*Task, error) {
+	if req == nil {
+		return nil, fmt.Errorf("req is nil")
+	}
+	if len(req.Statuses) == 0 && req.Until == nil {
+		return nil, fmt.Errorf("statuses or until is required")
+	}
+	return waitForTask(
+		ctx, client, &req.FeatureFlags, &req.DelegatedAuthInfo, req.TenantID, req.TaskID, req.IncludeDeleted,
+		func(task *Task) bool {
+			for name, info := range task.RepoInfo {
+				if req.Repo != nil && name != *req.Repo {
+					continue
+				}
+				if info != nil && info.PRStatus != nil && slices.Contains(req.Statuses, *info.PRStatus) {
+					return true
+				}
+			}
+			return req.Until != nil && req.Until(task)
+		},
+		options,
+	)
+}
+
+func waitForTask(
+	ctx context.Context,
+	client *Client,
+	flags *FeatureFlags,
+	auth *DelegatedAuthInfo,
+	tenantID string,
+	taskID string,
+	includeDeleted *bool,
+	until func(task *Task) bool,
+	options []WaitOption,
+) (*Task, error) {
+	if tenantID == "" {
+		return nil, fmt.Errorf("tenant id is required")
+	}
+	if taskID == "" {
+		return nil, fmt.Errorf("task id is required")
+	}
+	cfg := newWaitConfig(options)
+	ctx, cancel := cfg.context(ctx)
+	defer cancel()
+
+	get := func(ctx context.Context) (*Task, error) {
+		return client.GetTask(
+			ctx, &GetTaskRequest{
+				FeatureFlags:      *flags,
+				DelegatedAuthInfo: *auth,
+				TenantID:          tenantID,
+				TaskID:            taskID,
+				IncludeDeleted:    includeDeleted,
+			},
+		)
+	}
+	return poll(ctx, cfg, nil, get, until)
+}
+
+func (cfg *waitConfig) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if cfg.timeout > 0 {
+		return context.WithTimeout(ctx, cfg.timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// poll calls get until until returns true for its result, backing off between calls. A signal on notify triggers the
+// next call early. Retryable errors from get are retried; any other error ends the wait. The last value returned by
+// get is returned, even on error.
+func poll[T any](
+	ctx context.Context,
+	cfg *waitConfig,
+	notify <-chan struct{},
+	get func(ctx context.Context) (*T, error),
+	until func(value *T) bool,
+) (*T, error) {
+	backoff := util.NewBackoff(cfg.pollInterval, cfg.maxPollInterval)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	var last *T
+	for {
+		select {
+		case <-ctx.Done():
+			err := ctx.Err()
+			if errors.Is(err, context.DeadlineExceeded) {
+				err = fmt.Errorf("timed out waiting: %w", err)
+			}
+			return last, err
+		case <-timer.C:
+		case <-notify:
+		}
+
+		value, err := get(ctx)
+		switch {
+		case err != nil && ctx.Err() == nil && !isRetryableError(err):
+			return last, err
+		case err == nil:
+			last = value
+			if until(value) {
+				return value, nil
+			}
+		}
+		backoff.Backoff()
+		timer.Reset(backoff.Current())
+	}
+}
@@ -0,0 +1,308 @@
+package p42_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/plan42-ai/sdk-go/internal/util"
+	"github.com/plan42-ai/sdk-go/p42"
+	"github.com/stretchr/testify/require"
+)
+
+// sequenceHandler serves the next of values on each request, repeating the last one.
+func sequenceHandler[T any](t *testing.T, values ...T) (http.HandlerFunc, func() int) {
+	var mu sync.Mutex
+	calls := 0
+	handler := func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		value := values[min(calls, len(values)-1)]
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(value))
+	}
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls
+	}
+	return handler, count
+}
+
+var fastPoll = []p42.WaitOption{p42.WithPollInterval(time.Millisecond), p42.WithMaxPollInterval(5 * time.Millisecond)}
+
+func TestWaitForTurnCompletion(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	handler, calls := sequenceHandler(
+		t,
+		p42.Turn{TaskID: "task", TurnIndex: 2, Status: p42.TurnStatus("Running")},
+		p42.Turn{TaskID: "task", TurnIndex: 2, Status: p42.TurnStatus("Running")},
+		p42.Turn{TaskID: "task", TurnIndex: 2, Status: p42.TurnStatusDone, CompletedAt: &now},
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/tenants/ten/tasks/task/turns/last", handler)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	turn, err := p42.WaitForTurnCompletion(
+		context.Background(),
+		p42.NewClient(srv.URL),
+		&p42.WaitForTurnCompletionRequest{TenantID: "ten", TaskID: "task"},
+		fastPoll...,
+	)
+	require.NoError(t, err)
+	require.Equal(t, p42.TurnStatusDone, turn.Status)
+	require.Equal(t, 3, calls())
+}
+
+func TestWaitForTurnCompletionSucceeded(t *testing.T) {
+	t.Parallel()
+	// Succeeded is terminal even before CompletedAt is set.
+	handler, calls := sequenceHandler(
+		t,
+		p42.Turn{TaskID: "task", TurnIndex: 1, Status: p42.TurnStatus("Running")},
+		p42.Turn{TaskID: "task", TurnIndex: 1, Status: p42.TurnStatusSucceeded},
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/tenants/ten/tasks/task/turns/1", handler)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	turn, err := p42.WaitForTurnCompletion(
+		context.Background(),
+		p42.NewClient(srv.URL),
+		&p42.WaitForTurnCompletionRequest{TenantID: "ten", TaskID: "task", TurnIndex: util.Pointer(1)},
+		append(fastPoll, p42.WithWaitTimeout(5*time.Second))...,
+	)
+	require.NoError(t, err)
+	require.Equal(t, p42.TurnStatusSucceeded, turn.Status)
+	require.Equal(t, 2, calls())
+}
+
+func TestWaitForTurnCompletionFailed(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	handler, _ := sequenceHandler(
+		t,
+		p42.Turn{TaskID: "task", TurnIndex: 1, Status: p42.TurnStatusFailed, CompletedAt: &now, ErrorMessage: util.Pointer("boom")},
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/tenants/ten/tasks/task/turns/1", handler)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	turn, err := p42.WaitForTurnCompletion(
+		context.Background(),
+		p42.NewClient(srv.URL),
+		&p42.WaitForTurnCompletionRequest{TenantID: "ten", TaskID: "task", TurnIndex: util.Pointer(1)},
+		fastPoll...,
+	)
+	var failed *p42.TurnFailedError
+	require.ErrorAs(t, err, &failed)
+	require.Equal(t, turn, failed.Turn)
+	require.Equal(t, "turn 1 of task task failed: boom", err.Error())
+}
+
+func TestWaitForTurnCompletionUntil(t *testing.T) {
+	t.Parallel()
+	handler, _ := sequenceHandler(
+		t,
+		p42.Turn{TaskID: "task", TurnIndex: 1, Status: p42.TurnStatusPending},
+		p42.Turn{TaskID: "task", TurnIndex: 1, Status: p42.TurnStatus("Running")},
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/tenants/ten/tasks/task/turns/1", handler)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	turn, err := p42.WaitForTurnCompletion(
+		context.Background(),
+		p42.NewClient(srv.URL),
+		&p42.WaitForTurnCompletionRequest{
+			TenantID:  "ten",
+			TaskID:    "task",
+			TurnIndex: util.Pointer(1),
+			Until:     func(turn *p42.Turn) bool { return turn.Status == p42.TurnStatus("Running") },
+		},
+		fastPoll...,
+	)
+	require.NoError(t, err)
+	require.Equal(t, p42.TurnStatus("Running"), turn.Status)
+}
+
+func TestWaitForTurnCompletionTimeout(t *testing.T) {
+	t.Parallel()
+	handler, _ := sequenceHandler(t, p42.Turn{TaskID: "task", TurnIndex: 1, Status: p42.TurnStatus("Running")})
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	turn, err := p42.WaitForTurnCompletion(
+		context.Background(),
+		p42.NewClient(srv.URL),
+		&p42.WaitForTurnCompletionRequest{TenantID: "ten", TaskID: "task", TurnIndex: util.Pointer(1)},
+		append(fastPoll, p42.WithWaitTimeout(50*time.Millisecond))...,
+	)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.NotNil(t, turn)
+	require.Equal(t, p42.TurnStatus("Running"), turn.Status)
+}
+
+func TestWaitForTurnCompletionRetriesTransientErrors(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	var mu sync.Mutex
+	calls := 0
+	srv := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, _ *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				calls++
+				if calls == 1 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					_ = json.NewEncoder(w).Encode(p42.Error{ResponseCode: http.StatusServiceUnavailable, Message: "busy"})
+					return
+				}
+				_ = json.NewEncoder(w).Encode(p42.Turn{TaskID: "task", TurnIndex: 1, CompletedAt: &now})
+			},
+		),
+	)
+	defer srv.Close()
+
+	_, err := p42.WaitForTurnCompletion(
+		context.Background(),
+		p42.NewClient(srv.URL),
+		&p42.WaitForTurnCompletionRequest{TenantID: "ten", TaskID: "task", TurnIndex: util.Pointer(1)},
+		fastPoll...,
+	)
+	require.NoError(t, err)
+}
+
+func TestWaitForTurnCompletionSSE(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	handler, calls := sequenceHandler(
+		t,
+		p42.Turn{TaskID: "task", TurnIndex: 1, Status: p42.TurnStatus("Running")},
+		p42.Turn{TaskID: "task", TurnIndex: 1, Status: p42.TurnStatusDone, CompletedAt: &now},
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/tenants/ten/tasks/task/turns/1", handler)
+	mux.HandleFunc(
+		"GET /v1/tenants/ten/tasks/task/turns/1/logs", func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte("event: turn-status\ndata: {\"Status\":\"Done\",\"Version\":2}\n\nevent: end\ndata: {}\n\n"))
+		},
+	)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	// The poll interval is far longer than the test timeout, so the second poll must be triggered by the event.
+	turn, err := p42.WaitForTurnCompletion(
+		context.Background(),
+		p42.NewClient(srv.URL),
+		&p42.WaitForTurnCompletionRequest{TenantID: "ten", TaskID: "task", TurnIndex: util.Pointer(1)},
+		p42.WithPollInterval(time.Hour),
+		p42.WithSSENotifications(),
+		p42.WithWaitTimeout(5*time.Second),
+	)
+	require.NoError(t, err)
+	require.Equal(t, p42.TurnStatusDone, turn.Status)
+	require.Equal(t, 2, calls())
+}
+
+func TestWaitForTaskState(t *testing.T) {
+	t.Parallel()
+	handler, _ := sequenceHandler(
+		t,
+		p42.Task{TaskID: "task", State: p42.TaskStatePending},
+		p42.Task{TaskID: "task", State: p42.TaskStateExecuting},
+		p42.Task{TaskID: "task", State: p42.TaskStateAwaitingCodeReview},
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/tenants/ten/tasks/task", handler)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	task, err := p42.WaitForTaskState(
+		context.Background(),
+		p42.NewClient(srv.URL),
+		&p42.WaitForTaskStateRequest{
+			TenantID: "ten",
+			TaskID:   "task",
+			States:   []p42.TaskState{p42.TaskStateAwaitingCodeReview, p42.TaskStateCompleted},
+		},
+		fastPoll...,
+	)
+	require.NoError(t, err)
+	require.Equal(t, p42.TaskStateAwaitingCodeReview, task.State)
+
+	_, err = p42.WaitForTaskState(
+		context.Background(),
+		p42.NewClient(srv.URL),
+		&p42.WaitForTaskStateRequest{TenantID: "ten", TaskID: "task"},
+	)
+	require.ErrorContains(t, err, "states or until is required")
+}
+
+func TestWaitForTaskStateNotFound(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+				_ = json.NewEncoder(w).Encode(p42.Error{ResponseCode: http.StatusNotFound, Message: "not found"})
+			},
+		),
+	)
+	defer srv.Close()
+
+	_, err := p42.WaitForTaskState(
+		context.Background(),
+		p42.NewClient(srv.URL),
+		&p42.WaitForTaskStateRequest{TenantID: "ten", TaskID: "task", States: []p42.TaskState{p42.TaskStateCompleted}},
+		fastPoll...,
+	)
+	var apiErr *p42.Error
+	require.ErrorAs(t, err, &apiErr)
+	require.Equal(t, http.StatusNotFound, apiErr.ResponseCode)
+}
+
+func TestWaitForPRStatus(t *testing.T) {
+	t.Parallel()
+	repoInfo := func(status string) map[string]*p42.RepoInfo {
+		return map[string]*p42.RepoInfo{
+			"org/a": {PRStatus: util.Pointer("merged")},
+			"org/b": {PRStatus: util.Pointer(status)},
+		}
+	}
+	handler, _ := sequenceHandler(
+		t,
+		p42.Task{TaskID: "task", RepoInfo: repoInfo("open")},
+		p42.Task{TaskID: "task", RepoInfo: repoInfo("merged"), Version: 2},
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/tenants/ten/tasks/task", handler)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	task, err := p42.WaitForPRStatus(
+		context.Background(),
+		p42.NewClient(srv.URL),
+		&p42.WaitForPRStatusRequest{
+			TenantID: "ten",
+			TaskID:   "task",
+			Repo:     util.Pointer("org/b"),
+			Statuses: []string{"merged", "closed"},
+		},
+		fastPoll...,
+	)
+	require.NoError(t, err)
+	require.Equal(t, 2, task.Version)
+}